@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"mingo/internal/dap"
+)
+
+// dap is a Debug Adapter Protocol server on stdin/stdout. Editors start it
+// and send a launch request naming the program to debug.
+func main() {
+	if err := dap.New(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "dap:", err)
+		os.Exit(1)
+	}
+}
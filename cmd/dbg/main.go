@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"mingo/internal/compiler"
+	"mingo/internal/debugger"
+	"mingo/internal/module"
+	"mingo/internal/vm"
+)
+
+const PROMPT = "dbg> "
+
+const help = `commands:
+  break N      set a breakpoint on line N (b)
+  clear N      remove the breakpoint on line N
+  next         step over calls to the next line (n)
+  step         step into calls (s)
+  out          run until the current function returns (o)
+  continue     run to the next breakpoint (c)
+  print x      show a variable (p)
+  locals       show the current frame's locals
+  globals      show global variables
+  bt           show the call stack
+  list         show the source around the current line (l)
+  quit         abort the program (q)`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println("Usage: dbg <file.mg>")
+		os.Exit(2)
+	}
+	b, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	source := string(b)
+
+	mods, err := (&module.Loader{}).Load(os.Args[1], source)
+	if err != nil {
+		if errs, ok := err.(module.ErrorList); ok {
+			for _, e := range errs {
+				fmt.Fprintln(os.Stderr, e)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(3)
+	}
+
+	if errs := module.Check(mods); len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, "type error:", e)
+		}
+		os.Exit(4)
+	}
+
+	sym := compiler.NewSymbolTable()
+	comp := compiler.NewWithState(sym, nil)
+	if err := module.Compile(comp, mods); err != nil {
+		if errs, ok := err.(compiler.ErrorList); ok {
+			for _, e := range errs {
+				fmt.Fprintln(os.Stderr, "compile error:", e)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, "compile error:", err)
+		}
+		os.Exit(4)
+	}
+
+	fe := &frontend{in: bufio.NewScanner(os.Stdin), lines: strings.Split(source, "\n")}
+	fmt.Println("Mingo debugger. Type help for commands.")
+	dbg := debugger.New(sym, fe.stopped, true)
+	machine := vm.NewFromBytecode(comp.Bytecode(), nil)
+	err = dbg.Run(machine)
+	switch {
+	case errors.Is(err, debugger.ErrAborted):
+		fmt.Println("aborted")
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "runtime error:", err)
+		os.Exit(5)
+	default:
+		fmt.Println("program finished")
+	}
+}
+
+type frontend struct {
+	in    *bufio.Scanner
+	lines []string
+}
+
+// stopped runs the command loop until a command resumes execution.
+func (fe *frontend) stopped(d *debugger.Debugger, s debugger.Stop) debugger.Action {
+	if s.Reason == debugger.ReasonBreakpoint {
+		fmt.Printf("breakpoint at line %d\n", s.Pos.Line)
+	}
+	fe.showLine(s.Pos.Line)
+
+	for {
+		fmt.Print(PROMPT)
+		if !fe.in.Scan() {
+			return debugger.Abort
+		}
+		fields := strings.Fields(fe.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		arg := ""
+		if len(fields) > 1 {
+			arg = fields[1]
+		}
+
+		switch fields[0] {
+		case "break", "b":
+			if line, ok := fe.lineArg(arg); ok {
+				d.SetBreakpoint(line)
+				fmt.Printf("breakpoint set at line %d\n", line)
+			}
+		case "clear":
+			if line, ok := fe.lineArg(arg); ok {
+				d.ClearBreakpoint(line)
+			}
+		case "next", "n":
+			return debugger.StepOver
+		case "step", "s":
+			return debugger.StepIn
+		case "out", "o":
+			return debugger.StepOut
+		case "continue", "c":
+			return debugger.Continue
+		case "print", "p":
+			if v, ok := d.Lookup(arg); ok {
+				fmt.Printf("%s = %s\n", arg, v.Inspect())
+			} else {
+				fmt.Printf("undefined variable %s\n", arg)
+			}
+		case "locals":
+			printVariables(d.CallStack()[0].Locals)
+		case "globals":
+			printVariables(d.Globals())
+		case "bt":
+			for i, f := range d.CallStack() {
+				fmt.Printf("#%d %s at line %d\n", i, f.Function, f.Pos.Line)
+			}
+		case "list", "l":
+			for n := s.Pos.Line - 3; n <= s.Pos.Line+3; n++ {
+				fe.printLine(n, n == s.Pos.Line)
+			}
+		case "quit", "q":
+			return debugger.Abort
+		case "help", "h":
+			fmt.Println(help)
+		default:
+			fmt.Printf("unknown command %q; type help\n", fields[0])
+		}
+	}
+}
+
+func (fe *frontend) lineArg(arg string) (int, bool) {
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		fmt.Println("expected a line number")
+		return 0, false
+	}
+	return line, true
+}
+
+func (fe *frontend) showLine(n int) { fe.printLine(n, true) }
+
+func (fe *frontend) printLine(n int, current bool) {
+	if n < 1 || n > len(fe.lines) {
+		return
+	}
+	marker := "  "
+	if current {
+		marker = "=>"
+	}
+	fmt.Printf("%s %4d  %s\n", marker, n, fe.lines[n-1])
+}
+
+func printVariables(vars []vm.Variable) {
+	if len(vars) == 0 {
+		fmt.Println("(none)")
+	}
+	for _, v := range vars {
+		fmt.Printf("%s = %s\n", v.Name, v.Value.Inspect())
+	}
+}
@@ -6,14 +6,20 @@ import (
 	"io"
 	"os"
 
+	"mingo/internal/compiler"
 	"mingo/internal/lexer"
+	"mingo/internal/module"
 	"mingo/internal/parser"
 )
 
 type diag struct {
-	Msg    string `json:"msg"`
-	Line   int    `json:"line"`
-	Column int    `json:"column"`
+	Msg       string `json:"msg"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+	Severity  string `json:"severity"` // "error" or "warning"
+	Source    string `json:"source"`   // "parser", "module", "types" or "compiler"
 }
 
 func main() {
@@ -26,12 +32,57 @@ func main() {
 
 	l := lexer.New(string(src))
 	p := parser.New(l)
-	_ = p.ParseProgram()
+	program := p.ParseProgram()
 
 	rich := p.RichErrors()
 	out := make([]diag, 0, len(rich))
 	for _, e := range rich {
-		out = append(out, diag{Msg: e.Msg, Line: e.Pos.Line, Column: e.Pos.Column})
+		out = append(out, diag{Msg: e.Msg, Line: e.Pos.Line, Column: e.Pos.Column, Severity: "error", Source: "parser"})
+	}
+
+	add := func(d compiler.Diagnostic, source string) {
+		out = append(out, diag{
+			Msg:       d.Msg,
+			Line:      d.Pos.Line,
+			Column:    d.Pos.Column,
+			EndLine:   d.End.Line,
+			EndColumn: d.End.Column,
+			Severity:  d.Severity.String(),
+			Source:    source,
+		})
+	}
+
+	// Only a complete tree is worth checking and compiling. Imports are
+	// relative to the working directory, and errors in imported modules
+	// are reported at the imports.
+	if len(rich) == 0 {
+		mods, err := (&module.Loader{}).LoadProgram("", program)
+		if err != nil {
+			errs, ok := err.(module.ErrorList)
+			if !ok {
+				errs = module.ErrorList{{Msg: err.Error()}}
+			}
+			for _, e := range errs {
+				d := compiler.Diagnostic{Pos: e.Pos, Severity: compiler.SeverityError, Msg: e.Msg}
+				if e.Import != nil {
+					d.Pos, d.Msg = e.Import.Token.Pos, e.Error()
+				}
+				add(d, "module")
+			}
+		} else {
+			for _, d := range module.Check(mods) {
+				if d, ok := module.InMain(mods, d); ok {
+					add(d, "types")
+				}
+			}
+			comp := compiler.New()
+			_ = module.Compile(comp, mods)
+			for _, d := range comp.Diagnostics() {
+				if d, ok := module.InMain(mods, d); ok {
+					add(d, "compiler")
+				}
+			}
+		}
 	}
 
 	enc := json.NewEncoder(os.Stdout)
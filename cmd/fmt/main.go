@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"mingo/internal/format"
+)
+
+func main() {
+	write := flag.Bool("w", false, "write the result back to each file")
+	check := flag.Bool("check", false, "list files that are not formatted and exit 1 if there are any")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: fmt [-w | -check] [file.mg ...]")
+		fmt.Fprintln(os.Stderr, "With no files, formats stdin to stdout.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "fmt: -w needs file arguments")
+			os.Exit(2)
+		}
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		out, err := format.Source(string(b))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<stdin>:%v\n", err)
+			os.Exit(2)
+		}
+		if *check {
+			if out != string(b) {
+				fmt.Println("<stdin>")
+				os.Exit(1)
+			}
+			return
+		}
+		fmt.Print(out)
+		return
+	}
+
+	status := 0
+	for _, path := range flag.Args() {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			status = 2
+			continue
+		}
+		out, err := format.Source(string(b))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:%v\n", path, err)
+			status = 2
+			continue
+		}
+		switch {
+		case *check:
+			if out != string(b) {
+				fmt.Println(path)
+				status = max(status, 1)
+			}
+		case *write:
+			if out != string(b) {
+				if err := os.WriteFile(path, []byte(out), 0o644); err != nil {
+					fmt.Fprintf(os.Stderr, "error: %v\n", err)
+					status = 2
+				}
+			}
+		default:
+			fmt.Print(out)
+		}
+	}
+	os.Exit(status)
+}
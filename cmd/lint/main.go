@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"mingo/internal/lint"
+)
+
+// diag is the JSON format of cmd/diag, plus the file and the rule.
+type diag struct {
+	File      string `json:"file"`
+	Msg       string `json:"msg"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+	Severity  string `json:"severity"`
+	Source    string `json:"source"` // "parser" or "lint"
+	Rule      string `json:"rule,omitempty"`
+}
+
+const defaultConfig = ".mingolint.json"
+
+func main() {
+	configPath := flag.String("config", "", "rule config file (default "+defaultConfig+" if it exists)")
+	asJSON := flag.Bool("json", false, "print findings as a JSON array in the diag format")
+	listRules := flag.Bool("rules", false, "list the rules and exit")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lint [-config file] [-json] file.mg ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *listRules {
+		for _, r := range lint.Rules {
+			fmt.Printf("%-20s %s\n", r.ID, r.Doc)
+		}
+		return
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var cfg *lint.Config
+	path := *configPath
+	if path == "" {
+		if _, err := os.Stat(defaultConfig); err == nil {
+			path = defaultConfig
+		}
+	}
+	if path != "" {
+		var err error
+		if cfg, err = lint.LoadConfig(path, lint.Rules); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	status := 0
+	out := []diag{}
+	for _, file := range flag.Args() {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			status = 2
+			continue
+		}
+		diags, err := lint.Run(string(src), lint.Rules, cfg)
+		var parseErrs lint.ParseErrors
+		if errors.As(err, &parseErrs) {
+			status = 2
+			for _, e := range parseErrs {
+				out = append(out, diag{File: file, Msg: e.Msg, Line: e.Pos.Line, Column: e.Pos.Column, Severity: "error", Source: "parser"})
+			}
+		}
+		for _, d := range diags {
+			status = max(status, 1)
+			out = append(out, diag{
+				File:      file,
+				Msg:       d.Msg,
+				Line:      d.Pos.Line,
+				Column:    d.Pos.Column,
+				EndLine:   d.End.Line,
+				EndColumn: d.End.Column,
+				Severity:  "warning",
+				Source:    "lint",
+				Rule:      d.Rule,
+			})
+		}
+	}
+
+	if *asJSON {
+		if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+			fmt.Fprintf(os.Stderr, "encode error: %v\n", err)
+			os.Exit(2)
+		}
+	} else {
+		for _, d := range out {
+			if d.Rule != "" {
+				fmt.Printf("%s:%d:%d: %s (%s)\n", d.File, d.Line, d.Column, d.Msg, d.Rule)
+			} else {
+				fmt.Printf("%s:%d:%d: %s\n", d.File, d.Line, d.Column, d.Msg)
+			}
+		}
+	}
+	os.Exit(status)
+}
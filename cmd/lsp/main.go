@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"mingo/internal/lsp"
+)
+
+// lsp is a Language Server Protocol server on stdin/stdout.
+func main() {
+	if err := lsp.New(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "lsp:", err)
+		os.Exit(1)
+	}
+}
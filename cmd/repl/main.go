@@ -2,25 +2,42 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
 
+	"mingo/internal/ast"
 	"mingo/internal/lexer"
 	"mingo/internal/parser"
 )
 
 const PROMPT = "mg> "
 
+// jsonError is how -json reports a parse error.
+type jsonError struct {
+	Msg    string `json:"msg"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
 func main() {
+	asJSON := flag.Bool("json", false, "print each program as a JSON AST on one line, without prompts")
+	flag.Parse()
+
 	in := bufio.NewScanner(os.Stdin)
-	fmt.Println("Mingo parser REPL. Type code and press Enter. Ctrl+D to exit.")
+	if !*asJSON {
+		fmt.Println("Mingo parser REPL. Type code and press Enter. Ctrl+D to exit.")
+	}
 
 	var buf strings.Builder
 	indent := 0
 
 	for {
-		fmt.Print(PROMPT)
+		if !*asJSON {
+			fmt.Print(PROMPT)
+		}
 		if !in.Scan() {
 			break
 		}
@@ -47,7 +64,9 @@ func main() {
 		l := lexer.New(buf.String())
 		p := parser.New(l)
 		program := p.ParseProgram()
-		if len(p.Errors()) > 0 {
+		if *asJSON {
+			printJSON(program, p.RichErrors())
+		} else if len(p.Errors()) > 0 {
 			fmt.Println("parse errors:")
 			for _, e := range p.Errors() {
 				fmt.Println("  ", e)
@@ -58,3 +77,21 @@ func main() {
 		buf.Reset()
 	}
 }
+
+func printJSON(program *ast.Program, errs []parser.ParseError) {
+	var out []byte
+	if len(errs) > 0 {
+		list := make([]jsonError, len(errs))
+		for i, e := range errs {
+			list[i] = jsonError{Msg: e.Msg, Line: e.Pos.Line, Column: e.Pos.Column}
+		}
+		out, _ = json.Marshal(map[string]any{"errors": list})
+	} else {
+		var err error
+		if out, err = ast.JSON(program); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return
+		}
+	}
+	fmt.Println(string(out))
+}
@@ -2,57 +2,238 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"mingo/internal/compiler"
-	"mingo/internal/lexer"
-	"mingo/internal/parser"
+	"mingo/internal/cover"
+	"mingo/internal/eval"
+	"mingo/internal/module"
+	"mingo/internal/profile"
+	"mingo/internal/stdlib"
+	"mingo/internal/trace"
 	"mingo/internal/vm"
 )
 
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with the arguments args and returns its exit status:
+// 1 when a file cannot be read or written, 2 for bad usage, 3 when the
+// program does not parse or its imports do not load, 4 when it does not
+// type-check or compile, and 5 when it raises an error that is not caught.
+func run(args []string, stdin *os.File, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var caps stdlib.Capabilities
+	flags.StringVar(&caps.Root, "root", "", "directory the io module may use (default: no file access)")
+	flags.BoolVar(&caps.ReadOnly, "read-only", false, "let the io module read files under -root but not write them")
+	coverage := flags.Bool("cover", false, "print how much of the program ran to stderr")
+	coverProfile := flags.String("coverprofile", "", "write the program's coverage to this file")
+	format := flags.String("coverformat", "lcov", "format of -coverprofile: lcov or json")
+	profiling := flags.Bool("profile", false, "print where the program spent its time to stderr")
+	pprof := flags.String("pprof", "", "write a profile of the program for go tool pprof to this file")
+	topN := flags.Int("top", 10, "number of functions, lines and opcodes -profile lists")
+	interval := flags.Duration("sample-interval", time.Millisecond, "time between the call stack samples of a profile")
+	tracing := flags.Bool("trace", false, "log each instruction as a line of JSON to stderr")
+	traceFuncs := flags.String("trace-func", "", "trace only these functions, separated by commas (<main> is top-level code, <fn> function literals)")
+	traceLines := flags.String("trace-lines", "", "trace only instructions on these lines, as in 3-7, 3- or 3")
+	interpret := flags.Bool("eval", false, "run the program by walking its syntax tree instead of on the VM")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: mingo-run [flags] <file.mg | stdin>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	covering := *coverage || *coverProfile != ""
+	profiled := *profiling || *pprof != ""
+	if n := count(covering, profiled, *tracing, *interpret); n > 1 {
+		fmt.Fprintln(stderr, "error: coverage, profiling, tracing and -eval cannot be used together")
+		return 2
+	}
+	var filter trace.Filter
+	if *traceFuncs != "" {
+		filter.Functions = strings.Split(*traceFuncs, ",")
+	}
+	if *traceLines != "" {
+		var err error
+		if filter.FirstLine, filter.LastLine, err = lineRange(*traceLines); err != nil {
+			fmt.Fprintf(stderr, "error: -trace-lines: %v\n", err)
+			return 2
+		}
+	}
+
 	var input string
 
-	if len(os.Args) > 1 {
-		b, err := os.ReadFile(os.Args[1])
+	if flags.NArg() > 0 {
+		b, err := os.ReadFile(flags.Arg(0))
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
 		}
 		input = string(b)
 	} else {
-		stat, _ := os.Stdin.Stat()
+		stat, _ := stdin.Stat()
 		if (stat.Mode() & os.ModeCharDevice) == 0 {
-			scanner := bufio.NewScanner(os.Stdin)
+			scanner := bufio.NewScanner(stdin)
 			for scanner.Scan() {
 				input += scanner.Text() + "\n"
 			}
 		} else {
-			fmt.Println("Usage: mingo-run <file.mg | stdin>")
-			os.Exit(2)
+			flags.Usage()
+			return 2
 		}
 	}
 
-	l := lexer.New(input)
-	p := parser.New(l)
-	program := p.ParseProgram()
-	if len(p.Errors()) > 0 {
-		for _, e := range p.Errors() {
-			fmt.Fprintln(os.Stderr, e)
+	path := flags.Arg(0)
+	mods, err := (&module.Loader{}).Load(path, input)
+	if err != nil {
+		if errs, ok := err.(module.ErrorList); ok {
+			for _, e := range errs {
+				fmt.Fprintln(stderr, e)
+			}
+		} else {
+			fmt.Fprintln(stderr, err)
 		}
-		os.Exit(3)
+		return 3
+	}
+
+	if errs := module.Check(mods); len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintln(stderr, "type error:", e)
+		}
+		return 4
+	}
+
+	if *interpret {
+		in := eval.New()
+		in.SetOutput(stdout)
+		in.SetCapabilities(caps)
+		err := in.Run(mods)
+		if errs, ok := err.(compiler.ErrorList); ok {
+			for _, e := range errs {
+				fmt.Fprintln(stderr, "compile error:", e)
+			}
+			return 4
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "runtime error:", err)
+			return 5
+		}
+		return 0
 	}
 
 	comp := compiler.New()
-	if err := comp.Compile(program); err != nil {
-		fmt.Fprintln(os.Stderr, "compile error:", err)
-		os.Exit(4)
+	if err := module.Compile(comp, mods); err != nil {
+		if errs, ok := err.(compiler.ErrorList); ok {
+			for _, e := range errs {
+				fmt.Fprintln(stderr, "compile error:", e)
+			}
+		} else {
+			fmt.Fprintln(stderr, "compile error:", err)
+		}
+		return 4
 	}
 
-	machine := vm.New(comp.Instructions(), comp.Constants())
-	if err := machine.Run(); err != nil {
-		fmt.Fprintln(os.Stderr, "runtime error:", err)
-		os.Exit(5)
+	bc := comp.Bytecode()
+	machine := vm.NewFromBytecode(bc, nil)
+	machine.SetOutput(stdout)
+	machine.SetCapabilities(caps)
+	name := path
+	if name == "" {
+		name = "<stdin>"
+	}
+	var rec *cover.Recorder
+	var prof *profile.Profiler
+	switch {
+	case covering:
+		rec = cover.New(bc, name)
+		machine.SetHook(rec)
+		err = machine.Run()
+	case profiled:
+		prof = profile.New(name, *interval)
+		err = prof.Run(machine)
+	case *tracing:
+		err = trace.New(stderr, path, filter).Run(machine)
+	default:
+		err = machine.Run()
+	}
+	// the coverage or profile of a run that fails shows how far it got
+	if rec != nil {
+		p := rec.Profile()
+		if *coverage {
+			p.WriteText(stderr)
+		}
+		if *coverProfile != "" {
+			if err := p.WriteFile(*coverProfile, *format); err != nil {
+				fmt.Fprintf(stderr, "error: %v\n", err)
+				return 1
+			}
+		}
+	}
+	if prof != nil {
+		if *profiling {
+			prof.WriteText(stderr, *topN)
+		}
+		if *pprof != "" {
+			if err := writePprof(prof, *pprof); err != nil {
+				fmt.Fprintf(stderr, "error: %v\n", err)
+				return 1
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "runtime error:", err)
+		return 5
+	}
+	return 0
+}
+
+func writePprof(prof *profile.Profiler, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := prof.WritePprof(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func count(flags ...bool) int {
+	n := 0
+	for _, f := range flags {
+		if f {
+			n++
+		}
+	}
+	return n
+}
+
+// lineRange parses "first-last", "first-" or "line".
+func lineRange(s string) (first, last int, err error) {
+	from, to, isRange := strings.Cut(s, "-")
+	if first, err = strconv.Atoi(from); err != nil || first < 1 {
+		return 0, 0, fmt.Errorf("bad line %q", from)
+	}
+	switch {
+	case !isRange:
+		return first, first, nil
+	case to == "":
+		return first, 0, nil
+	}
+	if last, err = strconv.Atoi(to); err != nil || last < first {
+		return 0, 0, fmt.Errorf("bad last line %q", to)
 	}
+	return first, last, nil
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .out and .err files with what the programs print")
+
+// TestGolden runs the programs under testdata, except the modules in lib
+// directories, and the examples. Each must print to stdout what its .out
+// file holds, and to stderr what its .err file holds, where a run that
+// fails also ends with its exit status. A missing file stands for no
+// output.
+func TestGolden(t *testing.T) {
+	var files []string
+	err := filepath.WalkDir("testdata", func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case d.IsDir() && d.Name() == "lib":
+			return filepath.SkipDir
+		case filepath.Ext(path) == ".mg":
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	examples, _ := filepath.Glob("../../examples/*.mg")
+	if len(examples) == 0 {
+		t.Fatalf("no examples found")
+	}
+	for _, file := range examples {
+		// those are for bin/test
+		if !strings.HasSuffix(file, "_test.mg") {
+			files = append(files, file)
+		}
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimPrefix(file, "../../"), func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			if status := run([]string{file}, nil, &stdout, &stderr); status != 0 {
+				fmt.Fprintf(&stderr, "exit status %d\n", status)
+			}
+			base := strings.TrimSuffix(file, ".mg")
+			golden(t, base+".out", stdout.String())
+			golden(t, base+".err", stderr.String())
+		})
+	}
+}
+
+// golden compares got with the file path, or with -update writes it there.
+func golden(t *testing.T, path, got string) {
+	t.Helper()
+	if *update {
+		var err error
+		if got == "" {
+			if err = os.Remove(path); errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			err = os.WriteFile(path, []byte(got), 0o644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs (go test -update rewrites it).\nwant=%q\ngot=%q", path, want, got)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		args   []string
+		status int
+		stderr string
+	}{
+		{[]string{"testdata/missing.mg"}, 1, "error: open testdata/missing.mg: no such file or directory\n"},
+		{[]string{"-eval", "-trace", "testdata/try_catch.mg"}, 2, "error: coverage, profiling, tracing and -eval cannot be used together\n"},
+		{[]string{"-trace", "-trace-lines", "5-3", "testdata/try_catch.mg"}, 2, "error: -trace-lines: bad last line \"3\"\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		status := run(tt.args, nil, &stdout, &stderr)
+		if status != tt.status || stderr.String() != tt.stderr || stdout.Len() > 0 {
+			t.Errorf("%q: exit status %d, stdout %q, stderr %q; want %d, %q", tt.args, status, stdout.String(), stderr.String(), tt.status, tt.stderr)
+		}
+	}
+}
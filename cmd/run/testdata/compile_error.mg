@@ -0,0 +1,4 @@
+let total = 0;
+fn add(n) { total = total + n; }
+add(1);
+print(totl);
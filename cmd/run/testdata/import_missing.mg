@@ -0,0 +1,2 @@
+import "lib/missing.mg" as missing;
+print(missing.x);
@@ -0,0 +1,4 @@
+export fn positive(n) {
+  if (n < 0) { throw n; }
+  n
+}
@@ -0,0 +1,3 @@
+let x = 1;
+let = 2;
+print(x +);
@@ -0,0 +1,2 @@
+let greeting = "hello;
+print(greeting @ 1);
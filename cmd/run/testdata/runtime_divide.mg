@@ -0,0 +1,4 @@
+fn ratio(a, b) { a / b }
+print(ratio(6, 3));
+print(ratio(1, 0));
+print("not reached");
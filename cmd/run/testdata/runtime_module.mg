@@ -0,0 +1,3 @@
+import "lib/checks.mg" as checks;
+print(checks.positive(3));
+print(checks.positive(-3));
@@ -0,0 +1,2 @@
+fn down(n) { 1 + down(n + 1) }
+down(0);
@@ -0,0 +1,10 @@
+try {
+  throw "caught";
+} catch (e) {
+  print(e);
+}
+try {
+  [1, 2][5];
+} finally {
+  print("finally runs first");
+}
@@ -0,0 +1,13 @@
+import "strings" as strings;
+fn safe_div(a, b) {
+  try {
+    return a / b;
+  } catch (e) {
+    print(e);
+    return 0;
+  } finally {
+    print("done " + strings.format("{} / {}", a, b));
+  }
+}
+print(safe_div(7, 2));
+print(safe_div(1, 0));
@@ -0,0 +1,3 @@
+fn twice(n: int) -> int { n * 2 }
+let s: string = "a";
+print(twice(s));
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"mingo/internal/cover"
+	"mingo/internal/tester"
+)
+
+func main() {
+	run := flag.String("run", "", "run only the tests whose names match this regular expression")
+	verbose := flag.Bool("v", false, "list every test, and what the passing ones print")
+	coverage := flag.Bool("cover", false, "print how much of the modules the tests import they run")
+	profile := flag.String("coverprofile", "", "write the coverage of the modules the tests import to this file")
+	format := flag.String("coverformat", "lcov", "format of -coverprofile: lcov or json")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: test [flags] [file.mg | dir ...] (default .)")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	r := &tester.Runner{}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -run: %v\n", err)
+			os.Exit(2)
+		}
+		r.Run = re
+	}
+	if *coverage || *profile != "" {
+		r.Coverage = cover.Profile{}
+	}
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := tester.Find(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	if len(files) == 0 {
+		fmt.Println("no test files")
+		return
+	}
+
+	failed := false
+	for _, file := range files {
+		results, err := r.RunFile(file)
+		if err != nil {
+			fmt.Printf("FAIL\t%s\n", file)
+			fmt.Println(indent(err.Error()))
+			failed = true
+			continue
+		}
+		passed := 0
+		for _, res := range results {
+			if res.Err == nil {
+				passed++
+				if *verbose {
+					fmt.Printf("--- PASS: %s (%s)\n", res.Name, res.Pos)
+					if res.Output != "" {
+						fmt.Println(indent(strings.TrimSuffix(res.Output, "\n")))
+					}
+				}
+				continue
+			}
+			fmt.Printf("--- FAIL: %s (%s)\n", res.Name, res.Pos)
+			fmt.Println(indent(res.Err.Error()))
+			if res.Output != "" {
+				fmt.Println(indent("output:\n" + strings.TrimSuffix(res.Output, "\n")))
+			}
+		}
+		switch {
+		case passed < len(results):
+			fmt.Printf("FAIL\t%s\t%d passed, %d failed\n", file, passed, len(results)-passed)
+			failed = true
+		case len(results) == 0:
+			fmt.Printf("ok\t%s\t[no tests to run]\n", file)
+		default:
+			fmt.Printf("ok\t%s\t%d passed\n", file, passed)
+		}
+	}
+	if *coverage {
+		r.Coverage.WriteText(os.Stdout)
+	}
+	if *profile != "" {
+		if err := r.Coverage.WriteFile(*profile, *format); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func indent(s string) string {
+	return "    " + strings.ReplaceAll(s, "\n", "\n    ")
+}
@@ -9,8 +9,8 @@ import (
 	"mingo/internal/code"
 	"mingo/internal/compiler"
 	"mingo/internal/lexer"
-	"mingo/internal/object"
 	"mingo/internal/parser"
+	"mingo/internal/types"
 	"mingo/internal/vm"
 )
 
@@ -23,7 +23,7 @@ func main() {
 
 	sym := compiler.NewSymbolTable()
 	comp := compiler.NewWithState(sym, nil)
-	globals := make([]object.Object, vm.GlobalsSize)
+	globals := vm.NewGlobals()
 
 	// naive multi-line buffer for blocks
 	var buf strings.Builder
@@ -64,15 +64,31 @@ func main() {
 			continue
 		}
 
+		// names from earlier inputs are unknown to the checker, so it
+		// treats them as any
+		if errs := types.Check(program); len(errs) > 0 {
+			for _, e := range errs {
+				fmt.Println("type error:", e)
+			}
+			continue
+		}
+
 		// re-use compiler state
 		comp = compiler.NewWithState(sym, comp.Constants())
 		if err := comp.Compile(program); err != nil {
-			fmt.Println("compile error:", err)
+			if errs, ok := err.(compiler.ErrorList); ok {
+				for _, e := range errs {
+					fmt.Println("compile error:", e)
+				}
+			} else {
+				fmt.Println("compile error:", err)
+			}
 			continue
 		}
 
 		// Echo expressions: replace OpPop with OpPrint for top-level statements
-		patched := append([]byte(nil), comp.Instructions()...)
+		bytecode := comp.Bytecode()
+		patched := append([]byte(nil), bytecode.Instructions...)
 		for i := 0; i < len(patched); {
 			op := code.Opcode(patched[i])
 			def, _ := code.Lookup(op)
@@ -90,7 +106,8 @@ func main() {
 		}
 
 		// re-use VM globals across iterations
-		machine := vm.NewWithGlobals(patched, comp.Constants(), globals)
+		bytecode.Instructions = patched
+		machine := vm.NewFromBytecode(bytecode, globals)
 		if err := machine.Run(); err != nil {
 			fmt.Println("runtime error:", err)
 			continue
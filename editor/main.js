@@ -40,7 +40,10 @@ ipcMain.handle("run-mingo", async (event, source) => {
   }
 
   return new Promise((resolve) => {
-    const child = spawn(runner, [], { stdio: ["pipe", "pipe", "pipe"] });
+    // scripts get the same sandbox as fs-read and fs-write: the repo
+    const child = spawn(runner, ["-root", repoRoot], {
+      stdio: ["pipe", "pipe", "pipe"],
+    });
     let out = "";
     let err = "";
 
@@ -68,37 +71,170 @@ ipcMain.handle("run-mingo", async (event, source) => {
   });
 });
 
-// Optional: lightweight diagnostics by invoking Go parser via a tiny helper binary would be ideal,
-// but we can reuse the runner to just parse and return errors by using a special flag in future.
-// For now, keep the channel placeholder so the renderer can call it later without breaking.
-ipcMain.handle("mingo-diagnostics", async (_event, source) => {
+// Debug sessions: the renderer talks the Debug Adapter Protocol to bin/dap
+// through these channels; events are pushed back as "mingo-debug-event".
+let debugSession = null;
+
+// Both bin/dap and bin/lsp exchange Content-Length framed JSON messages.
+function writeFramed(stream, msg) {
+  const body = JSON.stringify(msg);
+  stream.write(`Content-Length: ${Buffer.byteLength(body)}\r\n\r\n${body}`);
+}
+
+function framedReader(onMessage) {
+  let buffer = Buffer.alloc(0);
+  return (chunk) => {
+    buffer = Buffer.concat([buffer, chunk]);
+    for (;;) {
+      const headerEnd = buffer.indexOf("\r\n\r\n");
+      if (headerEnd < 0) return;
+      const m = /Content-Length: (\d+)/i.exec(buffer.slice(0, headerEnd).toString());
+      const length = m ? Number(m[1]) : 0;
+      const start = headerEnd + 4;
+      if (buffer.length < start + length) return;
+      const msg = JSON.parse(buffer.slice(start, start + length).toString());
+      buffer = buffer.slice(start + length);
+      onMessage(msg);
+    }
+  };
+}
+
+function binPath(name) {
   const repoRoot = path.resolve(__dirname, "..");
-  const diagBin = path.join(
+  return path.join(
     repoRoot,
     "bin",
-    process.platform === "win32" ? "diag.exe" : "diag"
+    process.platform === "win32" ? `${name}.exe` : name
   );
-  if (!fs.existsSync(diagBin)) {
-    return { errors: [], missing: true };
+}
+
+function debugSend(command, args) {
+  const session = debugSession;
+  if (!session) return Promise.resolve({ success: false, message: "not debugging" });
+  const seq = ++session.seq;
+  writeFramed(session.child.stdin, { seq, type: "request", command, arguments: args });
+  return new Promise((resolve) => session.pending.set(seq, resolve));
+}
+
+function debugReceive(session, msg) {
+  if (msg.type === "response") {
+    const resolve = session.pending.get(msg.request_seq);
+    session.pending.delete(msg.request_seq);
+    if (resolve) resolve(msg);
+  } else if (msg.type === "event" && mainWindow) {
+    mainWindow.webContents.send("mingo-debug-event", msg);
   }
-  return new Promise((resolve) => {
-    const child = spawn(diagBin, [], { stdio: ["pipe", "pipe", "pipe"] });
-    let out = "";
-    let err = "";
-    child.stdout.on("data", (d) => (out += d.toString()));
-    child.stderr.on("data", (d) => (err += d.toString()));
-    child.on("close", () => {
-      try {
-        const parsed = JSON.parse(out || "[]");
-        resolve({ errors: parsed, err });
-      } catch (e) {
-        resolve({ errors: [], err: String(e) });
+}
+
+ipcMain.handle("mingo-debug-start", async (_event, source, lines) => {
+  const adapter = binPath("dap");
+  if (!fs.existsSync(adapter)) {
+    return {
+      ok: false,
+      error: `Mingo debug adapter not found at ${adapter}. Build it first (make build).`,
+    };
+  }
+  if (debugSession) await debugSend("disconnect", {});
+
+  const program = path.join(require("os").tmpdir(), `mingo-debug-${process.pid}.mg`);
+  fs.writeFileSync(program, source, "utf8");
+  const child = spawn(adapter, [], { stdio: ["pipe", "pipe", "pipe"] });
+  const session = { child, seq: 0, pending: new Map() };
+  debugSession = session;
+  child.stdout.on("data", framedReader((msg) => debugReceive(session, msg)));
+  child.on("close", () => {
+    for (const resolve of session.pending.values()) {
+      resolve({ success: false, message: "debug adapter exited" });
+    }
+    if (debugSession === session) debugSession = null;
+  });
+
+  await debugSend("initialize", { adapterID: "mingo", linesStartAt1: true });
+  const launched = await debugSend("launch", { program, stopOnEntry: false });
+  if (!launched.success) {
+    await debugSend("disconnect", {});
+    return { ok: false, error: launched.message };
+  }
+  await debugSend("setBreakpoints", {
+    source: { path: program },
+    breakpoints: lines.map((line) => ({ line })),
+  });
+  await debugSend("configurationDone", {});
+  return { ok: true, program };
+});
+
+ipcMain.handle("mingo-debug-request", async (_event, command, args) =>
+  debugSend(command, args)
+);
+
+// Language server: one bin/lsp process for the window, started on first
+// use. The renderer sends requests and notifications through these
+// channels; server notifications (diagnostics) come back as
+// "mingo-lsp-notification".
+let lspSession = null;
+
+function lspStart() {
+  if (lspSession) return lspSession;
+  const server = binPath("lsp");
+  if (!fs.existsSync(server)) return null;
+  const child = spawn(server, [], { stdio: ["pipe", "pipe", "pipe"] });
+  const session = { child, id: 0, pending: new Map() };
+  lspSession = session;
+  child.stdout.on(
+    "data",
+    framedReader((msg) => {
+      if (msg.id !== undefined && !msg.method) {
+        const resolve = session.pending.get(msg.id);
+        session.pending.delete(msg.id);
+        if (resolve) resolve(msg);
+      } else if (msg.method && mainWindow) {
+        mainWindow.webContents.send("mingo-lsp-notification", msg);
       }
-    });
-    child.on("error", (e) => resolve({ errors: [], err: e.message }));
-    child.stdin.write(source);
-    child.stdin.end();
+    })
+  );
+  child.on("close", () => {
+    for (const resolve of session.pending.values()) {
+      resolve({ error: { message: "language server exited" } });
+    }
+    if (lspSession === session) lspSession = null;
   });
+  child.on("error", () => {});
+  session.ready = lspCall(session, "initialize", {
+    processId: process.pid,
+    rootUri: null,
+    capabilities: {},
+  }).then(() => lspNotify(session, "initialized", {}));
+  return session;
+}
+
+function lspCall(session, method, params) {
+  const id = ++session.id;
+  writeFramed(session.child.stdin, { jsonrpc: "2.0", id, method, params });
+  return new Promise((resolve) => session.pending.set(id, resolve));
+}
+
+function lspNotify(session, method, params) {
+  writeFramed(session.child.stdin, { jsonrpc: "2.0", method, params });
+}
+
+ipcMain.handle("mingo-lsp-request", async (_event, method, params) => {
+  const session = lspStart();
+  if (!session) return { error: { message: "bin/lsp not found; run make build" } };
+  await session.ready;
+  return lspCall(session, method, params);
+});
+
+ipcMain.handle("mingo-lsp-notify", async (_event, method, params) => {
+  const session = lspStart();
+  if (!session) return { missing: true };
+  await session.ready;
+  lspNotify(session, method, params);
+  return {};
+});
+
+app.on("will-quit", () => {
+  if (lspSession) lspSession.child.kill();
+  if (debugSession) debugSession.child.kill();
 });
 
 // File explorer: list files and read/save
@@ -4,8 +4,14 @@ contextBridge.exposeInMainWorld("mingo", {
   run: async (source) => {
     return await ipcRenderer.invoke("run-mingo", source);
   },
-  diagnostics: async (source) => {
-    return await ipcRenderer.invoke("mingo-diagnostics", source);
+  lspRequest: async (method, params) => {
+    return await ipcRenderer.invoke("mingo-lsp-request", method, params);
+  },
+  lspNotify: async (method, params) => {
+    return await ipcRenderer.invoke("mingo-lsp-notify", method, params);
+  },
+  onLspNotification: (callback) => {
+    ipcRenderer.on("mingo-lsp-notification", (_event, msg) => callback(msg));
   },
   listExamples: async () => {
     return await ipcRenderer.invoke("mingo-list-examples");
@@ -22,4 +28,13 @@ contextBridge.exposeInMainWorld("mingo", {
   fsWrite: async (relPath, content) => {
     return await ipcRenderer.invoke("fs-write", relPath, content);
   },
+  debugStart: async (source, lines) => {
+    return await ipcRenderer.invoke("mingo-debug-start", source, lines);
+  },
+  debugRequest: async (command, args) => {
+    return await ipcRenderer.invoke("mingo-debug-request", command, args);
+  },
+  onDebugEvent: (callback) => {
+    ipcRenderer.on("mingo-debug-event", (_event, msg) => callback(msg));
+  },
 });
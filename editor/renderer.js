@@ -72,42 +72,56 @@ window.require(["vs/editor/editor.main"], function () {
     runBtn.click();
   });
 
-  // Completion provider: keywords, snippets, and identifiers from buffer
-  const keywords = [
-    "fn",
-    "let",
-    "if",
-    "else",
-    "return",
-    "true",
-    "false",
-    "while",
-    "print",
-  ];
-  const keywordSet = new Set(keywords);
+  // Language features come from bin/lsp (see main.js). The model is the
+  // one open document; every edit is sent as a full-text change.
+  const lspKinds = monaco.languages.CompletionItemKind;
+  const completionKinds = {
+    3: lspKinds.Function,
+    6: lspKinds.Variable,
+    14: lspKinds.Keyword,
+  };
+  const docUri = () => window.editor.getModel().uri.toString();
+  const toLspPosition = (position) => ({
+    line: position.lineNumber - 1,
+    character: position.column - 1,
+  });
+  const toMonacoRange = (r) =>
+    new monaco.Range(
+      r.start.line + 1,
+      r.start.character + 1,
+      r.end.line + 1,
+      r.end.character + 1
+    );
+
+  async function lspAt(method, model, position, extra = {}) {
+    const res = await window.mingo.lspRequest(method, {
+      textDocument: { uri: model.uri.toString() },
+      position: toLspPosition(position),
+      ...extra,
+    });
+    return res && !res.error ? res.result : null;
+  }
+
   monaco.languages.registerCompletionItemProvider("mingo", {
-    triggerCharacters: [" ", "(", ")", ",", ";", "\n"],
-    provideCompletionItems(model, position) {
-      const text = model.getValue();
-      const idRegex = /\b[a-zA-Z_][a-zA-Z0-9_]*\b/g;
-      const identifiers = new Set();
-      let m;
-      while ((m = idRegex.exec(text))) {
-        const w = m[0];
-        if (!keywordSet.has(w)) identifiers.add(w);
-      }
+    triggerCharacters: [" ", "(", ",", "\n"],
+    async provideCompletionItems(model, position) {
+      const word = model.getWordUntilPosition(position);
+      const range = new monaco.Range(
+        position.lineNumber,
+        word.startColumn,
+        position.lineNumber,
+        word.endColumn
+      );
+      const items = (await lspAt("textDocument/completion", model, position)) || [];
 
       /** @type {import('monaco-editor').languages.CompletionItem[]} */
-      const suggestions = [];
-
-      // Keywords
-      for (const k of keywords) {
-        suggestions.push({
-          label: k,
-          kind: monaco.languages.CompletionItemKind.Keyword,
-          insertText: k,
-        });
-      }
+      const suggestions = items.map((it) => ({
+        label: it.label,
+        kind: completionKinds[it.kind] ?? lspKinds.Text,
+        detail: it.detail,
+        insertText: it.label,
+        range,
+      }));
 
       // Snippets
       suggestions.push(
@@ -117,6 +131,7 @@ window.require(["vs/editor/editor.main"], function () {
           detail: "function",
           insertTextRules:
             monaco.languages.CompletionItemInsertTextRule.InsertAsSnippet,
+          range,
           insertText: "fn ${1:name}(${2:args}) {\n\t$0\n}",
         },
         {
@@ -125,6 +140,7 @@ window.require(["vs/editor/editor.main"], function () {
           detail: "if/else",
           insertTextRules:
             monaco.languages.CompletionItemInsertTextRule.InsertAsSnippet,
+          range,
           insertText: "if (${1:cond}) {\n\t$0\n} else {\n\t\n}",
         },
         {
@@ -133,30 +149,72 @@ window.require(["vs/editor/editor.main"], function () {
           detail: "while loop",
           insertTextRules:
             monaco.languages.CompletionItemInsertTextRule.InsertAsSnippet,
+          range,
           insertText: "while (${1:cond}) {\n\t$0\n}",
         },
         {
           label: "print",
           kind: monaco.languages.CompletionItemKind.Function,
+          range,
           insertText: "print(${1:expr});",
           insertTextRules:
             monaco.languages.CompletionItemInsertTextRule.InsertAsSnippet,
         }
       );
 
-      // Buffer identifiers
-      for (const id of identifiers) {
-        suggestions.push({
-          label: id,
-          kind: monaco.languages.CompletionItemKind.Variable,
-          insertText: id,
-        });
-      }
-
       return { suggestions };
     },
   });
 
+  monaco.languages.registerHoverProvider("mingo", {
+    async provideHover(model, position) {
+      const hover = await lspAt("textDocument/hover", model, position);
+      if (!hover) return null;
+      return {
+        range: hover.range ? toMonacoRange(hover.range) : undefined,
+        contents: [{ value: hover.contents.value }],
+      };
+    },
+  });
+
+  monaco.languages.registerDefinitionProvider("mingo", {
+    async provideDefinition(model, position) {
+      const loc = await lspAt("textDocument/definition", model, position);
+      return loc ? { uri: model.uri, range: toMonacoRange(loc.range) } : null;
+    },
+  });
+
+  monaco.languages.registerReferenceProvider("mingo", {
+    async provideReferences(model, position, context) {
+      const locs =
+        (await lspAt("textDocument/references", model, position, {
+          context: { includeDeclaration: context.includeDeclaration },
+        })) || [];
+      return locs.map((l) => ({ uri: model.uri, range: toMonacoRange(l.range) }));
+    },
+  });
+
+  monaco.languages.registerDocumentSymbolProvider("mingo", {
+    async provideDocumentSymbols(model) {
+      const res = await window.mingo.lspRequest("textDocument/documentSymbol", {
+        textDocument: { uri: model.uri.toString() },
+      });
+      const convert = (sym) => ({
+        name: sym.name,
+        detail: sym.detail || "",
+        kind:
+          sym.kind === 12
+            ? monaco.languages.SymbolKind.Function
+            : monaco.languages.SymbolKind.Variable,
+        tags: [],
+        range: toMonacoRange(sym.range),
+        selectionRange: toMonacoRange(sym.selectionRange),
+        children: (sym.children || []).map(convert),
+      });
+      return res && !res.error ? res.result.map(convert) : [];
+    },
+  });
+
   function appendConsole(text) {
     consoleEl.textContent += text;
     consoleEl.scrollTop = consoleEl.scrollHeight;
@@ -170,40 +228,67 @@ window.require(["vs/editor/editor.main"], function () {
 
   let diagTimer = null;
   let isRunning = false;
-  async function scheduleDiagnostics() {
+  let documentOpen = false;
+  function scheduleDiagnostics() {
     if (diagTimer) clearTimeout(diagTimer);
-    diagTimer = setTimeout(runDiagnostics, 250);
+    diagTimer = setTimeout(syncDocument, 150);
   }
 
-  async function runDiagnostics() {
-    const src = window.editor.getValue();
-    try {
-      const res = await window.mingo.diagnostics(src);
-      if (!res || res.missing || !Array.isArray(res.errors)) return;
-      const model = window.editor.getModel();
-      const markers = res.errors.map((e) => ({
-        severity: monaco.MarkerSeverity.Error,
-        message: e.msg || e.Msg || "Error",
-        startLineNumber: e.line || e.Line || 1,
-        startColumn: e.column || e.Column || 1,
-        endLineNumber: e.line || e.Line || 1,
-        endColumn: (e.column || e.Column || 1) + 1,
-      }));
-      monaco.editor.setModelMarkers(model, "mingo", markers);
-      if (!isRunning) {
-        if (markers.length > 0) {
-          statusEl.textContent = `Issues: ${markers.length}`;
-          statusEl.style.color = "#ff7777";
-        } else {
-          statusEl.textContent = "Ready";
-          statusEl.style.color = "";
-        }
-      }
-    } catch (_) {
-      // ignore
+  // syncDocument sends the text to the language server, which answers with
+  // a publishDiagnostics notification.
+  async function syncDocument() {
+    const model = window.editor.getModel();
+    const textDocument = { uri: docUri(), version: model.getVersionId() };
+    if (!documentOpen) {
+      documentOpen = true;
+      await window.mingo.lspNotify("textDocument/didOpen", {
+        textDocument: { ...textDocument, languageId: "mingo", text: model.getValue() },
+      });
+    } else {
+      await window.mingo.lspNotify("textDocument/didChange", {
+        textDocument,
+        contentChanges: [{ text: model.getValue() }],
+      });
     }
   }
 
+  const lspSeverities = {
+    1: monaco.MarkerSeverity.Error,
+    2: monaco.MarkerSeverity.Warning,
+    3: monaco.MarkerSeverity.Info,
+    4: monaco.MarkerSeverity.Hint,
+  };
+  window.mingo.onLspNotification((msg) => {
+    if (msg.method !== "textDocument/publishDiagnostics") return;
+    const model = window.editor.getModel();
+    if (msg.params.uri !== docUri()) return;
+    const markers = msg.params.diagnostics.map((d) => {
+      const r = toMonacoRange(d.range);
+      return {
+        severity: lspSeverities[d.severity] ?? monaco.MarkerSeverity.Error,
+        message: d.message,
+        source: d.source,
+        startLineNumber: r.startLineNumber,
+        startColumn: r.startColumn,
+        endLineNumber: r.endLineNumber,
+        endColumn: r.endColumn,
+      };
+    });
+    monaco.editor.setModelMarkers(model, "mingo", markers);
+    if (!isRunning) {
+      const errors = markers.filter(
+        (m) => m.severity === monaco.MarkerSeverity.Error
+      ).length;
+      if (markers.length > 0) {
+        statusEl.textContent = `Issues: ${markers.length}`;
+        statusEl.style.color = errors > 0 ? "#ff7777" : "#ffcc66";
+      } else {
+        statusEl.textContent = "Ready";
+        statusEl.style.color = "";
+      }
+    }
+  });
+
   // Kick initial diagnostics
   scheduleDiagnostics();
 
@@ -385,62 +470,23 @@ window.require(["vs/editor/editor.main"], function () {
     consoleEl.textContent = "";
   });
 
-  // Simple formatter: normalizes spaces, ensures semicolons/newlines and braces indentation
-  function formatMingo(src) {
-    const lines = [];
-    const tokens = src
-      .replace(/\t/g, "  ")
-      .replace(/\s+/g, (m) => (m.includes("\n") ? "\n" : " "))
-      .split(/(\{|\}|\(|\)|;|,)/);
-
-    let out = [];
-    let indent = 0;
-    const pushLine = (s = "") =>
-      lines.push("  ".repeat(Math.max(indent, 0)) + s.trim());
-
-    for (let i = 0; i < tokens.length; i++) {
-      const t = tokens[i];
-      if (!t) continue;
-      if (t === "{") {
-        const current = out.join(" ").trim();
-        if (current) pushLine(current + " ");
-        pushLine("{");
-        out = [];
-        indent++;
-      } else if (t === "}") {
-        const current = out.join(" ").trim();
-        if (current) {
-          pushLine(current + (current.endsWith(";") ? "" : ";"));
-          out = [];
-        }
-        indent--;
-        pushLine("}");
-      } else if (t === ";") {
-        const current = out.join(" ").trim();
-        pushLine(current + ";");
-        out = [];
-      } else if (t === ",") {
-        out.push(",");
-      } else if (t.match(/^\s*\n\s*$/)) {
-        // collapse stray newlines by closing current buffer
-        const current = out.join(" ").trim();
-        if (current) {
-          pushLine(current);
-          out = [];
-        }
-      } else {
-        out.push(t.trim());
-      }
-    }
-    const tail = out.join(" ").trim();
-    if (tail) pushLine(tail + (tail.endsWith(";") ? "" : ";"));
-    return lines.join("\n").replace(/\n{3,}/g, "\n\n");
-  }
+  // Format Document (Shift+Alt+F) asks bin/lsp for the canonical form;
+  // the pending text is synced first so the server formats what is shown.
+  monaco.languages.registerDocumentFormattingEditProvider("mingo", {
+    async provideDocumentFormattingEdits(model, options) {
+      if (diagTimer) clearTimeout(diagTimer);
+      await syncDocument();
+      const res = await window.mingo.lspRequest("textDocument/formatting", {
+        textDocument: { uri: model.uri.toString() },
+        options: { tabSize: options.tabSize, insertSpaces: options.insertSpaces },
+      });
+      const edits = res && !res.error ? res.result : [];
+      return edits.map((e) => ({ range: toMonacoRange(e.range), text: e.newText }));
+    },
+  });
 
   function doFormat() {
-    const src = window.editor.getValue();
-    const formatted = formatMingo(src);
-    window.editor.setValue(formatted);
+    window.editor.getAction("editor.action.formatDocument").run();
   }
 
   formatBtn.addEventListener("click", doFormat);
@@ -449,32 +495,6 @@ window.require(["vs/editor/editor.main"], function () {
     doFormat
   );
 
-  // Hover provider to show diagnostic messages on hover
-  monaco.languages.registerHoverProvider("mingo", {
-    provideHover(model, position) {
-      const markers = monaco.editor.getModelMarkers({ resource: model.uri });
-      for (const m of markers) {
-        const inLine =
-          position.lineNumber >= m.startLineNumber &&
-          position.lineNumber <= m.endLineNumber;
-        const inCol =
-          position.column >= m.startColumn && position.column <= m.endColumn;
-        if (inLine && inCol) {
-          return {
-            range: new monaco.Range(
-              m.startLineNumber,
-              m.startColumn,
-              m.endLineNumber,
-              m.endColumn
-            ),
-            contents: [{ value: `Error: ${m.message}` }],
-          };
-        }
-      }
-      return null;
-    },
-  });
-
   // Quick fixes: insert missing semicolons when diagnostic suggests it
   monaco.languages.registerCodeActionProvider("mingo", {
     providedCodeActionKinds: [monaco.languages.CodeActionKind.QuickFix],
@@ -511,7 +531,175 @@ window.require(["vs/editor/editor.main"], function () {
     },
   });
 
-  // Placeholder hooks for future features (autocomplete, debugging)
-  // monaco.languages.registerCompletionItemProvider('mingo', { ... })
-  // Debugging can be integrated using breakpoints overlay + IPC to a debug vm
+  // Debugging: gutter breakpoints and a Debug Adapter Protocol session
+  // with bin/dap (see main.js)
+  const debugBtn = document.getElementById("debugBtn");
+  const stepButtons = {
+    continue: document.getElementById("continueBtn"),
+    next: document.getElementById("nextBtn"),
+    stepIn: document.getElementById("stepInBtn"),
+    stepOut: document.getElementById("stepOutBtn"),
+  };
+  const stopBtn = document.getElementById("stopBtn");
+  let breakpoints = []; // decoration ids; they follow their lines on edits
+  let debugProgram = null;
+  let debugging = false;
+  let currentLine = [];
+
+  window.editor.updateOptions({ glyphMargin: true });
+
+  function breakpointLine(id) {
+    const range = window.editor.getModel().getDecorationRange(id);
+    return range ? range.startLineNumber : 0;
+  }
+
+  function breakpointLines() {
+    return [...new Set(breakpoints.map(breakpointLine))]
+      .filter((l) => l > 0)
+      .sort((a, b) => a - b);
+  }
+
+  function toggleBreakpoint(line) {
+    const existing = breakpoints.filter((id) => breakpointLine(id) === line);
+    if (existing.length > 0) {
+      window.editor.deltaDecorations(existing, []);
+      breakpoints = breakpoints.filter((id) => !existing.includes(id));
+    } else {
+      const [id] = window.editor.deltaDecorations(
+        [],
+        [
+          {
+            range: new monaco.Range(line, 1, line, 1),
+            options: {
+              glyphMarginClassName: "mingo-breakpoint",
+              stickiness:
+                monaco.editor.TrackedRangeStickiness.NeverGrowsWhenTypingAtEdges,
+            },
+          },
+        ]
+      );
+      breakpoints.push(id);
+    }
+    if (debugging) {
+      window.mingo.debugRequest("setBreakpoints", {
+        source: { path: debugProgram },
+        breakpoints: breakpointLines().map((l) => ({ line: l })),
+      });
+    }
+  }
+
+  window.editor.onMouseDown((e) => {
+    if (
+      e.target.type === monaco.editor.MouseTargetType.GUTTER_GLYPH_MARGIN ||
+      e.target.type === monaco.editor.MouseTargetType.GUTTER_LINE_NUMBERS
+    ) {
+      toggleBreakpoint(e.target.position.lineNumber);
+    }
+  });
+  window.editor.addCommand(monaco.KeyCode.F9, () => {
+    toggleBreakpoint(window.editor.getPosition().lineNumber);
+  });
+
+  function setPaused(paused) {
+    for (const btn of Object.values(stepButtons)) btn.disabled = !paused;
+    stopBtn.disabled = !debugging;
+    debugBtn.disabled = debugging;
+    if (!paused) currentLine = window.editor.deltaDecorations(currentLine, []);
+  }
+
+  async function showStop(reason) {
+    const trace = await window.mingo.debugRequest("stackTrace", { threadId: 1 });
+    if (!trace.success) return;
+    const frames = trace.body.stackFrames;
+    const top = frames[0];
+    currentLine = window.editor.deltaDecorations(currentLine, [
+      {
+        range: new monaco.Range(top.line, 1, top.line, 1),
+        options: { isWholeLine: true, className: "mingo-current-line" },
+      },
+    ]);
+    window.editor.revealLineInCenter(top.line);
+    statusEl.textContent = `Paused (${reason}) at line ${top.line}`;
+
+    let text = `-- ${reason} at line ${top.line} in ${top.name}\n`;
+    const scopes = await window.mingo.debugRequest("scopes", { frameId: top.id });
+    for (const scope of scopes.success ? scopes.body.scopes : []) {
+      const vars = await window.mingo.debugRequest("variables", {
+        variablesReference: scope.variablesReference,
+      });
+      if (!vars.success) continue;
+      const shown = vars.body.variables.map((v) => `${v.name} = ${v.value}`);
+      text += `   ${scope.name}: ${shown.join(", ") || "(none)"}\n`;
+    }
+    if (frames.length > 1) {
+      text += `   stack: ${frames.map((f) => `${f.name}:${f.line}`).join(" <- ")}\n`;
+    }
+    appendConsole(text);
+  }
+
+  window.mingo.onDebugEvent((msg) => {
+    switch (msg.event) {
+      case "stopped":
+        setPaused(true);
+        showStop(msg.body.reason);
+        break;
+      case "output":
+        appendConsole(msg.body.output);
+        break;
+      case "exited":
+        statusEl.textContent = `Exit ${msg.body.exitCode}`;
+        break;
+      case "terminated":
+        debugging = false;
+        setPaused(false);
+        window.mingo.debugRequest("disconnect", {});
+        break;
+    }
+  });
+
+  debugBtn.addEventListener("click", async () => {
+    if (debugging) return;
+    consoleEl.textContent = "";
+    statusEl.textContent = "Debugging...";
+    const res = await window.mingo.debugStart(
+      window.editor.getValue(),
+      breakpointLines()
+    );
+    if (!res.ok) {
+      appendConsole((res.error || "debug session failed") + "\n");
+      statusEl.textContent = "Error";
+      return;
+    }
+    debugProgram = res.program;
+    debugging = true;
+    setPaused(false);
+  });
+
+  for (const [command, btn] of Object.entries(stepButtons)) {
+    btn.addEventListener("click", () => {
+      setPaused(false);
+      statusEl.textContent = "Running...";
+      window.mingo.debugRequest(command, { threadId: 1 });
+    });
+  }
+  stopBtn.addEventListener("click", () => {
+    window.mingo.debugRequest("terminate", {});
+  });
+
+  window.editor.addCommand(monaco.KeyCode.F5, () => {
+    if (!debugging) debugBtn.click();
+    else if (!stepButtons.continue.disabled) stepButtons.continue.click();
+  });
+  window.editor.addCommand(monaco.KeyCode.F10, () => {
+    if (!stepButtons.next.disabled) stepButtons.next.click();
+  });
+  window.editor.addCommand(monaco.KeyCode.F11, () => {
+    if (!stepButtons.stepIn.disabled) stepButtons.stepIn.click();
+  });
+  window.editor.addCommand(monaco.KeyMod.Shift | monaco.KeyCode.F11, () => {
+    if (!stepButtons.stepOut.disabled) stepButtons.stepOut.click();
+  });
+  window.editor.addCommand(monaco.KeyMod.Shift | monaco.KeyCode.F5, () => {
+    if (debugging) stopBtn.click();
+  });
 });
@@ -0,0 +1,12 @@
+fn safe_div(a, b) {
+  try {
+    return a / b;
+  } catch (e) {
+    print(e);
+  } finally {
+    print(0);
+  }
+  -1;
+}
+print(safe_div(10, 2));
+print(safe_div(1, 0));
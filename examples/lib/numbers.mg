@@ -0,0 +1,15 @@
+// Helpers shared by the examples; imported by modules.mg.
+
+export fn square(x: int) -> int {
+  x * x;
+}
+
+export fn max(a: int, b: int) -> int {
+  if (a > b) {
+    a;
+  } else {
+    b;
+  }
+}
+
+export let zero = 0;
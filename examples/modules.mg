@@ -0,0 +1,5 @@
+// Run with: ./bin/run examples/modules.mg
+import "lib/numbers.mg" as num;
+
+print(num.square(7));
+print(num.max(num.zero, -3));
@@ -0,0 +1,20 @@
+// Tests for lib/numbers.mg; run them with bin/test examples.
+import "testing" as t;
+import "lib/numbers.mg" as num;
+
+fn test_square() {
+  t.assert_eq(num.square(7), 49);
+  t.assert_eq(num.square(-3), 9);
+}
+
+fn test_max() {
+  t.assert_eq(num.max(2, 5), 5);
+  t.assert_eq(num.max(5, 2), 5);
+  t.assert(num.max(num.zero, -1) == num.zero, "max of zero and -1 is zero");
+}
+
+fn test_square_needs_an_int() {
+  t.assert_error(fn() {
+    num.square(true);
+  }, "TypeError");
+}
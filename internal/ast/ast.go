@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"strings"
+	"unicode/utf8"
 
 	"mingo/internal/token"
 )
@@ -10,6 +11,9 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos reports the position of the node's token: the keyword of a
+	// statement, the operator of an infix expression, '(' of a call.
+	Pos() token.Position
 }
 
 type Statement interface {
@@ -22,6 +26,38 @@ type Expression interface {
 	expressionNode()
 }
 
+// Start returns the position of the first token of n, which Pos does not
+// report for nodes whose token is in their middle: an infix expression, a
+// call, an index, a selector or an assignment.
+func Start(n Node) token.Position {
+	switch n := n.(type) {
+	case *InfixExpression:
+		return Start(n.Left)
+	case *CallExpression:
+		return Start(n.Function)
+	case *IndexExpression:
+		return Start(n.Left)
+	case *SelectorExpression:
+		return Start(n.X)
+	case *AssignmentStatement:
+		return n.Name.Pos()
+	case *ExpressionStatement:
+		return Start(n.Expression)
+	}
+	return n.Pos()
+}
+
+// End returns the position just past tok, for reports that span a single
+// token such as an identifier or an operator.
+func End(tok token.Token) token.Position {
+	return token.Position{
+		File:   tok.Pos.File,
+		Line:   tok.Pos.Line,
+		Column: tok.Pos.Column + utf8.RuneCountInString(tok.Literal),
+		Offset: tok.Pos.Offset + len(tok.Literal),
+	}
+}
+
 type Program struct {
 	Statements []Statement
 }
@@ -33,6 +69,13 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -44,11 +87,18 @@ func (p *Program) String() string {
 type Identifier struct {
 	Token token.Token // token.IDENT
 	Value string
+	Type  TypeExpr // annotation of a let name or parameter, or nil
 }
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
-func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) String() string {
+	if i.Type != nil {
+		return i.Value + ": " + i.Type.String()
+	}
+	return i.Value
+}
 
 type LetStatement struct {
 	Token token.Token // token.LET
@@ -58,6 +108,7 @@ type LetStatement struct {
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
@@ -77,6 +128,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(rs.TokenLiteral() + " ")
@@ -94,11 +146,16 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
 func (es *ExpressionStatement) String() string {
-	if es.Expression != nil {
-		return es.Expression.String()
+	if es.Expression == nil {
+		return ""
 	}
-	return ""
+	// fn at the start of a statement would begin a function statement
+	if _, ok := es.Expression.(*FunctionLiteral); ok {
+		return "(" + es.Expression.String() + ");"
+	}
+	return es.Expression.String() + ";"
 }
 
 // AssignmentStatement: identifier re-assignment: x = expr;
@@ -110,6 +167,7 @@ type AssignmentStatement struct {
 
 func (as *AssignmentStatement) statementNode()       {}
 func (as *AssignmentStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignmentStatement) Pos() token.Position  { return as.Token.Pos }
 func (as *AssignmentStatement) String() string {
 	var out strings.Builder
 	out.WriteString(as.Name.String())
@@ -129,6 +187,7 @@ type PrintStatement struct {
 
 func (ps *PrintStatement) statementNode()       {}
 func (ps *PrintStatement) TokenLiteral() string { return ps.Token.Literal }
+func (ps *PrintStatement) Pos() token.Position  { return ps.Token.Pos }
 func (ps *PrintStatement) String() string {
 	var out strings.Builder
 	out.WriteString(ps.TokenLiteral())
@@ -147,8 +206,54 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// StringLiteral is a string: "text". Value is unescaped.
+type StringLiteral struct {
+	Token token.Token // STRING
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) String() string       { return token.Quote(sl.Value) }
+
+// ArrayLiteral is a list of values: [a, b, c]
+type ArrayLiteral struct {
+	Token    token.Token // [
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) String() string {
+	elems := make([]string, len(al.Elements))
+	for i, e := range al.Elements {
+		elems[i] = e.String()
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+// IndexExpression is an element of an array: a[i]
+type IndexExpression struct {
+	Token token.Token // [
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IndexExpression) String() string {
+	if _, ok := ie.Left.(*FunctionLiteral); ok {
+		return "(" + ie.Left.String() + ")[" + ie.Index.String() + "]"
+	}
+	return ie.Left.String() + "[" + ie.Index.String() + "]"
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -156,6 +261,7 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type PrefixExpression struct {
@@ -166,6 +272,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -184,6 +291,7 @@ type InfixExpression struct {
 
 func (oe *InfixExpression) expressionNode()      {}
 func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
+func (oe *InfixExpression) Pos() token.Position  { return oe.Token.Pos }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -197,16 +305,21 @@ func (oe *InfixExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token // LBRACE
 	Statements []Statement
+	Rbrace     token.Position // closing brace, or EOF when it is missing
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
-	var out strings.Builder
+	if len(bs.Statements) == 0 {
+		return "{}"
+	}
+	stmts := make([]string, 0, len(bs.Statements))
 	for _, s := range bs.Statements {
-		out.WriteString(s.String())
+		stmts = append(stmts, s.String())
 	}
-	return out.String()
+	return "{ " + strings.Join(stmts, " ") + " }"
 }
 
 type IfExpression struct {
@@ -218,11 +331,12 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IfExpression) String() string {
 	var out strings.Builder
-	out.WriteString("if ")
+	out.WriteString("if (")
 	out.WriteString(ie.Condition.String())
-	out.WriteString(" ")
+	out.WriteString(") ")
 	out.WriteString(ie.Consequence.String())
 	if ie.Alternative != nil {
 		out.WriteString(" else ")
@@ -239,11 +353,12 @@ type WhileStatement struct {
 
 func (ws *WhileStatement) statementNode()       {}
 func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
 func (ws *WhileStatement) String() string {
 	var out strings.Builder
-	out.WriteString("while ")
+	out.WriteString("while (")
 	out.WriteString(ws.Condition.String())
-	out.WriteString(" ")
+	out.WriteString(") ")
 	out.WriteString(ws.Body.String())
 	return out.String()
 }
@@ -251,11 +366,13 @@ func (ws *WhileStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token // FN
 	Parameters []*Identifier
+	ReturnType TypeExpr // nil when not annotated
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var out strings.Builder
 	out.WriteString(fl.TokenLiteral())
@@ -266,6 +383,9 @@ func (fl *FunctionLiteral) String() string {
 	}
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 	return out.String()
 }
@@ -275,11 +395,13 @@ type FunctionStatement struct {
 	Token      token.Token // FN
 	Name       *Identifier
 	Parameters []*Identifier
+	ReturnType TypeExpr // nil when not annotated
 	Body       *BlockStatement
 }
 
 func (fs *FunctionStatement) statementNode()       {}
 func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *FunctionStatement) Pos() token.Position  { return fs.Token.Pos }
 func (fs *FunctionStatement) String() string {
 	var out strings.Builder
 	out.WriteString(fs.TokenLiteral())
@@ -292,6 +414,9 @@ func (fs *FunctionStatement) String() string {
 	}
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fs.ReturnType != nil {
+		out.WriteString("-> " + fs.ReturnType.String() + " ")
+	}
 	out.WriteString(fs.Body.String())
 	return out.String()
 }
@@ -304,15 +429,152 @@ type CallExpression struct {
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Token.Pos }
 func (ce *CallExpression) String() string {
 	var out strings.Builder
 	args := make([]string, 0, len(ce.Arguments))
 	for _, a := range ce.Arguments {
 		args = append(args, a.String())
 	}
-	out.WriteString(ce.Function.String())
+	if _, ok := ce.Function.(*FunctionLiteral); ok {
+		out.WriteString("(" + ce.Function.String() + ")")
+	} else {
+		out.WriteString(ce.Function.String())
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
 	return out.String()
 }
+
+// ThrowStatement raises a value as an error: throw expr;
+type ThrowStatement struct {
+	Token token.Token // THROW
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *ThrowStatement) String() string {
+	var out strings.Builder
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// TryStatement: try { ... } catch (e) { ... } finally { ... }
+// At least one of CatchBlock and FinallyBlock is set; CatchParam is optional.
+type TryStatement struct {
+	Token        token.Token // TRY
+	Block        *BlockStatement
+	CatchParam   *Identifier
+	CatchBlock   *BlockStatement
+	FinallyBlock *BlockStatement
+}
+
+func (ts *TryStatement) statementNode()       {}
+func (ts *TryStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TryStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *TryStatement) String() string {
+	var out strings.Builder
+	out.WriteString("try ")
+	out.WriteString(ts.Block.String())
+	if ts.CatchBlock != nil {
+		out.WriteString(" catch ")
+		if ts.CatchParam != nil {
+			out.WriteString("(" + ts.CatchParam.String() + ") ")
+		}
+		out.WriteString(ts.CatchBlock.String())
+	}
+	if ts.FinallyBlock != nil {
+		out.WriteString(" finally ")
+		out.WriteString(ts.FinallyBlock.String())
+	}
+	return out.String()
+}
+
+// ImportStatement: import "path/to/lib.mg" as lib;
+type ImportStatement struct {
+	Token token.Token // IMPORT
+	Path  string      // as written, relative to the importing file
+	Alias *Identifier
+
+	// Resolved is the module's file path, set by the module loader.
+	Resolved string
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Pos }
+func (is *ImportStatement) String() string {
+	return "import " + token.Quote(is.Path) + " as " + is.Alias.String() + ";"
+}
+
+// ExportStatement makes the name a let or fn statement at the top level
+// of a module defines visible to importers: export fn f() { ... }
+type ExportStatement struct {
+	Token     token.Token // EXPORT
+	Statement Statement   // *LetStatement or *FunctionStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExportStatement) String() string       { return "export " + es.Statement.String() }
+
+// SelectorExpression names an exported member of an imported module:
+// lib.name
+type SelectorExpression struct {
+	Token token.Token // DOT
+	X     Expression  // the module's import name
+	Sel   *Identifier
+}
+
+func (se *SelectorExpression) expressionNode()      {}
+func (se *SelectorExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectorExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SelectorExpression) String() string       { return se.X.String() + "." + se.Sel.String() }
+
+// TypeExpr is a type annotation: a name such as int, or a function type.
+type TypeExpr interface {
+	Node
+	typeNode()
+}
+
+// NamedType is a type written as a name: int, bool or any.
+type NamedType struct {
+	Token token.Token // IDENT
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) Pos() token.Position  { return nt.Token.Pos }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// FunctionType is the type of a function: fn(int, bool) -> int. A missing
+// result type is any.
+type FunctionType struct {
+	Token  token.Token // FN
+	Params []TypeExpr
+	Result TypeExpr
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) Pos() token.Position  { return ft.Token.Pos }
+func (ft *FunctionType) String() string {
+	params := make([]string, 0, len(ft.Params))
+	for _, p := range ft.Params {
+		params = append(params, p.String())
+	}
+	s := "fn(" + strings.Join(params, ", ") + ")"
+	if ft.Result != nil {
+		s += " -> " + ft.Result.String()
+	}
+	return s
+}
@@ -0,0 +1,157 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"mingo/internal/ast"
+	"mingo/internal/lexer"
+	"mingo/internal/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%q: parse errors: %v", input, p.Errors())
+	}
+	return program
+}
+
+// shape is a program's JSON tree without positions, which change when the
+// program is printed differently.
+func shape(t *testing.T, program *ast.Program) any {
+	t.Helper()
+	b, err := ast.JSON(program)
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	var tree any
+	if err := json.Unmarshal(b, &tree); err != nil {
+		t.Fatalf("bad JSON %s: %v", b, err)
+	}
+	var strip func(v any)
+	strip = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			delete(v, "pos")
+			delete(v, "rbrace")
+			for _, child := range v {
+				strip(child)
+			}
+		case []any:
+			for _, child := range v {
+				strip(child)
+			}
+		}
+	}
+	strip(tree)
+	return tree
+}
+
+// Printing a program and parsing the result gives the same tree.
+func TestStringRoundTrip(t *testing.T) {
+	inputs := []string{
+		"if (x) { 1; } else { 2; } let y = if (x) { 1; };",
+		"while (i < 3) { i = i + 1; } while (true) {}",
+		"fn f(a, b) { return a * (b - 1); } f(1, 2);",
+		"(fn(x) { x; })(1); (fn() {}); let g = fn() { (fn() {}); };",
+		"-(-1); !!true; (a + b)(c); -f(x) * 2 - 3 - (4 - 5);",
+		"try { throw 1; } catch (e) { print(e); } finally { print(2); } try {} catch {}",
+		"if (x) { 1; } -1; if (y) {} (2);",
+		`import "a\\b \"c\".mg" as c; export let d = c.e.f(1).g; export fn h() {}`,
+		"let n: int = 1; fn f(a: int, g: fn(int) -> bool) -> bool { g(a); } let h = fn(b: any) -> fn() { (fn() {}); };",
+		`let s: string = "𝒳\\n"; [1, [], ["a"]][0][f(1)]; (fn() {})[0]; -xs[1]; let t: array = [a + b];`,
+	}
+	files, _ := filepath.Glob("../../examples/*.mg")
+	if len(files) == 0 {
+		t.Fatalf("no examples found")
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, string(src))
+	}
+
+	for _, input := range inputs {
+		program := parse(t, input)
+		printed := program.String()
+		reparsed := parse(t, printed)
+		if !reflect.DeepEqual(shape(t, program), shape(t, reparsed)) {
+			t.Fatalf("%q printed as %q, which parses differently", input, printed)
+		}
+		if again := reparsed.String(); again != printed {
+			t.Fatalf("printing is not stable.\nfirst=%q\nsecond=%q", printed, again)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	program := parse(t, "let x = -1;\nif (x) { f(x); }")
+	b, err := ast.JSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"kind":"Program","statements":[` +
+		`{"kind":"LetStatement","pos":{"line":1,"column":1,"offset":0},` +
+		`"name":{"kind":"Identifier","pos":{"line":1,"column":5,"offset":4},"value":"x"},` +
+		`"value":{"kind":"PrefixExpression","pos":{"line":1,"column":9,"offset":8},"operator":"-",` +
+		`"right":{"kind":"IntegerLiteral","pos":{"line":1,"column":10,"offset":9},"value":1,"literal":"1"}}},` +
+		`{"kind":"ExpressionStatement","pos":{"line":2,"column":1,"offset":12},` +
+		`"expression":{"kind":"IfExpression","pos":{"line":2,"column":1,"offset":12},` +
+		`"condition":{"kind":"Identifier","pos":{"line":2,"column":5,"offset":16},"value":"x"},` +
+		`"consequence":{"kind":"BlockStatement","pos":{"line":2,"column":8,"offset":19},"statements":[` +
+		`{"kind":"ExpressionStatement","pos":{"line":2,"column":10,"offset":21},` +
+		`"expression":{"kind":"CallExpression","pos":{"line":2,"column":11,"offset":22},` +
+		`"function":{"kind":"Identifier","pos":{"line":2,"column":10,"offset":21},"value":"f"},` +
+		`"arguments":[{"kind":"Identifier","pos":{"line":2,"column":12,"offset":23},"value":"x"}]}}],` +
+		`"rbrace":{"line":2,"column":16,"offset":27}},` +
+		`"alternative":null}}]}`
+	if string(b) != expected {
+		t.Fatalf("wrong JSON.\nwant=%s\ngot=%s", expected, b)
+	}
+
+	b, err = ast.JSON(parse(t, "fn f(a: int) -> fn() {}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"kind":"Program","statements":[` +
+		`{"kind":"FunctionStatement","pos":{"line":1,"column":1,"offset":0},` +
+		`"name":{"kind":"Identifier","pos":{"line":1,"column":4,"offset":3},"value":"f"},` +
+		`"parameters":[{"kind":"Identifier","pos":{"line":1,"column":6,"offset":5},"value":"a",` +
+		`"type":{"kind":"NamedType","pos":{"line":1,"column":9,"offset":8},"name":"int"}}],` +
+		`"returnType":{"kind":"FunctionType","pos":{"line":1,"column":17,"offset":16},"params":[],"result":null},` +
+		`"body":{"kind":"BlockStatement","pos":{"line":1,"column":22,"offset":21},"statements":[],` +
+		`"rbrace":{"line":1,"column":23,"offset":22}}}]}`
+	if string(b) != expected {
+		t.Fatalf("wrong JSON.\nwant=%s\ngot=%s", expected, b)
+	}
+}
+
+func TestStartAndEnd(t *testing.T) {
+	program := parse(t, "a.b(c)[0] + 1; 𝒳 = -f(x);")
+	tests := []struct {
+		node  ast.Node
+		start string
+	}{
+		{program.Statements[0], "1:1"},
+		{program.Statements[0].(*ast.ExpressionStatement).Expression, "1:1"},
+		{program.Statements[1], "1:16"},
+	}
+	for _, tt := range tests {
+		if got := ast.Start(tt.node).String(); got != tt.start {
+			t.Errorf("start of %s: want=%q got=%q", tt.node, tt.start, got)
+		}
+	}
+
+	name := program.Statements[1].(*ast.AssignmentStatement).Name
+	if got := ast.End(name.Token); got.Column != 17 || got.Offset != 19 {
+		t.Errorf("end of %s: want=1:17 at offset 19 got=%s at offset %d", name, got, got.Offset)
+	}
+}
@@ -0,0 +1,260 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"mingo/internal/token"
+)
+
+// JSON encodes a tree as JSON for tools outside Go. Every node is
+// an object whose "kind" is its type name (for example "LetStatement")
+// and whose "pos" is the position of its token; the other keys are its
+// children and values, named after the fields. Missing children are null,
+// except type annotations, which are left out when absent.
+func JSON(node Node) ([]byte, error) {
+	return json.Marshal(toJSON(node))
+}
+
+// object is a JSON object that keeps its keys in order, so "kind" and
+// "pos" come first.
+type object []field
+
+type field struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.key)
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(key)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+type position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+func jsonPos(p token.Position) position { return position{p.Line, p.Column, p.Offset} }
+
+func nodeObject(kind string, pos token.Position, fields ...field) object {
+	return append(object{{"kind", kind}, {"pos", jsonPos(pos)}}, fields...)
+}
+
+func statementsJSON(stmts []Statement) []any {
+	list := make([]any, len(stmts))
+	for i, s := range stmts {
+		list[i] = toJSON(s)
+	}
+	return list
+}
+
+func expressionsJSON(exprs []Expression) []any {
+	list := make([]any, len(exprs))
+	for i, e := range exprs {
+		list[i] = toJSON(e)
+	}
+	return list
+}
+
+func identifiersJSON(ids []*Identifier) []any {
+	list := make([]any, len(ids))
+	for i, id := range ids {
+		list[i] = toJSON(id)
+	}
+	return list
+}
+
+// toJSON converts a node. A nil node, including a typed nil the parser
+// leaves behind after an error, becomes nil.
+func toJSON(node Node) any {
+	switch n := node.(type) {
+	case *Program:
+		if n == nil {
+			return nil
+		}
+		return object{{"kind", "Program"}, {"statements", statementsJSON(n.Statements)}}
+	case *Identifier:
+		if n == nil {
+			return nil
+		}
+		obj := nodeObject("Identifier", n.Token.Pos, field{"value", n.Value})
+		if n.Type != nil {
+			obj = append(obj, field{"type", toJSON(n.Type)})
+		}
+		return obj
+	case *IntegerLiteral:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("IntegerLiteral", n.Token.Pos, field{"value", n.Value}, field{"literal", n.Token.Literal})
+	case *Boolean:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("Boolean", n.Token.Pos, field{"value", n.Value})
+	case *StringLiteral:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("StringLiteral", n.Token.Pos, field{"value", n.Value})
+	case *ArrayLiteral:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("ArrayLiteral", n.Token.Pos, field{"elements", expressionsJSON(n.Elements)})
+	case *IndexExpression:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("IndexExpression", n.Token.Pos, field{"left", toJSON(n.Left)}, field{"index", toJSON(n.Index)})
+	case *PrefixExpression:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("PrefixExpression", n.Token.Pos,
+			field{"operator", n.Operator}, field{"right", toJSON(n.Right)})
+	case *InfixExpression:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("InfixExpression", n.Token.Pos,
+			field{"left", toJSON(n.Left)}, field{"operator", n.Operator}, field{"right", toJSON(n.Right)})
+	case *IfExpression:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("IfExpression", n.Token.Pos,
+			field{"condition", toJSON(n.Condition)},
+			field{"consequence", toJSON(n.Consequence)},
+			field{"alternative", toJSON(n.Alternative)})
+	case *FunctionLiteral:
+		if n == nil {
+			return nil
+		}
+		fields := []field{{"parameters", identifiersJSON(n.Parameters)}}
+		fields = append(fields, returnTypeJSON(n.ReturnType)...)
+		fields = append(fields, field{"body", toJSON(n.Body)})
+		return nodeObject("FunctionLiteral", n.Token.Pos, fields...)
+	case *CallExpression:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("CallExpression", n.Token.Pos,
+			field{"function", toJSON(n.Function)}, field{"arguments", expressionsJSON(n.Arguments)})
+	case *LetStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("LetStatement", n.Token.Pos,
+			field{"name", toJSON(n.Name)}, field{"value", toJSON(n.Value)})
+	case *AssignmentStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("AssignmentStatement", n.Token.Pos,
+			field{"name", toJSON(n.Name)}, field{"value", toJSON(n.Value)})
+	case *ReturnStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("ReturnStatement", n.Token.Pos, field{"returnValue", toJSON(n.ReturnValue)})
+	case *ExpressionStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("ExpressionStatement", n.Token.Pos, field{"expression", toJSON(n.Expression)})
+	case *PrintStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("PrintStatement", n.Token.Pos, field{"value", toJSON(n.Value)})
+	case *ThrowStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("ThrowStatement", n.Token.Pos, field{"value", toJSON(n.Value)})
+	case *BlockStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("BlockStatement", n.Token.Pos,
+			field{"statements", statementsJSON(n.Statements)}, field{"rbrace", jsonPos(n.Rbrace)})
+	case *WhileStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("WhileStatement", n.Token.Pos,
+			field{"condition", toJSON(n.Condition)}, field{"body", toJSON(n.Body)})
+	case *FunctionStatement:
+		if n == nil {
+			return nil
+		}
+		fields := []field{{"name", toJSON(n.Name)}, {"parameters", identifiersJSON(n.Parameters)}}
+		fields = append(fields, returnTypeJSON(n.ReturnType)...)
+		fields = append(fields, field{"body", toJSON(n.Body)})
+		return nodeObject("FunctionStatement", n.Token.Pos, fields...)
+	case *TryStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("TryStatement", n.Token.Pos,
+			field{"block", toJSON(n.Block)},
+			field{"catchParam", toJSON(n.CatchParam)},
+			field{"catchBlock", toJSON(n.CatchBlock)},
+			field{"finallyBlock", toJSON(n.FinallyBlock)})
+	case *ImportStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("ImportStatement", n.Token.Pos, field{"path", n.Path}, field{"alias", toJSON(n.Alias)})
+	case *ExportStatement:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("ExportStatement", n.Token.Pos, field{"statement", toJSON(n.Statement)})
+	case *SelectorExpression:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("SelectorExpression", n.Token.Pos, field{"x", toJSON(n.X)}, field{"sel", toJSON(n.Sel)})
+	case *NamedType:
+		if n == nil {
+			return nil
+		}
+		return nodeObject("NamedType", n.Token.Pos, field{"name", n.Name})
+	case *FunctionType:
+		if n == nil {
+			return nil
+		}
+		params := make([]any, len(n.Params))
+		for i, p := range n.Params {
+			params[i] = toJSON(p)
+		}
+		return nodeObject("FunctionType", n.Token.Pos, field{"params", params}, field{"result", toJSON(n.Result)})
+	}
+	return nil
+}
+
+func returnTypeJSON(t TypeExpr) []field {
+	if t == nil {
+		return nil
+	}
+	return []field{{"returnType", toJSON(t)}}
+}
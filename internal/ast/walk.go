@@ -0,0 +1,347 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A Visitor's Visit method is called for each node Walk encounters. If the
+// result w is not nil, Walk visits each child of the node with w, followed
+// by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a tree depth-first in source order, starting with node.
+// Nil children are skipped, including the typed nils the parser leaves in
+// statement lists after a syntax error.
+func Walk(v Visitor, node Node) {
+	if isNil(node) {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *AssignmentStatement:
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *ReturnStatement:
+		Walk(v, n.ReturnValue)
+	case *ExpressionStatement:
+		Walk(v, n.Expression)
+	case *PrintStatement:
+		Walk(v, n.Value)
+	case *ThrowStatement:
+		Walk(v, n.Value)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *WhileStatement:
+		Walk(v, n.Condition)
+		Walk(v, n.Body)
+	case *FunctionStatement:
+		Walk(v, n.Name)
+		walkIdentifiers(v, n.Parameters)
+		Walk(v, n.ReturnType)
+		Walk(v, n.Body)
+	case *TryStatement:
+		Walk(v, n.Block)
+		Walk(v, n.CatchParam)
+		Walk(v, n.CatchBlock)
+		Walk(v, n.FinallyBlock)
+	case *PrefixExpression:
+		Walk(v, n.Right)
+	case *InfixExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *IfExpression:
+		Walk(v, n.Condition)
+		Walk(v, n.Consequence)
+		Walk(v, n.Alternative)
+	case *FunctionLiteral:
+		walkIdentifiers(v, n.Parameters)
+		Walk(v, n.ReturnType)
+		Walk(v, n.Body)
+	case *CallExpression:
+		Walk(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *IndexExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Index)
+	case *ImportStatement:
+		Walk(v, n.Alias)
+	case *ExportStatement:
+		Walk(v, n.Statement)
+	case *SelectorExpression:
+		Walk(v, n.X)
+		Walk(v, n.Sel)
+	case *Identifier:
+		Walk(v, n.Type)
+	case *FunctionType:
+		for _, p := range n.Params {
+			Walk(v, p)
+		}
+		Walk(v, n.Result)
+	case *IntegerLiteral, *Boolean, *StringLiteral, *NamedType:
+		// leaves
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, s := range stmts {
+		Walk(v, s)
+	}
+}
+
+func walkExpressions(v Visitor, exprs []Expression) {
+	for _, e := range exprs {
+		Walk(v, e)
+	}
+}
+
+func walkIdentifiers(v Visitor, ids []*Identifier) {
+	for _, id := range ids {
+		Walk(v, id)
+	}
+}
+
+// isNil reports whether node is nil or a nil pointer in an interface.
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a tree in the order of Walk, calling f(node) for each
+// node. If f returns true, Inspect visits the node's children and then
+// calls f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite rewrites a tree bottom-up and returns its new root: it rewrites
+// the children of node, stores the results in node's fields, and then
+// returns f(node). f returns its argument to keep a node, or another node
+// to replace it. A nil result drops the node from a list (statements,
+// parameters, arguments or elements) and leaves a nil field elsewhere.
+//
+// A replacement must fit its place: an Expression where an expression
+// was, a Statement for a statement, a *BlockStatement for a block, an
+// *Identifier for a name and a TypeExpr for a type. Rewrite panics
+// otherwise.
+func Rewrite(node Node, f func(Node) Node) Node {
+	if isNil(node) {
+		return node
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = rewriteStatements(n.Statements, f)
+	case *LetStatement:
+		n.Name = rewriteIdentifier(n.Name, f)
+		n.Value = rewriteExpression(n.Value, f)
+	case *AssignmentStatement:
+		n.Name = rewriteIdentifier(n.Name, f)
+		n.Value = rewriteExpression(n.Value, f)
+	case *ReturnStatement:
+		n.ReturnValue = rewriteExpression(n.ReturnValue, f)
+	case *ExpressionStatement:
+		n.Expression = rewriteExpression(n.Expression, f)
+	case *PrintStatement:
+		n.Value = rewriteExpression(n.Value, f)
+	case *ThrowStatement:
+		n.Value = rewriteExpression(n.Value, f)
+	case *BlockStatement:
+		n.Statements = rewriteStatements(n.Statements, f)
+	case *WhileStatement:
+		n.Condition = rewriteExpression(n.Condition, f)
+		n.Body = rewriteBlock(n.Body, f)
+	case *FunctionStatement:
+		n.Name = rewriteIdentifier(n.Name, f)
+		n.Parameters = rewriteIdentifiers(n.Parameters, f)
+		n.ReturnType = rewriteType(n.ReturnType, f)
+		n.Body = rewriteBlock(n.Body, f)
+	case *TryStatement:
+		n.Block = rewriteBlock(n.Block, f)
+		n.CatchParam = rewriteIdentifier(n.CatchParam, f)
+		n.CatchBlock = rewriteBlock(n.CatchBlock, f)
+		n.FinallyBlock = rewriteBlock(n.FinallyBlock, f)
+	case *PrefixExpression:
+		n.Right = rewriteExpression(n.Right, f)
+	case *InfixExpression:
+		n.Left = rewriteExpression(n.Left, f)
+		n.Right = rewriteExpression(n.Right, f)
+	case *IfExpression:
+		n.Condition = rewriteExpression(n.Condition, f)
+		n.Consequence = rewriteBlock(n.Consequence, f)
+		n.Alternative = rewriteBlock(n.Alternative, f)
+	case *FunctionLiteral:
+		n.Parameters = rewriteIdentifiers(n.Parameters, f)
+		n.ReturnType = rewriteType(n.ReturnType, f)
+		n.Body = rewriteBlock(n.Body, f)
+	case *CallExpression:
+		n.Function = rewriteExpression(n.Function, f)
+		n.Arguments = rewriteExpressions(n.Arguments, f)
+	case *ArrayLiteral:
+		n.Elements = rewriteExpressions(n.Elements, f)
+	case *IndexExpression:
+		n.Left = rewriteExpression(n.Left, f)
+		n.Index = rewriteExpression(n.Index, f)
+	case *ImportStatement:
+		n.Alias = rewriteIdentifier(n.Alias, f)
+	case *ExportStatement:
+		n.Statement = rewriteStatement(n.Statement, f)
+	case *SelectorExpression:
+		n.X = rewriteExpression(n.X, f)
+		n.Sel = rewriteIdentifier(n.Sel, f)
+	case *Identifier:
+		n.Type = rewriteType(n.Type, f)
+	case *FunctionType:
+		params := n.Params[:0]
+		for _, p := range n.Params {
+			if p = rewriteType(p, f); p != nil {
+				params = append(params, p)
+			}
+		}
+		n.Params = params
+		n.Result = rewriteType(n.Result, f)
+	case *IntegerLiteral, *Boolean, *StringLiteral, *NamedType:
+		// leaves
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+
+	return f(node)
+}
+
+func rewriteStatements(stmts []Statement, f func(Node) Node) []Statement {
+	out := stmts[:0]
+	for _, s := range stmts {
+		if isNil(s) {
+			out = append(out, s)
+			continue
+		}
+		if r := rewriteStatement(s, f); r != nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func rewriteStatement(s Statement, f func(Node) Node) Statement {
+	if isNil(s) {
+		return s
+	}
+	r := Rewrite(s, f)
+	if isNil(r) {
+		return nil
+	}
+	stmt, ok := r.(Statement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace statement %T", r, s))
+	}
+	return stmt
+}
+
+func rewriteExpression(e Expression, f func(Node) Node) Expression {
+	if isNil(e) {
+		return e
+	}
+	r := Rewrite(e, f)
+	if isNil(r) {
+		return nil
+	}
+	x, ok := r.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace expression %T", r, e))
+	}
+	return x
+}
+
+func rewriteExpressions(exprs []Expression, f func(Node) Node) []Expression {
+	out := exprs[:0]
+	for _, e := range exprs {
+		if e = rewriteExpression(e, f); e != nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func rewriteBlock(b *BlockStatement, f func(Node) Node) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	r := Rewrite(b, f)
+	if isNil(r) {
+		return nil
+	}
+	x, ok := r.(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace a block", r))
+	}
+	return x
+}
+
+func rewriteIdentifier(id *Identifier, f func(Node) Node) *Identifier {
+	if id == nil {
+		return nil
+	}
+	r := Rewrite(id, f)
+	if isNil(r) {
+		return nil
+	}
+	x, ok := r.(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace identifier %s", r, id.Value))
+	}
+	return x
+}
+
+func rewriteType(t TypeExpr, f func(Node) Node) TypeExpr {
+	if isNil(t) {
+		return t
+	}
+	r := Rewrite(t, f)
+	if isNil(r) {
+		return nil
+	}
+	x, ok := r.(TypeExpr)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace type %T", r, t))
+	}
+	return x
+}
+
+func rewriteIdentifiers(ids []*Identifier, f func(Node) Node) []*Identifier {
+	out := ids[:0]
+	for _, id := range ids {
+		if r := rewriteIdentifier(id, f); r != nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
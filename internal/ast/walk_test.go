@@ -0,0 +1,173 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mingo/internal/ast"
+	"mingo/internal/token"
+)
+
+// everything uses every node type.
+const everything = `import "lib.mg" as lib;
+export let a = -lib.one;
+a = a + 2;
+fn f(x: int, y) -> int { return x; }
+let g: fn(int) = fn(z) { z; };
+print(f(a, true));
+while (a < 3) { a = a + 1; }
+if (a) { 1; } else { 2; }
+try { throw 1; } catch (e) { e; } finally { 3; }
+print(["x", a][0]);
+`
+
+func kind(n ast.Node) string { return strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.") }
+
+type tracer struct{ trace *[]string }
+
+func (t tracer) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		*t.trace = append(*t.trace, "end")
+	} else {
+		*t.trace = append(*t.trace, kind(n))
+	}
+	return t
+}
+
+func TestWalkOrder(t *testing.T) {
+	var trace []string
+	ast.Walk(tracer{&trace}, parse(t, "fn f(x) { return -x; } try {} catch (e) {}"))
+	expected := "Program FunctionStatement Identifier end Identifier end BlockStatement ReturnStatement " +
+		"PrefixExpression Identifier end end end end end " +
+		"TryStatement BlockStatement end Identifier end BlockStatement end end end"
+	if got := strings.Join(trace, " "); got != expected {
+		t.Fatalf("wrong walk order.\nwant=%q\ngot=%q", expected, got)
+	}
+}
+
+// Walk reaches every node type, and as many nodes as the JSON encoding.
+func TestWalkComplete(t *testing.T) {
+	program := parse(t, everything)
+	seen := map[string]int{}
+	total := 0
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			seen[kind(n)]++
+			total++
+		}
+		return true
+	})
+	kinds := []string{
+		"Program", "Identifier", "IntegerLiteral", "Boolean", "PrefixExpression", "InfixExpression",
+		"IfExpression", "FunctionLiteral", "CallExpression", "LetStatement", "AssignmentStatement",
+		"ReturnStatement", "ExpressionStatement", "PrintStatement", "ThrowStatement", "BlockStatement",
+		"WhileStatement", "FunctionStatement", "TryStatement", "NamedType", "FunctionType",
+		"ImportStatement", "ExportStatement", "SelectorExpression", "StringLiteral", "ArrayLiteral",
+		"IndexExpression",
+	}
+	for _, k := range kinds {
+		if seen[k] == 0 {
+			t.Errorf("%s not visited", k)
+		}
+	}
+	if len(seen) != len(kinds) {
+		t.Errorf("unexpected node types visited. got=%v", seen)
+	}
+
+	b, err := ast.JSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded := strings.Count(string(b), `"kind":`); encoded != total {
+		t.Fatalf("Walk visited %d nodes, JSON has %d", total, encoded)
+	}
+}
+
+func TestInspectPrune(t *testing.T) {
+	var names []string
+	ast.Inspect(parse(t, everything), func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionStatement, *ast.FunctionLiteral:
+			return false // skip function bodies
+		case *ast.Identifier:
+			names = append(names, n.Value)
+		}
+		return true
+	})
+	if got := strings.Join(names, " "); got != "lib a lib one a a g f a a a a a e e a" {
+		t.Fatalf("wrong identifiers. got=%q", got)
+	}
+}
+
+func TestWalkSkipsNil(t *testing.T) {
+	// what the parser leaves behind after syntax errors
+	program := &ast.Program{Statements: []ast.Statement{
+		(*ast.LetStatement)(nil),
+		&ast.ExpressionStatement{},
+		&ast.ExpressionStatement{Expression: &ast.IfExpression{}},
+	}}
+	var trace []string
+	ast.Walk(tracer{&trace}, program)
+	expected := "Program ExpressionStatement end ExpressionStatement IfExpression end end end"
+	if got := strings.Join(trace, " "); got != expected {
+		t.Fatalf("wrong walk.\nwant=%q\ngot=%q", expected, got)
+	}
+	ast.Rewrite(program, func(n ast.Node) ast.Node { return n })
+}
+
+func TestRewrite(t *testing.T) {
+	program := parse(t, "let x = 1 + 2 * 3; print(x); fn f(a) { print(a); return a - (4 - 4); }")
+	root := ast.Rewrite(program, func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case *ast.InfixExpression:
+			// fold integer arithmetic
+			l, lok := n.Left.(*ast.IntegerLiteral)
+			r, rok := n.Right.(*ast.IntegerLiteral)
+			if !lok || !rok {
+				return n
+			}
+			var v int64
+			switch n.Operator {
+			case "+":
+				v = l.Value + r.Value
+			case "-":
+				v = l.Value - r.Value
+			case "*":
+				v = l.Value * r.Value
+			default:
+				return n
+			}
+			lit := fmt.Sprint(v)
+			return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: lit, Pos: l.Pos()}, Value: v}
+		case *ast.PrintStatement:
+			return nil // drop
+		case *ast.Identifier:
+			if n.Value == "a" {
+				return &ast.Identifier{Token: n.Token, Value: "arg"}
+			}
+		}
+		return n
+	})
+	if root != program {
+		t.Fatalf("root replaced")
+	}
+	expected := "let x = 7;fn f(arg) { return (arg - 0); }"
+	if got := program.String(); got != expected {
+		t.Fatalf("wrong rewrite.\nwant=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestRewriteMismatch(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "cannot replace expression") {
+			t.Fatalf("wrong panic. got=%v", r)
+		}
+	}()
+	ast.Rewrite(parse(t, "let x = 1;"), func(n ast.Node) ast.Node {
+		if _, ok := n.(*ast.IntegerLiteral); ok {
+			return &ast.PrintStatement{}
+		}
+		return n
+	})
+}
@@ -47,6 +47,13 @@ const (
 	OpReturn
 
 	OpPrint
+
+	OpThrow
+
+	OpGetBuiltin
+
+	OpArray
+	OpIndex
 )
 
 type Definition struct {
@@ -82,6 +89,10 @@ var definitions = map[Opcode]*Definition{
 	OpReturnValue:   {Name: "OpReturnValue"},
 	OpReturn:        {Name: "OpReturn"},
 	OpPrint:         {Name: "OpPrint"},
+	OpThrow:         {Name: "OpThrow"},
+	OpGetBuiltin:    {Name: "OpGetBuiltin", OperandWidths: []int{2}},
+	OpArray:         {Name: "OpArray", OperandWidths: []int{2}},
+	OpIndex:         {Name: "OpIndex"},
 }
 
 func Lookup(op Opcode) (*Definition, error) {
@@ -144,9 +155,18 @@ func (ins Instructions) String() string {
 		op := Opcode(ins[i])
 		def, err := Lookup(op)
 		if err != nil {
-			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			fmt.Fprintf(&out, "%04d ERROR: %s\n", i, err)
+			i++
 			continue
 		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if i+1+width > len(ins) {
+			fmt.Fprintf(&out, "%04d ERROR: %s needs %d operand bytes, got %d\n", i, def.Name, width, len(ins)-i-1)
+			break
+		}
 
 		operands, read := ReadOperands(def, ins[i+1:])
 		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
@@ -0,0 +1,76 @@
+package code_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mingo/internal/code"
+	"mingo/internal/compiler"
+	"mingo/internal/module"
+)
+
+func TestInstructionsString(t *testing.T) {
+	tests := []struct {
+		input    code.Instructions
+		expected string
+	}{
+		{
+			concat(code.Make(code.OpConstant, 65534), code.Make(code.OpGetLocal, 1), code.Make(code.OpAdd)),
+			"0000 OpConstant 65534\n0003 OpGetLocal 1\n0005 OpAdd\n",
+		},
+		{
+			concat([]byte{255}, code.Make(code.OpPop)),
+			"0000 ERROR: opcode 255 undefined\n0001 OpPop\n",
+		},
+		{
+			concat(code.Make(code.OpPop), []byte{byte(code.OpConstant), 1}),
+			"0000 OpPop\n0001 ERROR: OpConstant needs 2 operand bytes, got 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.input.String(); got != tt.expected {
+			t.Errorf("wrong listing.\nwant=%q\ngot=%q", tt.expected, got)
+		}
+	}
+}
+
+// String lists any bytes, one line per instruction or bad byte.
+func FuzzInstructionsString(f *testing.F) {
+	files, _ := filepath.Glob("../../examples/*.mg")
+	if len(files) == 0 {
+		f.Fatalf("no examples found")
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		mods, err := (&module.Loader{}).Load(file, string(src))
+		if err != nil {
+			f.Fatalf("%s: %v", file, err)
+		}
+		comp := compiler.New()
+		if err := module.Compile(comp, mods); err != nil {
+			f.Fatalf("%s: %v", file, err)
+		}
+		f.Add([]byte(comp.Bytecode().Instructions))
+	}
+	f.Add([]byte{255, byte(code.OpConstant), 1})
+	f.Fuzz(func(t *testing.T, input []byte) {
+		listing := code.Instructions(input).String()
+		if n := strings.Count(listing, "\n"); n > len(input) {
+			t.Fatalf("%d lines for %d bytes:\n%s", n, len(input), listing)
+		}
+	})
+}
+
+func concat(parts ...[]byte) code.Instructions {
+	var ins code.Instructions
+	for _, p := range parts {
+		ins = append(ins, p...)
+	}
+	return ins
+}
@@ -0,0 +1,59 @@
+package code
+
+import (
+	"sort"
+
+	"mingo/internal/token"
+)
+
+// PosEntry records that the instructions starting at Offset were compiled
+// from the source position Pos.
+type PosEntry struct {
+	Offset int
+	Pos    token.Position
+}
+
+// PosTable maps instruction offsets to source positions. Entries are sorted
+// by Offset and each one covers the instructions up to the next entry.
+type PosTable []PosEntry
+
+// Lookup returns the source position of the instruction containing offset.
+func (t PosTable) Lookup(offset int) (token.Position, bool) {
+	i := sort.Search(len(t), func(i int) bool { return t[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}, false
+	}
+	return t[i-1].Pos, true
+}
+
+// Handler is one entry of a function's exception table. An error raised by
+// an instruction in [Start, End) resets the operand stack to Depth values
+// above the frame's locals, pushes the error and continues at Target.
+// Entries are ordered innermost first.
+type Handler struct {
+	Start  int
+	End    int
+	Target int
+	Depth  int
+}
+
+// StackEffect reports how many values an instruction adds to (or, when
+// negative, removes from) the operand stack.
+func StackEffect(op Opcode, operands ...int) int {
+	switch op {
+	case OpConstant, OpTrue, OpFalse, OpNull, OpGetGlobal, OpGetLocal, OpGetBuiltin:
+		return 1
+	case OpAdd, OpSub, OpMul, OpDiv,
+		OpEqual, OpNotEqual, OpGreaterThan, OpLessThan, OpGreaterEqual, OpLessEqual,
+		OpPop, OpJumpNotTruthy, OpSetGlobal, OpSetLocal, OpReturnValue, OpPrint, OpThrow, OpIndex:
+		return -1
+	case OpArray:
+		// pops the elements, pushes the array
+		return 1 - operands[0]
+	case OpCall:
+		// pops the callee and arguments, pushes the result
+		return -operands[0]
+	default:
+		return 0
+	}
+}
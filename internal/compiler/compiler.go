@@ -2,25 +2,108 @@ package compiler
 
 import (
 	"fmt"
+	"math"
 
 	"mingo/internal/ast"
 	"mingo/internal/code"
 	"mingo/internal/object"
+	"mingo/internal/stdlib"
+	"mingo/internal/token"
 )
 
 type Compiler struct {
-	instructions code.Instructions
-	constants    []object.Object
+	constants []object.Object
 
 	symTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	pos token.Position // source position of the node being compiled
+
+	diagnostics []Diagnostic
+
+	modules map[string]*module // compiled imported modules by path
+	module  *module            // the module being compiled; nil for the main program
+	aliases map[string]*module // import names of the module being compiled
+	blocks  int                // depth of block nesting; imports and exports need 0
+
+	defined map[*ast.Identifier]definition // the symbols names were defined as
 }
 
-func New() *Compiler {
-	return &Compiler{
-		instructions: code.Instructions{},
-		constants:    []object.Object{},
-		symTable:     NewSymbolTable(),
+// definition is the symbol a name got in the table it was defined in.
+type definition struct {
+	table *SymbolTable
+	sym   Symbol
+}
+
+// module is what the compiler keeps of a compiled imported module.
+type module struct {
+	path    string
+	exports map[string]Symbol
+}
+
+// EmittedInstruction remembers an opcode and where it was written.
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function being compiled.
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+
+	positions code.PosTable
+	handlers  []code.Handler
+	depth     int // operand stack depth above the locals at the end of instructions
+
+	// the enclosing try statements, innermost last; a return statement
+	// runs their finally blocks before leaving the function
+	tries []*tryBlock
+
+	locals []local // let bindings by slot, for unused-variable warnings
+}
+
+// tryBlock is a try statement being compiled.
+type tryBlock struct {
+	finally *ast.BlockStatement // nil if it has none
+	// the finally code return statements inlined in its body or catch
+	// block, as instruction ranges; an error there is not its to handle
+	gaps [][2]int
+}
+
+// handlers returns the exception table entries sending errors raised in
+// [start, end), less the gaps, to target.
+func (t *tryBlock) handlers(start, end, target, depth int) []code.Handler {
+	var hs []code.Handler
+	for _, g := range t.gaps {
+		if g[0] < start || g[1] > end {
+			continue
+		}
+		if g[0] > start {
+			hs = append(hs, code.Handler{Start: start, End: g[0], Target: target, Depth: depth})
+		}
+		start = g[1]
 	}
+	if start < end {
+		hs = append(hs, code.Handler{Start: start, End: end, Target: target, Depth: depth})
+	}
+	return hs
+}
+
+// Bytecode is a compiled program: the top-level instructions with their
+// source positions and exception table, and the constant pool.
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	Positions    code.PosTable
+	Handlers     []code.Handler
+}
+
+func New() *Compiler {
+	return NewWithState(nil, nil)
 }
 
 // NewWithState creates a compiler that reuses an existing symbol table and constants.
@@ -32,44 +115,256 @@ func NewWithState(sym *SymbolTable, consts []object.Object) *Compiler {
 		consts = []object.Object{}
 	}
 	return &Compiler{
-		instructions: code.Instructions{},
-		constants:    consts,
-		symTable:     sym,
+		constants: consts,
+		symTable:  sym,
+		scopes:    []CompilationScope{{instructions: code.Instructions{}}},
+		modules:   map[string]*module{},
+		aliases:   map[string]*module{},
+		defined:   map[*ast.Identifier]definition{},
+	}
+}
+
+// CompileModule compiles an imported module, whose file is path, into the
+// program: its top-level code runs where it is compiled, before the code
+// that imports it, and its globals live in a namespace of their own.
+// Importers reach the names it exports through the import statements the
+// module loader resolved to path. A module is compiled only once.
+func (c *Compiler) CompileModule(path string, program *ast.Program) error {
+	if c.modules[path] != nil {
+		return nil
 	}
+	m := &module{path: path, exports: map[string]Symbol{}}
+	table, outer, aliases := c.symTable, c.module, c.aliases
+	c.symTable, c.module, c.aliases = table.NewModuleTable(), m, map[string]*module{}
+	err := c.Compile(program)
+	c.symTable, c.module, c.aliases = table, outer, aliases
+	c.modules[path] = m
+	return err
 }
 
-func (c *Compiler) Instructions() code.Instructions { return c.instructions }
+func (c *Compiler) Instructions() code.Instructions { return c.scopes[c.scopeIndex].instructions }
 func (c *Compiler) Constants() []object.Object      { return c.constants }
 
+func (c *Compiler) Bytecode() *Bytecode {
+	scope := c.scopes[c.scopeIndex]
+	return &Bytecode{
+		Instructions: scope.instructions,
+		Constants:    c.constants,
+		Positions:    scope.positions,
+		Handlers:     scope.handlers,
+	}
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	ins := code.Make(op, operands...)
-	pos := len(c.instructions)
-	c.instructions = append(c.instructions, ins...)
+	pos := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.previousInstruction = scope.lastInstruction
+	scope.lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+	if n := len(scope.positions); n == 0 || scope.positions[n-1].Pos != c.pos {
+		scope.positions = append(scope.positions, code.PosEntry{Offset: pos, Pos: c.pos})
+	}
+	scope.depth += code.StackEffect(op, operands...)
 	return pos
 }
 
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	scope := &c.scopes[c.scopeIndex]
+	last := scope.lastInstruction.Position
+	scope.instructions = scope.instructions[:last]
+	scope.lastInstruction = scope.previousInstruction
+	for n := len(scope.positions); n > 0 && scope.positions[n-1].Offset >= last; n-- {
+		scope.positions = scope.positions[:n-1]
+	}
+	scope.depth++
+}
+
+// replaceLastPopWithReturn turns a trailing expression statement into the
+// function's return value.
+func (c *Compiler) replaceLastPopWithReturn() {
+	pos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(pos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{instructions: code.Instructions{}})
+	c.scopeIndex++
+	c.symTable = c.symTable.NewEnclosed()
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	ins := c.currentInstructions()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symTable = c.symTable.Outer
+	return ins
+}
+
+// resolve looks a name up for reading or, when assign is set, assignment,
+// reporting an error when it cannot be used. Functions are not closures, so
+// locals of an enclosing function are out of reach.
+func (c *Compiler) resolve(id *ast.Identifier, assign bool) (Symbol, bool) {
+	sym, ok := c.symTable.Resolve(id.Value)
+	if !ok {
+		if assign {
+			c.errorAt(id, "assignment to undeclared variable %s (declare it with let)", id.Value)
+		} else {
+			c.errorAt(id, "undefined variable %s", id.Value)
+		}
+		return Symbol{}, false
+	}
+	if sym.Scope == LocalScope {
+		if _, own := c.symTable.store[id.Value]; !own {
+			c.errorAt(id, "cannot use %s: local of an enclosing function (closures are not supported)", id.Value)
+			return Symbol{}, false
+		}
+	}
+	if !assign {
+		c.markUsed(sym)
+	}
+	return sym, true
+}
+
+// define defines the name id declares. A finally block is compiled once
+// for each way out of its try statement, and its copies share the names it
+// defines.
+func (c *Compiler) define(id *ast.Identifier) Symbol {
+	if d, ok := c.defined[id]; ok && d.table == c.symTable {
+		c.symTable.store[id.Value] = d.sym
+		return d.sym
+	}
+	sym := c.symTable.Define(id.Value)
+	c.defined[id] = definition{c.symTable, sym}
+	c.checkSlot(id, sym)
+	return sym
+}
+
+// checkSlot reports the first name of a function, or of the program, whose
+// slot does not fit in the operand of the instructions that get and set
+// it: a byte for locals and two for globals.
+func (c *Compiler) checkSlot(id *ast.Identifier, sym Symbol) {
+	switch {
+	case sym.Scope == LocalScope && sym.Index == math.MaxUint8+1:
+		c.errorAt(id, "too many local variables in function: more than %d", math.MaxUint8+1)
+	case sym.Scope == GlobalScope && sym.Index == math.MaxUint16+1:
+		c.errorAt(id, "too many global variables: more than %d", math.MaxUint16+1)
+	}
+}
+
+func (c *Compiler) emitSet(sym Symbol) {
+	switch sym.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, sym.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, sym.Index)
+	}
+}
+
+func (c *Compiler) emitGet(sym Symbol) {
+	switch sym.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, sym.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, sym.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, sym.Index)
+	}
+}
+
 func (c *Compiler) addConstant(obj object.Object) int {
 	c.constants = append(c.constants, obj)
 	return len(c.constants) - 1
 }
 
+// Compile compiles node. It carries on past errors such as undefined
+// names, and returns every error found as an ErrorList; warnings are only
+// available from Diagnostics.
 func (c *Compiler) Compile(node ast.Node) error {
+	first := len(c.diagnostics)
+	if err := c.compile(node); err != nil {
+		d, ok := err.(Diagnostic)
+		if !ok {
+			d = Diagnostic{Msg: err.Error()}
+		}
+		c.report(d)
+	}
+	var errs ErrorList
+	for _, d := range c.diagnostics[first:] {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// compile compiles one node. Errors it returns end compilation; they carry
+// the position of the innermost node that failed.
+func (c *Compiler) compile(node ast.Node) (err error) {
+	if node == nil {
+		return fmt.Errorf("missing node")
+	}
+	if _, ok := node.(*ast.Program); !ok {
+		saved := c.pos
+		c.pos = node.Pos()
+		defer func() {
+			c.pos = saved
+			if _, ok := err.(Diagnostic); err != nil && !ok {
+				err = Diagnostic{Pos: node.Pos(), Severity: SeverityError, Msg: err.Error()}
+			}
+		}()
+	}
+
 	switch n := node.(type) {
 	case *ast.Program:
-		for _, s := range n.Statements {
-			if err := c.Compile(s); err != nil {
-				return err
-			}
+		if err := c.compileStatements(n.Statements); err != nil {
+			return err
 		}
 	case *ast.ExpressionStatement:
-		if err := c.Compile(n.Expression); err != nil {
+		if err := c.compile(n.Expression); err != nil {
 			return err
 		}
 		c.emit(code.OpPop)
 	case *ast.IntegerLiteral:
-		i := &object.Integer{Value: n.Value}
+		i := object.NewInteger(n.Value)
 		constIdx := c.addConstant(i)
 		c.emit(code.OpConstant, constIdx)
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: n.Value}))
+	case *ast.ArrayLiteral:
+		if len(n.Elements) > math.MaxUint16 {
+			return fmt.Errorf("too many elements in array literal: %d", len(n.Elements))
+		}
+		for _, e := range n.Elements {
+			if err := c.compile(e); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(n.Elements))
+	case *ast.IndexExpression:
+		if err := c.compile(n.Left); err != nil {
+			return err
+		}
+		if err := c.compile(n.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
 	case *ast.Boolean:
 		if n.Value {
 			c.emit(code.OpTrue)
@@ -77,7 +372,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpFalse)
 		}
 	case *ast.PrefixExpression:
-		if err := c.Compile(n.Right); err != nil {
+		if err := c.compile(n.Right); err != nil {
 			return err
 		}
 		switch n.Operator {
@@ -91,10 +386,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.InfixExpression:
 		// Order matters for < and <=; compile as > or >= by swapping
 		if n.Operator == "<" || n.Operator == "<=" {
-			if err := c.Compile(n.Right); err != nil {
+			if err := c.compile(n.Right); err != nil {
 				return err
 			}
-			if err := c.Compile(n.Left); err != nil {
+			if err := c.compile(n.Left); err != nil {
 				return err
 			}
 			if n.Operator == "<" {
@@ -104,10 +399,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 			return nil
 		}
-		if err := c.Compile(n.Left); err != nil {
+		if err := c.compile(n.Left); err != nil {
 			return err
 		}
-		if err := c.Compile(n.Right); err != nil {
+		if err := c.compile(n.Right); err != nil {
 			return err
 		}
 		switch n.Operator {
@@ -131,133 +426,144 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return fmt.Errorf("unknown operator %q", n.Operator)
 		}
 	case *ast.LetStatement:
-		if err := c.Compile(n.Value); err != nil {
+		if err := c.compile(n.Value); err != nil {
 			return err
 		}
-		sym := c.symTable.Define(n.Name.Value)
-		switch sym.Scope {
-		case GlobalScope:
-			c.emit(code.OpSetGlobal, sym.Index)
-		case LocalScope:
-			c.emit(code.OpSetLocal, sym.Index)
-		}
+		sym := c.defineLocal(n.Name)
+		c.emitSet(sym)
 	case *ast.Identifier:
-		sym, ok := c.symTable.Resolve(n.Value)
+		sym, ok := c.resolve(n, false)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", n.Value)
-		}
-		switch sym.Scope {
-		case GlobalScope:
-			c.emit(code.OpGetGlobal, sym.Index)
-		case LocalScope:
-			c.emit(code.OpGetLocal, sym.Index)
+			c.emit(code.OpNull) // keeps the stack balanced for what follows
+			break
 		}
+		c.emitGet(sym)
 	case *ast.AssignmentStatement:
 		// compile RHS then assign to existing symbol
-		if err := c.Compile(n.Value); err != nil {
+		if err := c.compile(n.Value); err != nil {
 			return err
 		}
-		sym, ok := c.symTable.Resolve(n.Name.Value)
+		sym, ok := c.resolve(n.Name, true)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", n.Name.Value)
-		}
-		switch sym.Scope {
-		case GlobalScope:
-			c.emit(code.OpSetGlobal, sym.Index)
-		case LocalScope:
-			c.emit(code.OpSetLocal, sym.Index)
+			c.emit(code.OpPop)
+			break
 		}
+		c.emitSet(sym)
 	case *ast.BlockStatement:
-		for _, s := range n.Statements {
-			if err := c.Compile(s); err != nil {
-				return err
-			}
+		c.blocks++
+		defer func() { c.blocks-- }()
+		if err := c.compileStatements(n.Statements); err != nil {
+			return err
 		}
 	case *ast.IfExpression:
-		if err := c.Compile(n.Condition); err != nil {
+		if err := c.compile(n.Condition); err != nil {
 			return err
 		}
 		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
-		if err := c.Compile(n.Consequence); err != nil {
+		depth := c.scopes[c.scopeIndex].depth
+		if err := c.compileBranch(n.Consequence); err != nil {
 			return err
 		}
 		jumpPos := c.emit(code.OpJump, 9999)
 		// patch jump-not-truthy to after consequence
-		afterConsequence := len(c.instructions)
+		afterConsequence := len(c.currentInstructions())
 		c.replaceOperand(jumpNotTruthyPos, afterConsequence)
+		c.scopes[c.scopeIndex].depth = depth
 		if n.Alternative != nil {
-			if err := c.Compile(n.Alternative); err != nil {
+			if err := c.compileBranch(n.Alternative); err != nil {
 				return err
 			}
+		} else {
+			c.emit(code.OpNull)
 		}
-		afterAlternative := len(c.instructions)
+		afterAlternative := len(c.currentInstructions())
 		c.replaceOperand(jumpPos, afterAlternative)
 	case *ast.WhileStatement:
-		loopStart := len(c.instructions)
-		if err := c.Compile(n.Condition); err != nil {
+		loopStart := len(c.currentInstructions())
+		if err := c.compile(n.Condition); err != nil {
 			return err
 		}
 		exitJumpPos := c.emit(code.OpJumpNotTruthy, 9999)
-		if err := c.Compile(n.Body); err != nil {
+		if err := c.compile(n.Body); err != nil {
 			return err
 		}
 		c.emit(code.OpJump, loopStart)
-		afterLoop := len(c.instructions)
+		afterLoop := len(c.currentInstructions())
 		c.replaceOperand(exitJumpPos, afterLoop)
 	case *ast.FunctionLiteral:
-		// Enter function scope
-		outer := c.symTable
-		c.symTable = outer.NewEnclosed()
-
-		for _, p := range n.Parameters {
-			c.symTable.Define(p.Value)
-		}
-		// Compile body
-		if err := c.Compile(n.Body); err != nil {
+		if err := c.compileFunction("", n.Parameters, n.Body); err != nil {
 			return err
 		}
-		c.emit(code.OpReturn)
-
-		fn := &object.CompiledFunction{Instructions: c.instructions, NumLocals: c.symTable.numDefs, NumParameters: len(n.Parameters)}
-
-		// restore
-		c.instructions = code.Instructions{}
-		c.symTable = outer
-
-		idx := c.addConstant(fn)
-		c.emit(code.OpConstant, idx)
 	case *ast.FunctionStatement:
-		// Compile like a function literal, then assign to name
-		lit := &ast.FunctionLiteral{Token: n.Token, Parameters: n.Parameters, Body: n.Body}
-		if err := c.Compile(lit); err != nil {
+		// Define the name first so the body can call itself recursively
+		sym := c.define(n.Name)
+		if err := c.compileFunction(n.Name.Value, n.Parameters, n.Body); err != nil {
 			return err
 		}
-		sym := c.symTable.Define(n.Name.Value)
-		c.emit(code.OpSetGlobal, sym.Index)
+		c.emitSet(sym)
 	case *ast.CallExpression:
-		if err := c.Compile(n.Function); err != nil {
+		if err := c.compile(n.Function); err != nil {
 			return err
 		}
 		for _, a := range n.Arguments {
-			if err := c.Compile(a); err != nil {
+			if err := c.compile(a); err != nil {
 				return err
 			}
 		}
 		c.emit(code.OpCall, len(n.Arguments))
 	case *ast.ReturnStatement:
+		if c.module != nil && c.scopeIndex == 0 {
+			return fmt.Errorf("return outside a function in module %s", c.module.path)
+		}
 		if n.ReturnValue != nil {
-			if err := c.Compile(n.ReturnValue); err != nil {
+			if err := c.compile(n.ReturnValue); err != nil {
+				return err
+			}
+			if err := c.compilePendingFinally(); err != nil {
 				return err
 			}
 			c.emit(code.OpReturnValue)
 		} else {
+			if err := c.compilePendingFinally(); err != nil {
+				return err
+			}
 			c.emit(code.OpReturn)
 		}
 	case *ast.PrintStatement:
-		if err := c.Compile(n.Value); err != nil {
+		if err := c.compile(n.Value); err != nil {
 			return err
 		}
 		c.emit(code.OpPrint)
+	case *ast.ThrowStatement:
+		if err := c.compile(n.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.TryStatement:
+		if err := c.compileTry(n); err != nil {
+			return err
+		}
+	case *ast.ImportStatement:
+		c.compileImport(n)
+	case *ast.ExportStatement:
+		if c.blocks > 0 {
+			return fmt.Errorf("export is only allowed at the top level")
+		}
+		if err := c.compile(n.Statement); err != nil {
+			return err
+		}
+		var name *ast.Identifier
+		switch s := n.Statement.(type) {
+		case *ast.LetStatement:
+			name = s.Name
+		case *ast.FunctionStatement:
+			name = s.Name
+		}
+		if sym, ok := c.symTable.Resolve(name.Value); ok && c.module != nil {
+			c.module.exports[name.Value] = sym
+		}
+	case *ast.SelectorExpression:
+		c.compileSelector(n)
 	default:
 		return fmt.Errorf("unhandled node type %T", n)
 	}
@@ -265,15 +571,241 @@ func (c *Compiler) Compile(node ast.Node) error {
 	return nil
 }
 
+func (c *Compiler) compileImport(n *ast.ImportStatement) {
+	if c.blocks > 0 {
+		c.errorAt(n.Alias, "import is only allowed at the top level")
+		return
+	}
+	m := c.modules[n.Resolved]
+	if std := stdlib.Lookup(n.Path); std != nil {
+		m = c.stdModule(std)
+	}
+	if m == nil {
+		c.errorAt(n.Alias, "module %s is not loaded", token.Quote(n.Path))
+		return
+	}
+	if _, dup := c.aliases[n.Alias.Value]; dup {
+		c.errorAt(n.Alias, "%s is already the name of an import", n.Alias.Value)
+		return
+	}
+	c.aliases[n.Alias.Value] = m
+}
+
+// stdModule returns the module that gives access to a standard library
+// module's builtins.
+func (c *Compiler) stdModule(std *stdlib.Module) *module {
+	if m := c.modules[std.Name]; m != nil {
+		return m
+	}
+	m := &module{path: std.Name, exports: map[string]Symbol{}}
+	for _, member := range std.Members {
+		m.exports[member.Name] = Symbol{Name: member.Name, Index: member.Index, Scope: BuiltinScope}
+	}
+	c.modules[std.Name] = m
+	return m
+}
+
+// compileSelector loads an exported global of an imported module. Leaving
+// null in its place after an error keeps the stack balanced.
+func (c *Compiler) compileSelector(n *ast.SelectorExpression) {
+	id, ok := n.X.(*ast.Identifier)
+	if !ok || c.aliases[id.Value] == nil {
+		c.report(Diagnostic{Pos: n.X.Pos(), Severity: SeverityError, Msg: fmt.Sprintf("%s is not an imported module", n.X)})
+		c.emit(code.OpNull)
+		return
+	}
+	m := c.aliases[id.Value]
+	sym, ok := m.exports[n.Sel.Value]
+	if !ok {
+		c.errorAt(n.Sel, "module %s does not export %s", m.path, n.Sel.Value)
+		c.emit(code.OpNull)
+		return
+	}
+	c.emitGet(sym)
+}
+
+// compileBranch compiles an if/else arm so that it leaves exactly one value on
+// the stack: the value of a trailing expression statement, or null.
+func (c *Compiler) compileBranch(block *ast.BlockStatement) error {
+	if err := c.compile(block); err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+func (c *Compiler) compileFunction(name string, params []*ast.Identifier, body *ast.BlockStatement) error {
+	c.enterScope()
+
+	for _, p := range params {
+		c.checkSlot(p, c.symTable.Define(p.Value))
+	}
+	if err := c.compile(body); err != nil {
+		c.leaveScope()
+		return err
+	}
+	// The value of a trailing expression statement is the implicit result
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	c.warnUnused()
+
+	numLocals := c.symTable.numDefs
+	localNames := make([]string, numLocals)
+	for _, sym := range c.symTable.Symbols() {
+		localNames[sym.Index] = sym.Name
+	}
+	scope := c.scopes[c.scopeIndex]
+	ins := c.leaveScope()
+	fn := &object.CompiledFunction{
+		Instructions:  ins,
+		NumLocals:     numLocals,
+		NumParameters: len(params),
+		Positions:     scope.positions,
+		Handlers:      scope.handlers,
+		Name:          name,
+		LocalNames:    localNames,
+	}
+	idx := c.addConstant(fn)
+	c.emit(code.OpConstant, idx)
+	return nil
+}
+
+// compileTry lays out a try statement as
+//
+//	try body; [finally]; jump end
+//	catch:   bind error; catch body; [finally]; jump end
+//	finally: finally body; rethrow      (error still on the stack)
+//	end:
+//
+// and records exception-table entries sending errors from the try body to
+// the catch block, and errors escaping the try or catch body to the
+// finally block.
+func (c *Compiler) compileTry(n *ast.TryStatement) error {
+	depth := c.scopes[c.scopeIndex].depth
+	var exitJumps []int
+
+	t := &tryBlock{finally: n.FinallyBlock}
+	c.pushTry(t)
+	tryStart := len(c.currentInstructions())
+	if err := c.compile(n.Block); err != nil {
+		return err
+	}
+	tryEnd := len(c.currentInstructions())
+	c.popTry()
+	if err := c.compileOptional(n.FinallyBlock); err != nil {
+		return err
+	}
+	exitJumps = append(exitJumps, c.emit(code.OpJump, 9999))
+
+	var handlers []code.Handler
+	catchStart, catchEnd := 0, 0
+	if n.CatchBlock != nil {
+		catchStart = len(c.currentInstructions())
+		handlers = append(handlers, t.handlers(tryStart, tryEnd, catchStart, depth)...)
+		c.scopes[c.scopeIndex].depth = depth + 1 // the caught error
+
+		if n.CatchParam != nil {
+			c.emitSet(c.define(n.CatchParam))
+		} else {
+			c.emit(code.OpPop)
+		}
+		c.pushTry(t)
+		if err := c.compile(n.CatchBlock); err != nil {
+			return err
+		}
+		catchEnd = len(c.currentInstructions())
+		c.popTry()
+		if err := c.compileOptional(n.FinallyBlock); err != nil {
+			return err
+		}
+		exitJumps = append(exitJumps, c.emit(code.OpJump, 9999))
+	}
+
+	if n.FinallyBlock != nil {
+		finallyStart := len(c.currentInstructions())
+		if n.CatchBlock != nil {
+			handlers = append(handlers, t.handlers(catchStart, catchEnd, finallyStart, depth)...)
+		} else {
+			handlers = append(handlers, t.handlers(tryStart, tryEnd, finallyStart, depth)...)
+		}
+		c.scopes[c.scopeIndex].depth = depth + 1
+		if err := c.compile(n.FinallyBlock); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	}
+
+	end := len(c.currentInstructions())
+	for _, pos := range exitJumps {
+		c.replaceOperand(pos, end)
+	}
+	c.scopes[c.scopeIndex].depth = depth
+	// nested try statements were recorded while compiling the blocks above,
+	// so appending keeps the table innermost first
+	c.scopes[c.scopeIndex].handlers = append(c.scopes[c.scopeIndex].handlers, handlers...)
+	return nil
+}
+
+func (c *Compiler) compileOptional(block *ast.BlockStatement) error {
+	if block == nil {
+		return nil
+	}
+	return c.compile(block)
+}
+
+func (c *Compiler) pushTry(t *tryBlock) {
+	c.scopes[c.scopeIndex].tries = append(c.scopes[c.scopeIndex].tries, t)
+}
+
+func (c *Compiler) popTry() {
+	tries := c.scopes[c.scopeIndex].tries
+	c.scopes[c.scopeIndex].tries = tries[:len(tries)-1]
+}
+
+// compilePendingFinally inlines the finally blocks a return statement leaves,
+// innermost first. Each runs with only its outer try statements still
+// pending, and outside the handlers of its own and the inner ones, so that
+// an error it raises is not caught by a try statement it is leaving.
+func (c *Compiler) compilePendingFinally() error {
+	pending := c.scopes[c.scopeIndex].tries
+	defer func() { c.scopes[c.scopeIndex].tries = pending }()
+	for i := len(pending) - 1; i >= 0; i-- {
+		if pending[i].finally == nil {
+			continue
+		}
+		c.scopes[c.scopeIndex].tries = pending[:i]
+		start := len(c.currentInstructions())
+		if err := c.compile(pending[i].finally); err != nil {
+			return err
+		}
+		if end := len(c.currentInstructions()); end > start {
+			for _, t := range pending[i:] {
+				t.gaps = append(t.gaps, [2]int{start, end})
+			}
+		}
+	}
+	return nil
+}
+
 func (c *Compiler) replaceOperand(pos int, operand int) {
-	op := code.Opcode(c.instructions[pos])
+	op := code.Opcode(c.currentInstructions()[pos])
 	operands := []int{operand}
 	newIns := code.Make(op, operands...)
 	c.replaceInstruction(pos, newIns)
 }
 
 func (c *Compiler) replaceInstruction(pos int, newIns []byte) {
+	ins := c.currentInstructions()
 	for i := 0; i < len(newIns); i++ {
-		c.instructions[pos+i] = newIns[i]
+		ins[pos+i] = newIns[i]
 	}
 }
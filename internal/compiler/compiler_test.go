@@ -0,0 +1,92 @@
+package compiler_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mingo/internal/compiler"
+	"mingo/internal/lexer"
+	"mingo/internal/parser"
+)
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1; print(a);", nil},
+		{"print(x + y);", []string{"error 1:7-1:8 undefined variable x", "error 1:11-1:12 undefined variable y"}},
+		{"let a = 1;\nb = a;", []string{"error 2:1-2:2 assignment to undeclared variable b (declare it with let)"}},
+		{"fn f(a) { let g = fn() { a; }; g; }", []string{"error 1:26-1:27 cannot use a: local of an enclosing function (closures are not supported)"}},
+		{"fn f(a) { let b = 1; let c = 2; c; }", []string{"warning 1:15-1:16 unused variable b"}},
+		// assignment alone is not a use; a redefinition reading the old value is
+		{"fn f() { let b = 1; b = 2; let c = 1; let c = c + 1; c; }", []string{"warning 1:14-1:15 unused variable b"}},
+		{"fn f() { return 1; print(2); print(3); }", []string{"warning 1:20 unreachable code"}},
+		{"fn f(x) { if (x) { throw 1; x; } x; }", []string{"warning 1:29 unreachable code"}},
+		// finally blocks are compiled once per exit but reported once
+		{"fn f() { try { return 1; } finally { print(z); } }", []string{"error 1:44-1:45 undefined variable z"}},
+		// local slots are a byte, and global slots two
+		{"fn f(a) {" + strings.Repeat(" let a = a + 1;", 255) + " a; }", nil},
+		{"fn f(a) {" + strings.Repeat(" let a = a + 1;", 256) + " a; }", []string{"error 1:3840-1:3841 too many local variables in function: more than 256"}},
+		{"fn f(" + strings.Repeat("a, ", 256) + "b) { b; }", []string{"error 1:774-1:775 too many local variables in function: more than 256"}},
+		{strings.Repeat("let a = 1; ", 65536), nil},
+		{strings.Repeat("let a = 1; ", 65537), []string{"error 1:720901-1:720902 too many global variables: more than 65536"}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%q: parse errors: %v", tt.input, p.Errors())
+		}
+		c := compiler.New()
+		err := c.Compile(program)
+
+		var got, errs []string
+		for _, d := range c.Diagnostics() {
+			s := fmt.Sprintf("%s %d:%d", d.Severity, d.Pos.Line, d.Pos.Column)
+			if d.End.Line > 0 {
+				s += fmt.Sprintf("-%d:%d", d.End.Line, d.End.Column)
+			}
+			got = append(got, s+" "+d.Msg)
+			if d.Severity == compiler.SeverityError {
+				errs = append(errs, d.Error())
+			}
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: wrong diagnostics.\nwant=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+		if errs == nil && err != nil || errs != nil && (err == nil || err.Error() != strings.Join(errs, "\n")) {
+			t.Errorf("%q: Compile returned %v, want the errors %q", tt.input, err, errs)
+		}
+	}
+}
+
+// Errors in one REPL line do not linger into the next compile.
+func TestCompileReportsOwnErrors(t *testing.T) {
+	sym := compiler.NewSymbolTable()
+	c := compiler.NewWithState(sym, nil)
+	p := parser.New(lexer.New("x;"))
+	if err := c.Compile(p.ParseProgram()); err == nil {
+		t.Fatalf("undefined x compiled")
+	}
+	p = parser.New(lexer.New("let y = 1;"))
+	if err := c.Compile(p.ParseProgram()); err != nil {
+		t.Fatalf("second compile failed: %v", err)
+	}
+}
+
+// An error that ends compilation inside a function leaves its scope, so
+// the next compile defines globals again.
+func TestCompileLeavesFunctionOnError(t *testing.T) {
+	c := compiler.NewWithState(compiler.NewSymbolTable(), nil)
+	p := parser.New(lexer.New("fn f() { if (true) { export let x = 1; } }"))
+	if err := c.Compile(p.ParseProgram()); err == nil {
+		t.Fatalf("export in a function compiled")
+	}
+	p = parser.New(lexer.New("let y = 1; fn g() { y; }"))
+	if err := c.Compile(p.ParseProgram()); err != nil {
+		t.Fatalf("second compile failed: %v", err)
+	}
+}
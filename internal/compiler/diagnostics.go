@@ -0,0 +1,130 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"mingo/internal/ast"
+	"mingo/internal/token"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic is a problem found while compiling. End is zero when only the
+// start of the offending code is known.
+type Diagnostic struct {
+	Pos      token.Position
+	End      token.Position
+	Severity Severity
+	Msg      string
+}
+
+func (d Diagnostic) Error() string {
+	if d.Pos.Line == 0 {
+		return d.Msg
+	}
+	return fmt.Sprintf("%s: %s", d.Pos, d.Msg)
+}
+
+// ErrorList is the error Compile returns: every error-severity diagnostic
+// of the call, in source order of discovery.
+type ErrorList []Diagnostic
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, d := range l {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Diagnostics returns everything reported so far, errors and warnings.
+func (c *Compiler) Diagnostics() []Diagnostic { return c.diagnostics }
+
+func (c *Compiler) report(d Diagnostic) {
+	// finally blocks are compiled once per exit path; report their
+	// problems once
+	for _, seen := range c.diagnostics {
+		if seen.Pos == d.Pos && seen.Msg == d.Msg {
+			return
+		}
+	}
+	c.diagnostics = append(c.diagnostics, d)
+}
+
+// errorAt reports an error spanning the identifier id.
+func (c *Compiler) errorAt(id *ast.Identifier, format string, args ...any) {
+	c.report(Diagnostic{Pos: id.Token.Pos, End: ast.End(id.Token), Severity: SeverityError, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *Compiler) warnAt(pos, end token.Position, format string, args ...any) {
+	c.report(Diagnostic{Pos: pos, End: end, Severity: SeverityWarning, Msg: fmt.Sprintf(format, args...)})
+}
+
+// local tracks a let binding of a function so unused ones can be reported.
+type local struct {
+	name *ast.Identifier
+	used bool
+}
+
+func (c *Compiler) defineLocal(id *ast.Identifier) Symbol {
+	sym := c.define(id)
+	if sym.Scope == LocalScope {
+		scope := &c.scopes[c.scopeIndex]
+		for len(scope.locals) <= sym.Index {
+			scope.locals = append(scope.locals, local{})
+		}
+		scope.locals[sym.Index] = local{name: id}
+	}
+	return sym
+}
+
+func (c *Compiler) markUsed(sym Symbol) {
+	if sym.Scope == LocalScope {
+		if locals := c.scopes[c.scopeIndex].locals; sym.Index < len(locals) {
+			locals[sym.Index].used = true
+		}
+	}
+}
+
+// warnUnused reports the let bindings of the current function that are
+// never read.
+func (c *Compiler) warnUnused() {
+	for _, l := range c.scopes[c.scopeIndex].locals {
+		if l.name != nil && !l.used {
+			c.warnAt(l.name.Token.Pos, ast.End(l.name.Token), "unused variable %s", l.name.Value)
+		}
+	}
+}
+
+// compileStatements compiles a statement list, warning once about
+// statements that follow a return or throw.
+func (c *Compiler) compileStatements(stmts []ast.Statement) error {
+	terminated := false
+	for _, s := range stmts {
+		if terminated {
+			c.warnAt(s.Pos(), token.Position{}, "unreachable code")
+			terminated = false
+		}
+		if err := c.compile(s); err != nil {
+			return err
+		}
+		switch s.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			terminated = true
+		}
+	}
+	return nil
+}
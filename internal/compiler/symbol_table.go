@@ -1,5 +1,7 @@
 package compiler
 
+import "sort"
+
 type SymbolScope string
 
 type Symbol struct {
@@ -9,24 +11,38 @@ type Symbol struct {
 }
 
 const (
-	GlobalScope SymbolScope = "GLOBAL"
-	LocalScope  SymbolScope = "LOCAL"
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	BuiltinScope SymbolScope = "BUILTIN" // Index is into stdlib.Builtins
 )
 
 type SymbolTable struct {
 	Outer   *SymbolTable
 	store   map[string]Symbol
 	numDefs int
+
+	// globals counts the global slots handed out; the global tables of
+	// all modules of a program share it.
+	globals *int
 }
 
 func NewSymbolTable() *SymbolTable {
-	return &SymbolTable{store: make(map[string]Symbol)}
+	return &SymbolTable{store: make(map[string]Symbol), globals: new(int)}
+}
+
+// NewModuleTable returns an empty global table for another module of the
+// program. Its names are separate from those of s, but its slots come
+// from the same global array.
+func (s *SymbolTable) NewModuleTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol), globals: s.globals}
 }
 
 func (s *SymbolTable) Define(name string) Symbol {
 	sym := Symbol{Name: name, Index: s.numDefs}
 	if s.Outer == nil {
 		sym.Scope = GlobalScope
+		sym.Index = *s.globals
+		*s.globals++
 	} else {
 		sym.Scope = LocalScope
 	}
@@ -50,3 +66,17 @@ func (s *SymbolTable) NewEnclosed() *SymbolTable {
 	st.Outer = s
 	return st
 }
+
+// Symbols returns the symbols defined directly in this table, ordered by
+// index. A name defined twice is reported once, with its latest slot.
+func (s *SymbolTable) Symbols() []Symbol {
+	syms := make([]Symbol, 0, len(s.store))
+	for _, sym := range s.store {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool { return syms[i].Index < syms[j].Index })
+	return syms
+}
+
+// NumDefinitions reports how many slots the table has handed out.
+func (s *SymbolTable) NumDefinitions() int { return s.numDefs }
@@ -0,0 +1,205 @@
+// Package cover records which source lines and branch arms of a program
+// run, through the VM's instruction hook, and reports them as text, LCOV
+// or JSON.
+//
+// A line is covered when an instruction compiled from it runs. Every if
+// and while is a branch with two arms: an if's consequence and
+// alternative (which is there even when the source leaves it out), and a
+// while's body and its exit.
+package cover
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"mingo/internal/code"
+	"mingo/internal/compiler"
+	"mingo/internal/object"
+	"mingo/internal/token"
+	"mingo/internal/vm"
+)
+
+// Profile is the coverage of the files of one or more runs, by name.
+type Profile map[string]*File
+
+// File is the coverage of one source file.
+type File struct {
+	Name string
+	// Lines holds the times each line with code ran, zero for those that
+	// did not.
+	Lines    map[int]int
+	Branches []*Branch // in source order
+}
+
+// Branch is an if or a while.
+type Branch struct {
+	Pos  token.Position // of the if or while keyword
+	Kind string         // "if" or "while"
+	// Arms holds the times each arm was taken: the consequence and the
+	// alternative of an if, the body and the exit of a while.
+	Arms [2]int
+}
+
+// Recorder is a vm.Hook that counts the instructions a program runs.
+type Recorder struct {
+	name   string // of the main program, whose positions have no file
+	main   *object.CompiledFunction
+	fns    []*object.CompiledFunction
+	counts map[*object.CompiledFunction][]int
+}
+
+// New returns a recorder for bc, whose main program is the file name.
+func New(bc *compiler.Bytecode, name string) *Recorder {
+	r := &Recorder{
+		name:   name,
+		main:   &object.CompiledFunction{Instructions: bc.Instructions, Positions: bc.Positions},
+		counts: map[*object.CompiledFunction][]int{},
+	}
+	r.fns = append(r.fns, r.main)
+	for _, c := range bc.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			r.fns = append(r.fns, fn)
+		}
+	}
+	return r
+}
+
+func (r *Recorder) Instruction(machine *vm.VM, op code.Opcode) error {
+	fn, ip := machine.Location()
+	if machine.Depth() == 1 {
+		// the VM wraps the main program in a function of its own
+		fn = r.main
+	}
+	counts := r.counts[fn]
+	if counts == nil {
+		counts = make([]int, len(fn.Instructions))
+		r.counts[fn] = counts
+	}
+	counts[ip]++
+	return nil
+}
+
+// Profile returns the coverage recorded so far.
+func (r *Recorder) Profile() Profile {
+	p := Profile{}
+	for _, fn := range r.fns {
+		counts := r.counts[fn]
+		ran := func(ip int) int {
+			if counts == nil {
+				return 0
+			}
+			return counts[ip]
+		}
+		ins := fn.Instructions
+		for ip := 0; ip < len(ins); {
+			op := code.Opcode(ins[ip])
+			def, err := code.Lookup(op)
+			if err != nil {
+				break
+			}
+			operands, n := code.ReadOperands(def, ins[ip+1:])
+			if pos, ok := fn.Positions.Lookup(ip); ok && pos.Line > 0 {
+				if pos.File == "" {
+					pos.File = r.name
+				}
+				f := p.file(pos.File)
+				if hits, ok := f.Lines[pos.Line]; !ok || ran(ip) > hits {
+					f.Lines[pos.Line] = ran(ip)
+				}
+				// only ifs and whiles jump on a condition; the first arm is
+				// reached by falling through the jump alone, and the second
+				// by taking it, perhaps to the end of the program, where no
+				// instruction counts it
+				if op == code.OpJumpNotTruthy {
+					fell := ran(ip + 1 + n)
+					f.Branches = append(f.Branches, &Branch{
+						Pos:  pos,
+						Kind: kind(ins, ip, operands[0]),
+						Arms: [2]int{fell, ran(ip) - fell},
+					})
+				}
+			}
+			ip += 1 + n
+		}
+	}
+	for _, f := range p {
+		slices.SortFunc(f.Branches, func(a, b *Branch) int { return comparePos(a.Pos, b.Pos) })
+	}
+	return p
+}
+
+// kind tells an if from a while by the jump that ends the arm the
+// condition falls through to: an if's jumps past its alternative, and a
+// while's back to its condition.
+func kind(ins code.Instructions, ip, target int) string {
+	if back := target - 3; back > ip && code.Opcode(ins[back]) == code.OpJump {
+		if int(ins[back+1])<<8|int(ins[back+2]) <= ip {
+			return "while"
+		}
+	}
+	return "if"
+}
+
+func comparePos(a, b token.Position) int {
+	return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+}
+
+func (p Profile) file(name string) *File {
+	f := p[name]
+	if f == nil {
+		f = &File{Name: name, Lines: map[int]int{}}
+		p[name] = f
+	}
+	return f
+}
+
+// Merge adds the counts of q, another run of the same files, to p.
+func (p Profile) Merge(q Profile) {
+	for name, qf := range q {
+		f := p.file(name)
+		for line, hits := range qf.Lines {
+			f.Lines[line] += hits
+		}
+		for _, qb := range qf.Branches {
+			i, found := slices.BinarySearchFunc(f.Branches, qb.Pos, func(b *Branch, pos token.Position) int {
+				return comparePos(b.Pos, pos)
+			})
+			if !found {
+				b := *qb
+				f.Branches = slices.Insert(f.Branches, i, &b)
+				continue
+			}
+			f.Branches[i].Arms[0] += qb.Arms[0]
+			f.Branches[i].Arms[1] += qb.Arms[1]
+		}
+	}
+}
+
+// Files returns the files of p, sorted by name.
+func (p Profile) Files() []*File {
+	return slices.SortedFunc(maps.Values(p), func(a, b *File) int { return cmp.Compare(a.Name, b.Name) })
+}
+
+// LineCounts reports how many of f's lines with code ran, out of how many.
+func (f *File) LineCounts() (covered, total int) {
+	for _, hits := range f.Lines {
+		if hits > 0 {
+			covered++
+		}
+	}
+	return covered, len(f.Lines)
+}
+
+// ArmCounts reports how many of f's branch arms were taken, out of how
+// many.
+func (f *File) ArmCounts() (covered, total int) {
+	for _, b := range f.Branches {
+		for _, hits := range b.Arms {
+			if hits > 0 {
+				covered++
+			}
+		}
+	}
+	return covered, 2 * len(f.Branches)
+}
@@ -0,0 +1,152 @@
+package cover_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"mingo/internal/compiler"
+	"mingo/internal/cover"
+	"mingo/internal/module"
+	"mingo/internal/vm"
+)
+
+const program = `import "lib.mg" as lib;
+let i = 0;
+while (i < 2) {
+  i = i + 1;
+}
+if (i > 5) {
+  print("big");
+}
+print(lib.pick(true));
+`
+
+const lib = `export fn pick(c) {
+  if (c) { 1; } else { 2; }
+}
+export fn unused() {
+  while (false) {}
+}
+`
+
+// record runs program with a recorder and returns what it recorded.
+func record(t *testing.T) cover.Profile {
+	t.Helper()
+	loader := &module.Loader{ReadFile: func(path string) ([]byte, error) {
+		if path == "lib.mg" {
+			return []byte(lib), nil
+		}
+		return nil, os.ErrNotExist
+	}}
+	mods, err := loader.Load("main.mg", program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp := compiler.New()
+	if err := module.Compile(comp, mods); err != nil {
+		t.Fatal(err)
+	}
+	bc := comp.Bytecode()
+	machine := vm.NewFromBytecode(bc, nil)
+	machine.SetOutput(io.Discard)
+	rec := cover.New(bc, "main.mg")
+	machine.SetHook(rec)
+	if err := machine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Profile()
+}
+
+// show lists a file's lines as line:count and its branches as
+// kind@line:column[arms].
+func show(f *cover.File) string {
+	var parts []string
+	for line := 1; line <= 10; line++ {
+		if hits, ok := f.Lines[line]; ok {
+			parts = append(parts, fmt.Sprintf("%d:%d", line, hits))
+		}
+	}
+	for _, b := range f.Branches {
+		parts = append(parts, fmt.Sprintf("%s@%d:%d%v", b.Kind, b.Pos.Line, b.Pos.Column, b.Arms))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestRecord(t *testing.T) {
+	p := record(t)
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"main.mg", "2:1 3:3 4:2 6:1 7:0 9:1 while@3:1[2 1] if@6:1[0 1]"},
+		{"lib.mg", "1:1 2:1 4:1 5:0 if@2:3[1 0] while@5:3[0 0]"},
+	}
+	for _, tt := range tests {
+		f := p[tt.file]
+		if f == nil {
+			t.Errorf("%s: not recorded", tt.file)
+			continue
+		}
+		if got := show(f); got != tt.expected {
+			t.Errorf("%s: want=%q got=%q", tt.file, tt.expected, got)
+		}
+	}
+
+	p.Merge(record(t))
+	if got, want := show(p["lib.mg"]), "1:2 2:2 4:2 5:0 if@2:3[2 0] while@5:3[0 0]"; got != want {
+		t.Errorf("merged: want=%q got=%q", want, got)
+	}
+}
+
+func TestReports(t *testing.T) {
+	p := record(t)
+
+	var text strings.Builder
+	if err := p.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	expected := `lib.mg   lines 75.0% (3/4)   branches 25.0% (1/4)
+main.mg  lines 83.3% (5/6)   branches 75.0% (3/4)
+total    lines 80.0% (8/10)  branches 50.0% (4/8)
+`
+	if text.String() != expected {
+		t.Errorf("text: want=%q got=%q", expected, text.String())
+	}
+
+	var lcov strings.Builder
+	if err := (cover.Profile{"lib.mg": p["lib.mg"]}).WriteLCOV(&lcov); err != nil {
+		t.Fatal(err)
+	}
+	expected = `TN:
+SF:lib.mg
+BRDA:2,0,0,1
+BRDA:2,0,1,0
+BRDA:5,1,0,-
+BRDA:5,1,1,-
+BRF:4
+BRH:1
+DA:1,1
+DA:2,1
+DA:4,1
+DA:5,0
+LF:4
+LH:3
+end_of_record
+`
+	if lcov.String() != expected {
+		t.Errorf("lcov: want=%q got=%q", expected, lcov.String())
+	}
+
+	var js strings.Builder
+	if err := (cover.Profile{"lib.mg": p["lib.mg"]}).WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	expected = `{"files":[{"name":"lib.mg","lines":[{"line":1,"count":1},{"line":2,"count":1},{"line":4,"count":1},{"line":5,"count":0}],` +
+		`"branches":[{"line":2,"column":3,"kind":"if","arms":[1,0]},{"line":5,"column":3,"kind":"while","arms":[0,0]}]}]}` + "\n"
+	if js.String() != expected {
+		t.Errorf("json: want=%q got=%q", expected, js.String())
+	}
+}
@@ -0,0 +1,130 @@
+package cover
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+)
+
+// WriteText writes a line for each file with the share of its lines and
+// branch arms that ran, and a total.
+func (p Profile) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	var lines, lineTotal, arms, armTotal int
+	for _, f := range p.Files() {
+		l, lt := f.LineCounts()
+		a, at := f.ArmCounts()
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Name, percent("lines", l, lt), percent("branches", a, at))
+		lines, lineTotal, arms, armTotal = lines+l, lineTotal+lt, arms+a, armTotal+at
+	}
+	fmt.Fprintf(tw, "total\t%s\t%s\n", percent("lines", lines, lineTotal), percent("branches", arms, armTotal))
+	return tw.Flush()
+}
+
+func percent(what string, n, total int) string {
+	if total == 0 {
+		return what + " -"
+	}
+	return fmt.Sprintf("%s %.1f%% (%d/%d)", what, 100*float64(n)/float64(total), n, total)
+}
+
+// WriteLCOV writes p in the LCOV tracefile format, with a DA record for
+// each line and a BRDA record for each branch arm.
+func (p Profile) WriteLCOV(w io.Writer) error {
+	for _, f := range p.Files() {
+		fmt.Fprintf(w, "TN:\nSF:%s\n", f.Name)
+		for i, b := range f.Branches {
+			for arm, hits := range b.Arms {
+				taken := fmt.Sprint(hits)
+				if b.Arms[0]+b.Arms[1] == 0 {
+					// the condition never ran
+					taken = "-"
+				}
+				fmt.Fprintf(w, "BRDA:%d,%d,%d,%s\n", b.Pos.Line, i, arm, taken)
+			}
+		}
+		arms, armTotal := f.ArmCounts()
+		fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", armTotal, arms)
+		for _, line := range lineNumbers(f) {
+			fmt.Fprintf(w, "DA:%d,%d\n", line, f.Lines[line])
+		}
+		lines, lineTotal := f.LineCounts()
+		if _, err := fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", lineTotal, lines); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lineNumbers(f *File) []int { return slices.Sorted(maps.Keys(f.Lines)) }
+
+type jsonProfile struct {
+	Files []jsonFile `json:"files"`
+}
+
+type jsonFile struct {
+	Name     string       `json:"name"`
+	Lines    []jsonLine   `json:"lines"`
+	Branches []jsonBranch `json:"branches"`
+}
+
+type jsonLine struct {
+	Line  int `json:"line"`
+	Count int `json:"count"`
+}
+
+type jsonBranch struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Kind   string `json:"kind"`
+	Arms   [2]int `json:"arms"`
+}
+
+// WriteJSON writes p as one JSON object, for editors to shade lines with:
+//
+//	{"files": [{"name": "lib/a.mg",
+//	  "lines": [{"line": 1, "count": 2}, ...],
+//	  "branches": [{"line": 3, "column": 1, "kind": "if", "arms": [2, 0]}, ...]}]}
+//
+// Lines that did not run have a count of 0; lines without code are left
+// out.
+func (p Profile) WriteJSON(w io.Writer) error {
+	out := jsonProfile{Files: []jsonFile{}}
+	for _, f := range p.Files() {
+		jf := jsonFile{Name: f.Name, Lines: []jsonLine{}, Branches: []jsonBranch{}}
+		for _, line := range lineNumbers(f) {
+			jf.Lines = append(jf.Lines, jsonLine{Line: line, Count: f.Lines[line]})
+		}
+		for _, b := range f.Branches {
+			jf.Branches = append(jf.Branches, jsonBranch{Line: b.Pos.Line, Column: b.Pos.Column, Kind: b.Kind, Arms: b.Arms})
+		}
+		out.Files = append(out.Files, jf)
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// WriteFile writes p to the file path in format, "lcov" or "json".
+func (p Profile) WriteFile(path, format string) error {
+	var write func(io.Writer) error
+	switch format {
+	case "lcov":
+		write = p.WriteLCOV
+	case "json":
+		write = p.WriteJSON
+	default:
+		return fmt.Errorf("unknown coverage format %q; want lcov or json", format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
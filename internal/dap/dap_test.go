@@ -0,0 +1,331 @@
+package dap_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mingo/internal/dap"
+	"mingo/internal/wire"
+)
+
+const program = `fn double(n) {
+  let d = n * 2;
+  d;
+}
+let x = 5;
+let y = double(x);
+print(y);
+print(x + y);
+`
+
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	Event      string          `json:"event"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client is a scripted DAP client talking to an in-process server.
+type client struct {
+	t      *testing.T
+	w      io.WriteCloser
+	in     chan message
+	events []message
+	seq    int
+	done   chan error
+}
+
+func start(t *testing.T, source string) (*client, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "prog.mg")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	toServer, clientW := io.Pipe()
+	clientR, fromServer := io.Pipe()
+	c := &client{t: t, w: clientW, in: make(chan message, 64), done: make(chan error, 1)}
+	go func() {
+		err := dap.New(toServer, fromServer).Serve()
+		fromServer.Close()
+		c.done <- err
+	}()
+	go func() {
+		r := bufio.NewReader(clientR)
+		for {
+			body, err := wire.ReadMessage(r)
+			if err != nil {
+				close(c.in)
+				return
+			}
+			var m message
+			if err := json.Unmarshal(body, &m); err != nil {
+				t.Errorf("bad message %s: %v", body, err)
+			}
+			c.in <- m
+		}
+	}()
+	t.Cleanup(func() {
+		clientW.Close()
+		select {
+		case err := <-c.done:
+			if err != nil {
+				t.Errorf("Serve: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("server did not shut down")
+		}
+	})
+	return c, path
+}
+
+func (c *client) next() message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.in:
+		if !ok {
+			c.t.Fatalf("server closed the connection")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+	}
+	return message{}
+}
+
+// request sends a request and returns its response, queueing any events
+// that arrive first.
+func (c *client) request(command string, args any) message {
+	c.t.Helper()
+	c.seq++
+	req := map[string]any{"seq": c.seq, "type": "request", "command": command}
+	if args != nil {
+		req["arguments"] = args
+	}
+	if err := wire.WriteMessage(c.w, req); err != nil {
+		c.t.Fatalf("write %s: %v", command, err)
+	}
+	for {
+		m := c.next()
+		if m.Type == "event" {
+			c.events = append(c.events, m)
+			continue
+		}
+		if m.RequestSeq != c.seq || m.Command != command {
+			c.t.Fatalf("response for %s/%d, want %s/%d", m.Command, m.RequestSeq, command, c.seq)
+		}
+		return m
+	}
+}
+
+func (c *client) mustRequest(command string, args any, body any) {
+	c.t.Helper()
+	resp := c.request(command, args)
+	if !resp.Success {
+		c.t.Fatalf("%s failed: %s", command, resp.Message)
+	}
+	if body != nil {
+		if err := json.Unmarshal(resp.Body, body); err != nil {
+			c.t.Fatalf("%s body %s: %v", command, resp.Body, err)
+		}
+	}
+}
+
+// waitEvent returns the next event called name, collecting the output of
+// output events skipped on the way.
+func (c *client) waitEvent(name string, output *strings.Builder) message {
+	c.t.Helper()
+	for {
+		var m message
+		if len(c.events) > 0 {
+			m, c.events = c.events[0], c.events[1:]
+		} else {
+			m = c.next()
+		}
+		if m.Type != "event" {
+			c.t.Fatalf("unexpected %s %s while waiting for %s", m.Type, m.Command, name)
+		}
+		if m.Event == "output" && output != nil {
+			var body struct{ Output string }
+			json.Unmarshal(m.Body, &body)
+			output.WriteString(body.Output)
+		}
+		if m.Event == name {
+			return m
+		}
+	}
+}
+
+// stop waits for a stopped event and returns "reason:line function".
+func (c *client) stop(output *strings.Builder) string {
+	c.t.Helper()
+	ev := c.waitEvent("stopped", output)
+	var stopped struct{ Reason string }
+	json.Unmarshal(ev.Body, &stopped)
+	var trace struct {
+		StackFrames []dap.StackFrame
+	}
+	c.mustRequest("stackTrace", map[string]any{"threadId": 1}, &trace)
+	top := trace.StackFrames[0]
+	return stopped.Reason + ":" + strconv.Itoa(top.Line) + " " + top.Name
+}
+
+func launch(c *client, path string, stopOnEntry bool, lines ...int) {
+	c.t.Helper()
+	c.mustRequest("initialize", map[string]any{"adapterID": "mingo"}, nil)
+	c.waitEvent("initialized", nil)
+	c.mustRequest("launch", map[string]any{"program": path, "stopOnEntry": stopOnEntry}, nil)
+	if len(lines) > 0 {
+		bps := make([]map[string]int, len(lines))
+		for i, l := range lines {
+			bps[i] = map[string]int{"line": l}
+		}
+		var body struct{ Breakpoints []dap.Breakpoint }
+		c.mustRequest("setBreakpoints", map[string]any{"source": map[string]string{"path": path}, "breakpoints": bps}, &body)
+		for i, bp := range body.Breakpoints {
+			if !bp.Verified {
+				c.t.Fatalf("breakpoint on line %d not verified: %s", lines[i], bp.Message)
+			}
+		}
+	}
+	c.mustRequest("configurationDone", nil, nil)
+}
+
+func TestSteppingSession(t *testing.T) {
+	c, path := start(t, program)
+	launch(c, path, true)
+
+	var out strings.Builder
+	var stops []string
+	stops = append(stops, c.stop(&out))
+	for _, cmd := range []string{"next", "next", "stepIn", "next", "stepOut"} {
+		c.mustRequest(cmd, map[string]any{"threadId": 1}, nil)
+		stops = append(stops, c.stop(&out))
+	}
+	c.mustRequest("continue", map[string]any{"threadId": 1}, nil)
+	exited := c.waitEvent("exited", &out)
+	c.waitEvent("terminated", &out)
+	c.mustRequest("disconnect", nil, nil)
+
+	want := []string{
+		"entry:1 <main>",
+		"step:5 <main>",
+		"step:6 <main>",
+		"step:2 double",
+		"step:3 double",
+		"step:7 <main>", // the rest of line 6 runs after the return
+	}
+	if strings.Join(stops, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("wrong stops.\nwant=%q\ngot=%q", want, stops)
+	}
+	if out.String() != "10\n15\n" {
+		t.Fatalf("wrong output. got=%q", out.String())
+	}
+	var body struct{ ExitCode int }
+	json.Unmarshal(exited.Body, &body)
+	if body.ExitCode != 0 {
+		t.Fatalf("wrong exit code. got=%d", body.ExitCode)
+	}
+}
+
+func TestBreakpointsAndVariables(t *testing.T) {
+	c, path := start(t, program)
+	launch(c, path, false, 3, 8)
+
+	if got := c.stop(nil); got != "breakpoint:3 double" {
+		t.Fatalf("wrong stop. got=%q", got)
+	}
+
+	var scopes struct{ Scopes []dap.Scope }
+	c.mustRequest("scopes", map[string]any{"frameId": 1}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes. got=%+v", scopes.Scopes)
+	}
+	variables := func(ref int) string {
+		var body struct{ Variables []dap.Variable }
+		c.mustRequest("variables", map[string]any{"variablesReference": ref}, &body)
+		var parts []string
+		for _, v := range body.Variables {
+			parts = append(parts, v.Name+"="+v.Value)
+		}
+		return strings.Join(parts, " ")
+	}
+	if got := variables(scopes.Scopes[0].VariablesReference); got != "n=5 d=10" {
+		t.Fatalf("wrong locals. got=%q", got)
+	}
+	if got := variables(scopes.Scopes[1].VariablesReference); !strings.HasSuffix(got, " x=5 y=null") {
+		t.Fatalf("wrong globals. got=%q", got)
+	}
+
+	var eval struct{ Result string }
+	c.mustRequest("evaluate", map[string]any{"expression": "d", "frameId": 1}, &eval)
+	if eval.Result != "10" {
+		t.Fatalf("wrong evaluate result. got=%q", eval.Result)
+	}
+	if resp := c.request("evaluate", map[string]any{"expression": "nope", "frameId": 1}); resp.Success {
+		t.Fatalf("evaluate of an undefined name succeeded")
+	}
+
+	// moving breakpoints while paused takes effect on resume
+	c.mustRequest("setBreakpoints", map[string]any{"source": map[string]string{"path": path}, "breakpoints": []map[string]int{{"line": 7}}}, nil)
+	c.mustRequest("continue", map[string]any{"threadId": 1}, nil)
+	if got := c.stop(nil); got != "breakpoint:7 <main>" {
+		t.Fatalf("wrong stop. got=%q", got)
+	}
+	c.mustRequest("continue", map[string]any{"threadId": 1}, nil)
+	c.waitEvent("terminated", nil)
+}
+
+func TestUnverifiedBreakpoint(t *testing.T) {
+	c, path := start(t, program)
+	c.mustRequest("initialize", nil, nil)
+	c.mustRequest("launch", map[string]any{"program": path}, nil)
+	var body struct{ Breakpoints []dap.Breakpoint }
+	c.mustRequest("setBreakpoints", map[string]any{"source": map[string]string{"path": path}, "breakpoints": []map[string]int{{"line": 4}, {"line": 5}}}, &body)
+	if len(body.Breakpoints) != 2 || body.Breakpoints[0].Verified || !body.Breakpoints[1].Verified {
+		t.Fatalf("wrong verification. got=%+v", body.Breakpoints)
+	}
+}
+
+func TestRuntimeErrorAndDisconnect(t *testing.T) {
+	c, path := start(t, "let x = 1;\nprint(x / 0);\n")
+	launch(c, path, false)
+	var out strings.Builder
+	exited := c.waitEvent("exited", &out)
+	var body struct{ ExitCode int }
+	json.Unmarshal(exited.Body, &body)
+	if body.ExitCode == 0 || !strings.Contains(out.String(), "ZeroDivisionError") {
+		t.Fatalf("runtime error not reported. exit=%d output=%q", body.ExitCode, out.String())
+	}
+
+	// disconnecting aborts a program that never stops on its own
+	c2, path2 := start(t, "let i = 0;\nwhile (true) {\n  i = i + 1;\n}\n")
+	launch(c2, path2, false)
+	c2.mustRequest("pause", map[string]any{"threadId": 1}, nil)
+	if got := c2.stop(nil); !strings.HasPrefix(got, "pause:") {
+		t.Fatalf("wrong stop. got=%q", got)
+	}
+	c2.mustRequest("disconnect", nil, nil)
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c, path := start(t, "let = 5;")
+	c.mustRequest("initialize", nil, nil)
+	if resp := c.request("launch", map[string]any{"program": path}); resp.Success || resp.Message == "" {
+		t.Fatalf("launch of a bad program succeeded: %+v", resp)
+	}
+	if resp := c.request("next", map[string]any{"threadId": 1}); resp.Success {
+		t.Fatalf("next without a program succeeded")
+	}
+}
@@ -0,0 +1,458 @@
+// Package dap serves the Debug Adapter Protocol for one Mingo program, so
+// editors can drive the debugger. The program runs in its own goroutine;
+// whenever it stops, the server snapshots the call stack and globals, and
+// requests are answered from that snapshot until execution resumes.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"mingo/internal/code"
+	"mingo/internal/compiler"
+	"mingo/internal/debugger"
+	"mingo/internal/module"
+	"mingo/internal/object"
+	"mingo/internal/vm"
+	"mingo/internal/wire"
+)
+
+// Mingo programs are single threaded; the protocol still wants an id.
+const threadID = 1
+
+// Variable references: 1 names the globals, frameRef+i the locals of stack
+// frame i (innermost first).
+const (
+	globalsRef = 1
+	frameRef   = 2
+)
+
+// Server answers DAP requests read from in and writes responses and events
+// to out.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	wmu sync.Mutex // serializes writes and seq
+	seq int
+
+	source      *Source
+	bytecode    *compiler.Bytecode
+	symbols     *compiler.SymbolTable
+	codeLines   map[int]bool
+	breakpoints []int
+	stopOnEntry bool
+	noDebug     bool
+
+	launched, configured bool
+	dbg                  *debugger.Debugger // set once the program starts
+	resume               chan debugger.Action
+	done                 chan struct{}
+
+	mu      sync.Mutex // guards the stop snapshot
+	paused  bool
+	frames  []vm.StackFrame
+	globals []vm.Variable
+}
+
+func New(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:     bufio.NewReader(in),
+		out:    out,
+		resume: make(chan debugger.Action, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// Serve handles requests until the client disconnects or closes the input.
+// A program still running at that point is aborted.
+func (s *Server) Serve() error {
+	defer s.shutdown()
+	for {
+		body, err := wire.ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req Request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("dap: %w", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+		if s.handle(&req) {
+			return nil
+		}
+	}
+}
+
+// handle answers one request and reports whether the session is over.
+func (s *Server) handle(req *Request) bool {
+	var body any
+	var err error
+	switch req.Command {
+	case "initialize":
+		body = map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}
+		s.respond(req, body, nil)
+		s.event("initialized", nil)
+		return false
+	case "launch":
+		err = s.launch(req.Arguments)
+	case "setBreakpoints":
+		body, err = s.setBreakpoints(req.Arguments)
+	case "configurationDone":
+		s.configured = true
+		s.maybeStart()
+	case "threads":
+		body = map[string]any{"threads": []Thread{{ID: threadID, Name: "main"}}}
+	case "stackTrace":
+		body, err = s.stackTrace()
+	case "scopes":
+		body, err = s.scopes(req.Arguments)
+	case "variables":
+		body, err = s.variables(req.Arguments)
+	case "evaluate":
+		body, err = s.evaluate(req.Arguments)
+	case "continue":
+		s.resumeWith(req, debugger.Continue, map[string]any{"allThreadsContinued": true})
+		return false
+	case "next":
+		s.resumeWith(req, debugger.StepOver, nil)
+		return false
+	case "stepIn":
+		s.resumeWith(req, debugger.StepIn, nil)
+		return false
+	case "stepOut":
+		s.resumeWith(req, debugger.StepOut, nil)
+		return false
+	case "pause":
+		if s.dbg == nil {
+			err = errors.New("program is not running")
+		} else {
+			s.dbg.Pause()
+		}
+	case "terminate":
+		s.respond(req, nil, nil)
+		s.interrupt()
+		return false
+	case "disconnect":
+		s.respond(req, nil, nil)
+		return true
+	default:
+		err = fmt.Errorf("unsupported command %s", req.Command)
+	}
+	s.respond(req, body, err)
+	return false
+}
+
+func (s *Server) launch(raw json.RawMessage) error {
+	var args launchArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return err
+	}
+	if s.launched {
+		return errors.New("a program is already launched")
+	}
+	b, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	mods, err := (&module.Loader{}).Load(args.Program, string(b))
+	if err != nil {
+		return err
+	}
+	if errs := module.Check(mods); len(errs) > 0 {
+		return fmt.Errorf("type error: %w", compiler.ErrorList(errs))
+	}
+	sym := compiler.NewSymbolTable()
+	comp := compiler.NewWithState(sym, nil)
+	if err := module.Compile(comp, mods); err != nil {
+		return fmt.Errorf("compile error: %w", err)
+	}
+
+	s.source = &Source{Name: filepath.Base(args.Program), Path: args.Program}
+	s.bytecode = comp.Bytecode()
+	s.symbols = sym
+	s.codeLines = codeLines(s.bytecode)
+	s.stopOnEntry = args.StopOnEntry
+	s.noDebug = args.NoDebug
+	s.launched = true
+	s.maybeStart()
+	return nil
+}
+
+// codeLines returns the source lines that have instructions, the only lines
+// a breakpoint can stop on.
+func codeLines(bc *compiler.Bytecode) map[int]bool {
+	lines := make(map[int]bool)
+	add := func(t code.PosTable) {
+		for _, e := range t {
+			lines[e.Pos.Line] = true
+		}
+	}
+	add(bc.Positions)
+	for _, c := range bc.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			add(fn.Positions)
+		}
+	}
+	return lines
+}
+
+func (s *Server) setBreakpoints(raw json.RawMessage) (any, error) {
+	var args setBreakpointsArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	lines := args.Lines
+	if args.Breakpoints != nil {
+		lines = make([]int, 0, len(args.Breakpoints))
+		for _, bp := range args.Breakpoints {
+			lines = append(lines, bp.Line)
+		}
+	}
+
+	s.breakpoints = lines
+	if s.dbg != nil {
+		s.dbg.ClearBreakpoints()
+		for _, l := range lines {
+			s.dbg.SetBreakpoint(l)
+		}
+	}
+
+	result := make([]Breakpoint, len(lines))
+	for i, l := range lines {
+		result[i] = Breakpoint{Verified: true, Line: l}
+		// before launch every line is accepted
+		if s.codeLines != nil && !s.codeLines[l] {
+			result[i] = Breakpoint{Line: l, Message: "no code on this line"}
+		}
+	}
+	return map[string]any{"breakpoints": result}, nil
+}
+
+// maybeStart runs the program once it is launched and configured.
+func (s *Server) maybeStart() {
+	if !s.launched || !s.configured || s.dbg != nil {
+		return
+	}
+	s.dbg = debugger.New(s.symbols, s.stopped, s.stopOnEntry && !s.noDebug)
+	if !s.noDebug {
+		for _, l := range s.breakpoints {
+			s.dbg.SetBreakpoint(l)
+		}
+	}
+	machine := vm.NewFromBytecode(s.bytecode, nil)
+	machine.SetOutput(&outputWriter{s: s, category: "stdout"})
+
+	go func() {
+		defer close(s.done)
+		err := s.dbg.Run(machine)
+		exitCode := 0
+		switch {
+		case errors.Is(err, debugger.ErrAborted):
+		case err != nil:
+			s.event("output", map[string]any{"category": "stderr", "output": "runtime error: " + err.Error() + "\n"})
+			exitCode = 5 // as cmd/run
+		}
+		s.event("exited", map[string]any{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// stopped is the debugger's stop handler. It runs on the program goroutine
+// and blocks until a request resumes execution.
+func (s *Server) stopped(d *debugger.Debugger, st debugger.Stop) debugger.Action {
+	s.mu.Lock()
+	s.frames = d.CallStack()
+	s.globals = d.Globals()
+	s.paused = true
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]any{
+		"reason":            st.Reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+	return <-s.resume
+}
+
+// resumeWith answers a resuming request, then lets the program continue so
+// the response precedes any event the program sends next.
+func (s *Server) resumeWith(req *Request, action debugger.Action, body any) {
+	s.mu.Lock()
+	paused := s.paused
+	s.paused = false
+	s.mu.Unlock()
+	if !paused {
+		s.respond(req, nil, errors.New("program is not paused"))
+		return
+	}
+	s.respond(req, body, nil)
+	s.resume <- action
+}
+
+// interrupt aborts the running program, if any, and waits for it to end.
+func (s *Server) interrupt() {
+	if s.dbg == nil {
+		return
+	}
+	s.dbg.Interrupt()
+	s.mu.Lock()
+	s.paused = false
+	s.mu.Unlock()
+	// the program may be stopped, or about to stop, waiting for an action
+	select {
+	case s.resume <- debugger.Abort:
+	default:
+	}
+	<-s.done
+}
+
+func (s *Server) shutdown() { s.interrupt() }
+
+// snapshot returns the frames and globals of the current stop.
+func (s *Server) snapshot() ([]vm.StackFrame, []vm.Variable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.paused {
+		return nil, nil, errors.New("program is not paused")
+	}
+	return s.frames, s.globals, nil
+}
+
+func (s *Server) stackTrace() (any, error) {
+	frames, _, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]StackFrame, len(frames))
+	for i, f := range frames {
+		result[i] = StackFrame{
+			ID:     i + 1,
+			Name:   f.Function,
+			Source: s.source,
+			Line:   f.Pos.Line,
+			Column: f.Pos.Column,
+		}
+	}
+	return map[string]any{"stackFrames": result, "totalFrames": len(result)}, nil
+}
+
+func (s *Server) scopes(raw json.RawMessage) (any, error) {
+	var args frameArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	frames, _, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	i := args.FrameID - 1
+	if i < 0 || i >= len(frames) {
+		return nil, fmt.Errorf("unknown frame %d", args.FrameID)
+	}
+	var scopes []Scope
+	if i < len(frames)-1 { // top-level code has no locals
+		scopes = append(scopes, Scope{Name: "Locals", VariablesReference: frameRef + i})
+	}
+	scopes = append(scopes, Scope{Name: "Globals", VariablesReference: globalsRef})
+	return map[string]any{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(raw json.RawMessage) (any, error) {
+	var args variablesArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	frames, globals, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	var vars []vm.Variable
+	switch i := args.VariablesReference - frameRef; {
+	case args.VariablesReference == globalsRef:
+		vars = globals
+	case i >= 0 && i < len(frames):
+		vars = frames[i].Locals
+	default:
+		return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+	result := make([]Variable, len(vars))
+	for i, v := range vars {
+		result[i] = Variable{Name: v.Name, Value: v.Value.Inspect(), Type: string(v.Value.Type())}
+	}
+	return map[string]any{"variables": result}, nil
+}
+
+// evaluate resolves a variable name in the given frame, then in globals.
+// Arbitrary expressions are not supported.
+func (s *Server) evaluate(raw json.RawMessage) (any, error) {
+	var args evaluateArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	frames, globals, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(args.Expression)
+	vars := globals
+	if i := args.FrameID - 1; i >= 0 && i < len(frames) {
+		vars = append(append([]vm.Variable(nil), frames[i].Locals...), globals...)
+	}
+	for _, v := range vars {
+		if v.Name == name {
+			return map[string]any{"result": v.Value.Inspect(), "type": string(v.Value.Type()), "variablesReference": 0}, nil
+		}
+	}
+	return nil, fmt.Errorf("undefined variable %s", name)
+}
+
+func (s *Server) respond(req *Request, body any, err error) {
+	resp := &Response{Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+		resp.Body = nil
+	}
+	s.send(func(seq int) any { resp.Seq = seq; return resp })
+}
+
+func (s *Server) event(name string, body any) {
+	s.send(func(seq int) any { return &Event{Seq: seq, Type: "event", Event: name, Body: body} })
+}
+
+// send numbers and writes a message; write errors end the session on the
+// client side, so they are dropped here.
+func (s *Server) send(msg func(seq int) any) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	s.seq++
+	_ = wire.WriteMessage(s.out, msg(s.seq))
+}
+
+// outputWriter turns program output into output events.
+type outputWriter struct {
+	s        *Server
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", map[string]any{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,230 @@
+// Package debugger drives a VM through its instruction hook: it pauses on
+// line breakpoints and after steps, and resolves variable names for the
+// paused program.
+package debugger
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"mingo/internal/code"
+	"mingo/internal/compiler"
+	"mingo/internal/token"
+	"mingo/internal/vm"
+)
+
+// Action tells the debugger how to resume after a stop.
+type Action int
+
+const (
+	Continue Action = iota // run to the next breakpoint
+	StepIn                 // stop at the next line, entering calls
+	StepOver               // stop at the next line in this frame or a caller
+	StepOut                // stop once the current frame has returned
+	Abort                  // end the run with ErrAborted
+)
+
+// ErrAborted is returned by Run when a stop handler answers Abort.
+var ErrAborted = errors.New("debugger: aborted")
+
+// Stop reasons.
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// Stop describes where and why execution paused.
+type Stop struct {
+	Reason string
+	Pos    token.Position
+	Depth  int
+}
+
+// StopHandler is called while the program is paused. It may inspect the
+// debugger and change breakpoints, and returns how to resume.
+type StopHandler func(d *Debugger, s Stop) Action
+
+// Debugger is a vm.Hook. It stops whenever execution enters a new source
+// line that has a breakpoint or ends the current step. Breakpoints, Pause
+// and Interrupt may be used from other goroutines while the program runs.
+type Debugger struct {
+	machine *vm.VM
+	globals *compiler.SymbolTable
+	onStop  StopHandler
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+
+	pauseRequested atomic.Bool
+	interrupted    atomic.Bool
+
+	action    Action
+	stopped   bool
+	stopDepth int // frame depth of the last stop
+
+	// frameLines[d] is the line frame depth d is on. A caller keeps its
+	// line while a call runs, so returning to it does not enter the line
+	// again.
+	frameLines []int
+	lastDepth  int
+}
+
+// New returns a debugger for a program whose globals were defined in
+// globals. When stopOnEntry is set it pauses before the first line.
+func New(globals *compiler.SymbolTable, onStop StopHandler, stopOnEntry bool) *Debugger {
+	d := &Debugger{
+		globals:     globals,
+		onStop:      onStop,
+		breakpoints: make(map[int]bool),
+		action:      Continue,
+	}
+	if stopOnEntry {
+		d.action = StepIn
+	}
+	return d
+}
+
+// Run executes the program on machine under the debugger.
+func (d *Debugger) Run(machine *vm.VM) error {
+	d.machine = machine
+	machine.SetHook(d)
+	defer machine.SetHook(nil)
+	return machine.Run()
+}
+
+func (d *Debugger) SetBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[line] = true
+}
+
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, line)
+}
+
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = make(map[int]bool)
+}
+
+func (d *Debugger) hasBreakpoint(line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.breakpoints[line]
+}
+
+// Pause asks the running program to stop at the next line.
+func (d *Debugger) Pause() { d.pauseRequested.Store(true) }
+
+// Interrupt ends the run with ErrAborted at the next instruction.
+func (d *Debugger) Interrupt() { d.interrupted.Store(true) }
+
+// Breakpoints returns the breakpoint lines in ascending order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := make([]int, 0, len(d.breakpoints))
+	for l := range d.breakpoints {
+		lines = append(lines, l)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Instruction implements vm.Hook.
+func (d *Debugger) Instruction(machine *vm.VM, op code.Opcode) error {
+	if d.interrupted.Load() {
+		return ErrAborted
+	}
+	// code imported from other files runs without stopping
+	pos, ok := machine.Position()
+	if !ok || pos.Line == 0 || pos.File != "" {
+		return nil
+	}
+	depth := machine.Depth()
+	for len(d.frameLines) <= depth {
+		d.frameLines = append(d.frameLines, 0)
+	}
+	for i := d.lastDepth + 1; i <= depth; i++ {
+		d.frameLines[i] = 0 // fresh calls
+	}
+	d.lastDepth = depth
+	if d.frameLines[depth] == pos.Line {
+		return nil
+	}
+	d.frameLines[depth] = pos.Line
+
+	reason := ""
+	if d.shouldStep(depth) {
+		reason = ReasonStep
+		if !d.stopped {
+			reason = ReasonEntry
+		}
+	} else if d.hasBreakpoint(pos.Line) {
+		reason = ReasonBreakpoint
+	} else if d.pauseRequested.Swap(false) {
+		reason = ReasonPause
+	}
+	if reason == "" {
+		return nil
+	}
+
+	d.stopped, d.stopDepth = true, depth
+	d.action = d.onStop(d, Stop{Reason: reason, Pos: pos, Depth: depth})
+	if d.action == Abort {
+		return ErrAborted
+	}
+	return nil
+}
+
+// shouldStep reports whether entering a new line at depth completes the
+// current step.
+func (d *Debugger) shouldStep(depth int) bool {
+	switch d.action {
+	case StepIn:
+		return true
+	case StepOver:
+		return depth <= d.stopDepth
+	case StepOut:
+		return depth < d.stopDepth
+	default:
+		return false
+	}
+}
+
+// CallStack returns the active frames, innermost first.
+func (d *Debugger) CallStack() []vm.StackFrame { return d.machine.CallStack() }
+
+// Globals returns the named global variables in definition order.
+func (d *Debugger) Globals() []vm.Variable {
+	syms := d.globals.Symbols()
+	vars := make([]vm.Variable, 0, len(syms))
+	for _, sym := range syms {
+		vars = append(vars, vm.Variable{Name: sym.Name, Value: d.machine.Global(sym.Index)})
+	}
+	return vars
+}
+
+// Lookup resolves name the way the paused code would: locals of the current
+// frame first, then globals.
+func (d *Debugger) Lookup(name string) (vm.Value, bool) {
+	frames := d.machine.CallStack()
+	if len(frames) > 1 {
+		for _, v := range frames[0].Locals {
+			if v.Name == name {
+				return v.Value, true
+			}
+		}
+	}
+	if sym, ok := d.globals.Resolve(name); ok {
+		return d.machine.Global(sym.Index), true
+	}
+	return vm.Value{}, false
+}
@@ -0,0 +1,116 @@
+package debugger_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"mingo/internal/compiler"
+	"mingo/internal/debugger"
+	"mingo/internal/lexer"
+	"mingo/internal/parser"
+	"mingo/internal/vm"
+)
+
+const program = `fn fact(n) {
+  if (n < 2) {
+    return 1;
+  }
+  let r = n * fact(n - 1);
+  r;
+}
+let x = 3;
+print(fact(x));
+print(x + 1);
+`
+
+// session runs program, answering each stop with the next action and
+// recording "reason:line@depth" plus the result of inspect.
+func session(t *testing.T, breakpoints []int, actions []debugger.Action, inspect func(d *debugger.Debugger) string) []string {
+	t.Helper()
+	p := parser.New(lexer.New(program))
+	prog := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	sym := compiler.NewSymbolTable()
+	comp := compiler.NewWithState(sym, nil)
+	if err := comp.Compile(prog); err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	var stops []string
+	onStop := func(d *debugger.Debugger, s debugger.Stop) debugger.Action {
+		entry := fmt.Sprintf("%s:%d@%d", s.Reason, s.Pos.Line, s.Depth)
+		if inspect != nil {
+			entry += " " + inspect(d)
+		}
+		stops = append(stops, entry)
+		if len(stops) > len(actions) {
+			return debugger.Continue
+		}
+		return actions[len(stops)-1]
+	}
+	d := debugger.New(sym, onStop, true)
+	for _, line := range breakpoints {
+		d.SetBreakpoint(line)
+	}
+	machine := vm.NewFromBytecode(comp.Bytecode(), nil)
+	machine.SetOutput(io.Discard)
+	if err := d.Run(machine); err != nil && !errors.Is(err, debugger.ErrAborted) {
+		t.Fatalf("run error: %v", err)
+	}
+	return stops
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		actions     []debugger.Action
+		expected    []string
+	}{
+		{
+			name:     "step over stays in top-level code",
+			actions:  []debugger.Action{debugger.StepOver, debugger.StepOver, debugger.StepOver, debugger.StepOver},
+			expected: []string{"entry:1@1", "step:8@1", "step:9@1", "step:10@1"},
+		},
+		{
+			name:     "step in enters calls",
+			actions:  []debugger.Action{debugger.StepOver, debugger.StepOver, debugger.StepIn, debugger.StepIn, debugger.StepOut},
+			expected: []string{"entry:1@1", "step:8@1", "step:9@1", "step:2@2", "step:5@2", "step:10@1"},
+		},
+		{
+			name:        "breakpoints fire once per entry, also in recursion",
+			breakpoints: []int{5},
+			actions:     []debugger.Action{debugger.Continue, debugger.StepOver, debugger.StepOver, debugger.StepOver},
+			expected:    []string{"entry:1@1", "breakpoint:5@2", "breakpoint:5@3", "step:6@3", "step:6@2"},
+		},
+	}
+
+	for _, tt := range tests {
+		got := session(t, tt.breakpoints, tt.actions, nil)
+		if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("%s: stops = %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestInspection(t *testing.T) {
+	inspect := func(d *debugger.Debugger) string {
+		var parts []string
+		for _, f := range d.CallStack() {
+			parts = append(parts, f.Function)
+		}
+		n, _ := d.Lookup("n")
+		x, _ := d.Lookup("x")
+		return strings.Join(parts, "<") + " n=" + n.Inspect() + " x=" + x.Inspect()
+	}
+	got := session(t, []int{6}, []debugger.Action{debugger.Continue, debugger.Abort}, inspect)
+	want := "breakpoint:6@3 fact<fact<<main> n=2 x=3"
+	if len(got) < 2 || got[1] != want {
+		t.Fatalf("stops = %v, want second stop %q", got, want)
+	}
+}
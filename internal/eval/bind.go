@@ -0,0 +1,99 @@
+package eval
+
+import (
+	"mingo/internal/ast"
+	"mingo/internal/module"
+	"mingo/internal/resolve"
+	"mingo/internal/stdlib"
+)
+
+type scope int
+
+const (
+	globalScope scope = iota
+	localScope
+	builtinScope // index is into the builtins
+)
+
+// slot is where a variable lives: a global, a local of the function
+// running or a builtin.
+type slot struct {
+	scope scope
+	index int
+}
+
+// bind gives every name of the modules a slot, and every function its
+// value, from what package resolve finds in each module. It returns the
+// number of globals. The modules must compile: binding checks nothing.
+func (in *Interpreter) bind(mods []*module.Module) int {
+	globals := 0
+	exports := map[string]map[string]slot{} // by module path
+	for _, m := range mods {
+		info := resolve.Program(m.Program)
+		slots := map[*resolve.Symbol]slot{}
+		for i, sc := range info.Scopes {
+			for j, sym := range sc.Symbols {
+				if i == 0 {
+					slots[sym] = slot{scope: globalScope, index: globals}
+					globals++
+				} else {
+					slots[sym] = slot{scope: localScope, index: j}
+				}
+			}
+			in.bindFunction(sc)
+		}
+		for _, ref := range info.Refs {
+			in.slots[ref.Ident] = slots[ref.Sym]
+		}
+
+		// imports and exports are only allowed at the top level
+		aliases := map[string]map[string]slot{}
+		own := map[string]slot{}
+		for _, s := range m.Program.Statements {
+			switch s := s.(type) {
+			case *ast.ImportStatement:
+				aliases[s.Alias.Value] = exports[s.Resolved]
+				if std := stdlib.Lookup(s.Path); std != nil {
+					aliases[s.Alias.Value] = stdExports(std)
+				}
+			case *ast.ExportStatement:
+				switch d := s.Statement.(type) {
+				case *ast.LetStatement:
+					own[d.Name.Value] = in.slots[d.Name]
+				case *ast.FunctionStatement:
+					own[d.Name.Value] = in.slots[d.Name]
+				}
+			}
+		}
+		exports[m.Path] = own
+		ast.Inspect(m.Program, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpression); ok {
+				in.selectors[sel] = aliases[sel.X.(*ast.Identifier).Value][sel.Sel.Value]
+			}
+			return true
+		})
+	}
+	return globals
+}
+
+// bindFunction records the value of the function whose locals are sc.
+func (in *Interpreter) bindFunction(sc *resolve.Scope) {
+	f := &Function{NumLocals: len(sc.Symbols)}
+	switch n := sc.Func.(type) {
+	case *ast.FunctionStatement:
+		f.Name, f.Parameters, f.Body = n.Name.Value, n.Parameters, n.Body
+	case *ast.FunctionLiteral:
+		f.Parameters, f.Body = n.Parameters, n.Body
+	default:
+		return
+	}
+	in.functions[sc.Func] = f
+}
+
+func stdExports(std *stdlib.Module) map[string]slot {
+	exports := map[string]slot{}
+	for _, member := range std.Members {
+		exports[member.Name] = slot{scope: builtinScope, index: member.Index}
+	}
+	return exports
+}
@@ -0,0 +1,520 @@
+// Package eval runs programs by walking their syntax trees, giving them
+// the semantics the compiler and VM give them: the same scoping, values,
+// output and errors, down to the messages and positions of runtime errors.
+// It serves as a reference to test the compiler and VM against. The
+// compiler still checks the programs it runs, and package resolve binds
+// their names.
+//
+// Only the limits differ: the interpreter limits the depth of calls as the
+// VM does, but not the size of the operand stack, so deep recursion that
+// overflows the VM's stack may run here.
+package eval
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"mingo/internal/ast"
+	"mingo/internal/compiler"
+	"mingo/internal/module"
+	"mingo/internal/object"
+	"mingo/internal/stdlib"
+	"mingo/internal/token"
+)
+
+// MaxDepth is the most calls that can be active at once, counting the
+// program itself, as in the VM.
+const MaxDepth = 1024
+
+// Interpreter runs programs.
+type Interpreter struct {
+	out      io.Writer
+	builtins []object.Object
+
+	// what binding found: the slots of identifiers and selectors, and the
+	// values of function literals and statements
+	slots     map[*ast.Identifier]slot
+	selectors map[*ast.SelectorExpression]slot
+	functions map[ast.Node]*Function
+
+	globals []object.Object
+	depth   int
+}
+
+// New returns an interpreter printing to stdout.
+func New() *Interpreter {
+	return &Interpreter{
+		out:       os.Stdout,
+		builtins:  stdlib.Builtins,
+		slots:     map[*ast.Identifier]slot{},
+		selectors: map[*ast.SelectorExpression]slot{},
+		functions: map[ast.Node]*Function{},
+	}
+}
+
+// SetOutput redirects the output of print statements (stdout by default).
+func (in *Interpreter) SetOutput(w io.Writer) { in.out = w }
+
+// SetCapabilities grants the program's io functions access to files, which
+// they are denied by default.
+func (in *Interpreter) SetCapabilities(caps stdlib.Capabilities) { in.builtins = stdlib.Bind(caps) }
+
+// Function is the value of a function literal or statement. It shows as
+// the compiled function does.
+type Function struct {
+	Name       string // declared name; empty for function literals
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	NumLocals  int // parameters included
+}
+
+func (f *Function) Type() object.Type { return object.COMPILED_FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	return fmt.Sprintf("compiled fn[params=%d locals=%d]", len(f.Parameters), f.NumLocals)
+}
+
+// RuntimeError is returned by Run for an error no try statement caught.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Err.Pos.Line == 0 {
+		return e.Err.Inspect()
+	}
+	return fmt.Sprintf("%s: %s", e.Err.Pos, e.Err.Inspect())
+}
+
+// Run runs modules in the order the module loader returns them, the main
+// program last. The modules are compiled first, so that the interpreter
+// runs the programs the compiler accepts: if it rejects them, Run returns
+// its errors, a compiler.ErrorList, and runs nothing. An error that
+// escapes the program is returned as a *RuntimeError.
+func (in *Interpreter) Run(mods []*module.Module) error {
+	if err := module.Compile(compiler.New(), mods); err != nil {
+		return err
+	}
+	in.globals = make([]object.Object, in.bind(mods))
+	for i := range in.globals {
+		in.globals[i] = object.NULL
+	}
+	in.depth = 1
+	// the modules' top-level code runs as one function, which a return
+	// in the main program ends
+	top := &frame{}
+	for _, m := range mods {
+		_, err := in.execStatements(m.Program.Statements, top)
+		if err == errReturn {
+			break
+		}
+		if err != nil {
+			return &RuntimeError{Err: err}
+		}
+	}
+	return nil
+}
+
+// frame holds the locals of a call.
+type frame struct {
+	locals []object.Object
+	result object.Object // set by a return statement
+}
+
+// errReturn is raised by a return statement, to unwind to the call it
+// ends; the frame holds the value returned.
+var errReturn = &object.Error{Kind: "return"}
+
+// execStatements runs stmts until one raises an error. Its result is the
+// value of the last statement if that is an expression statement, and
+// null otherwise.
+func (in *Interpreter) execStatements(stmts []ast.Statement, f *frame) (object.Object, *object.Error) {
+	var result object.Object = object.NULL
+	for i, s := range stmts {
+		var err *object.Error
+		if es, ok := s.(*ast.ExpressionStatement); ok && i == len(stmts)-1 {
+			result, err = in.eval(es.Expression, f)
+		} else {
+			err = in.exec(s, f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (in *Interpreter) exec(s ast.Statement, f *frame) *object.Error {
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		_, err := in.eval(s.Expression, f)
+		return err
+	case *ast.LetStatement:
+		v, err := in.eval(s.Value, f)
+		if err != nil {
+			return err
+		}
+		in.set(in.slots[s.Name], v, f)
+	case *ast.AssignmentStatement:
+		v, err := in.eval(s.Value, f)
+		if err != nil {
+			return err
+		}
+		in.set(in.slots[s.Name], v, f)
+	case *ast.FunctionStatement:
+		in.set(in.slots[s.Name], in.functions[s], f)
+	case *ast.BlockStatement:
+		_, err := in.execStatements(s.Statements, f)
+		return err
+	case *ast.WhileStatement:
+		for {
+			cond, err := in.eval(s.Condition, f)
+			if err != nil {
+				return err
+			}
+			if !truthy(cond) {
+				return nil
+			}
+			if _, err := in.execStatements(s.Body.Statements, f); err != nil {
+				return err
+			}
+		}
+	case *ast.ReturnStatement:
+		var v object.Object = object.NULL
+		if s.ReturnValue != nil {
+			var err *object.Error
+			if v, err = in.eval(s.ReturnValue, f); err != nil {
+				return err
+			}
+		}
+		f.result = v
+		return errReturn
+	case *ast.PrintStatement:
+		v, err := in.eval(s.Value, f)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(in.out, v.Inspect())
+	case *ast.ThrowStatement:
+		v, err := in.eval(s.Value, f)
+		if err != nil {
+			return err
+		}
+		return raise(thrown(v), s)
+	case *ast.TryStatement:
+		return in.execTry(s, f)
+	case *ast.ImportStatement:
+		// bound, with nothing to run
+	case *ast.ExportStatement:
+		return in.exec(s.Statement, f)
+	default:
+		return raise(&object.Error{Kind: object.ErrorKind, Message: fmt.Sprintf("unhandled node type %T", s)}, s)
+	}
+	return nil
+}
+
+// execTry runs a try statement. The finally block runs however the try or
+// catch block is left; an error or return of its own replaces the way it
+// was left.
+func (in *Interpreter) execTry(s *ast.TryStatement, f *frame) *object.Error {
+	_, err := in.execStatements(s.Block.Statements, f)
+	if err != nil && err != errReturn && s.CatchBlock != nil {
+		if s.CatchParam != nil {
+			in.set(in.slots[s.CatchParam], err, f)
+		}
+		_, err = in.execStatements(s.CatchBlock.Statements, f)
+	}
+	if s.FinallyBlock == nil {
+		return err
+	}
+	result := f.result
+	if _, ferr := in.execStatements(s.FinallyBlock.Statements, f); ferr != nil {
+		return ferr
+	}
+	f.result = result
+	return err
+}
+
+func (in *Interpreter) eval(e ast.Expression, f *frame) (object.Object, *object.Error) {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return object.NewInteger(e.Value), nil
+	case *ast.StringLiteral:
+		return &object.String{Value: e.Value}, nil
+	case *ast.Boolean:
+		return object.NativeBool(e.Value), nil
+	case *ast.Identifier:
+		return in.get(in.slots[e], f), nil
+	case *ast.SelectorExpression:
+		return in.get(in.selectors[e], f), nil
+	case *ast.ArrayLiteral:
+		elems := make([]object.Object, len(e.Elements))
+		for i, x := range e.Elements {
+			v, err := in.eval(x, f)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = v
+		}
+		return &object.Array{Elements: elems}, nil
+	case *ast.IndexExpression:
+		left, err := in.eval(e.Left, f)
+		if err != nil {
+			return nil, err
+		}
+		index, err := in.eval(e.Index, f)
+		if err != nil {
+			return nil, err
+		}
+		v, err := evalIndex(left, index)
+		return v, raise(err, e)
+	case *ast.PrefixExpression:
+		right, err := in.eval(e.Right, f)
+		if err != nil {
+			return nil, err
+		}
+		if e.Operator == "!" {
+			return object.NativeBool(!truthy(right)), nil
+		}
+		n, ok := right.(*object.Integer)
+		if !ok {
+			return nil, errorf(e, object.TypeErrorKind, "unsupported negation operand %s", right.Type())
+		}
+		return object.NewInteger(-n.Value), nil
+	case *ast.InfixExpression:
+		return in.evalInfix(e, f)
+	case *ast.IfExpression:
+		cond, err := in.eval(e.Condition, f)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case truthy(cond):
+			return in.execStatements(e.Consequence.Statements, f)
+		case e.Alternative != nil:
+			return in.execStatements(e.Alternative.Statements, f)
+		}
+		return object.NULL, nil
+	case *ast.FunctionLiteral:
+		return in.functions[e], nil
+	case *ast.CallExpression:
+		fn, err := in.eval(e.Function, f)
+		if err != nil {
+			return nil, err
+		}
+		args := make([]object.Object, len(e.Arguments))
+		for i, a := range e.Arguments {
+			if args[i], err = in.eval(a, f); err != nil {
+				return nil, err
+			}
+		}
+		return in.call(e, fn, args)
+	}
+	return nil, errorf(e, object.ErrorKind, "unhandled node type %T", e)
+}
+
+// evalInfix evaluates a binary operation. The operands of < and <= are
+// evaluated right first, as the compiler turns them into > and >= with the
+// operands swapped; their type errors name the operator and the operands
+// as swapped.
+func (in *Interpreter) evalInfix(e *ast.InfixExpression, f *frame) (object.Object, *object.Error) {
+	first, second, op := e.Left, e.Right, e.Operator
+	switch op {
+	case "<":
+		first, second, op = e.Right, e.Left, ">"
+	case "<=":
+		first, second, op = e.Right, e.Left, ">="
+	}
+	left, err := in.eval(first, f)
+	if err != nil {
+		return nil, err
+	}
+	right, err := in.eval(second, f)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "==":
+		return object.NativeBool(equal(left, right)), nil
+	case "!=":
+		return object.NativeBool(!equal(left, right)), nil
+	}
+	if op == "+" {
+		l, lok := left.(*object.String)
+		r, rok := right.(*object.String)
+		if lok && rok {
+			return &object.String{Value: l.Value + r.Value}, nil
+		}
+	}
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if !lok || !rok {
+		if op == ">" || op == ">=" {
+			return nil, errorf(e, object.TypeErrorKind, "%s requires integers, got %s %s", op, left.Type(), right.Type())
+		}
+		return nil, errorf(e, object.TypeErrorKind, "unsupported types for binary op: %s %s", left.Type(), right.Type())
+	}
+	switch op {
+	case "+":
+		return object.NewInteger(l.Value + r.Value), nil
+	case "-":
+		return object.NewInteger(l.Value - r.Value), nil
+	case "*":
+		return object.NewInteger(l.Value * r.Value), nil
+	case "/":
+		if r.Value == 0 {
+			return nil, errorf(e, object.ZeroDivisionKind, "division by zero")
+		}
+		return object.NewInteger(l.Value / r.Value), nil
+	case ">":
+		return object.NativeBool(l.Value > r.Value), nil
+	default:
+		return object.NativeBool(l.Value >= r.Value), nil
+	}
+}
+
+func evalIndex(left, index object.Object) (object.Object, *object.Error) {
+	if m, ok := left.(*object.Map); ok {
+		key, ok := index.(*object.String)
+		if !ok {
+			return nil, newError(object.TypeErrorKind, "map key must be STRING, got %s", index.Type())
+		}
+		v, ok := m.Pairs[key.Value]
+		if !ok {
+			return nil, newError(object.KeyErrorKind, "key %s not in map", token.Quote(key.Value))
+		}
+		return v, nil
+	}
+	arr, ok := left.(*object.Array)
+	if !ok {
+		return nil, newError(object.TypeErrorKind, "index operator not supported: %s", left.Type())
+	}
+	i, ok := index.(*object.Integer)
+	if !ok {
+		return nil, newError(object.TypeErrorKind, "array index must be INTEGER, got %s", index.Type())
+	}
+	if i.Value < 0 || i.Value >= int64(len(arr.Elements)) {
+		return nil, newError(object.IndexErrorKind, "index %d out of range for array of length %d", i.Value, len(arr.Elements))
+	}
+	return arr.Elements[i.Value], nil
+}
+
+// call calls fn for the call expression node. A builtin's errors, and
+// those of the functions it calls back, are raised at node.
+func (in *Interpreter) call(node *ast.CallExpression, fn object.Object, args []object.Object) (object.Object, *object.Error) {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		if fn.Arity >= 0 && len(args) != fn.Arity {
+			return nil, errorf(node, object.ArgumentErrorKind, "wrong number of arguments to %s: want=%d, got=%d", fn.Name, fn.Arity, len(args))
+		}
+		var result object.Object
+		var err *object.Error
+		if fn.Call != nil {
+			result, err = fn.Call(func(fn object.Object, args ...object.Object) (object.Object, *object.Error) {
+				return in.call(node, fn, args)
+			}, args...)
+		} else {
+			result, err = fn.Fn(args...)
+		}
+		if err != nil {
+			return nil, raise(err, node)
+		}
+		if result == nil {
+			result = object.NULL
+		}
+		return result, nil
+	case *Function:
+		if len(args) != len(fn.Parameters) {
+			return nil, errorf(node, object.ArgumentErrorKind, "wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+		if in.depth >= MaxDepth {
+			return nil, errorf(node, object.StackOverflowKind, "call stack overflow")
+		}
+		in.depth++
+		defer func() { in.depth-- }()
+		f := &frame{locals: make([]object.Object, fn.NumLocals)}
+		copy(f.locals, args)
+		for i := len(args); i < len(f.locals); i++ {
+			f.locals[i] = object.NULL
+		}
+		result, err := in.execStatements(fn.Body.Statements, f)
+		if err == errReturn {
+			return f.result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	return nil, errorf(node, object.TypeErrorKind, "calling non-function: %s", fn.Type())
+}
+
+func (in *Interpreter) get(s slot, f *frame) object.Object {
+	switch s.scope {
+	case globalScope:
+		return in.globals[s.index]
+	case localScope:
+		return f.locals[s.index]
+	default:
+		return in.builtins[s.index]
+	}
+}
+
+func (in *Interpreter) set(s slot, v object.Object, f *frame) {
+	if s.scope == globalScope {
+		in.globals[s.index] = v
+	} else {
+		f.locals[s.index] = v
+	}
+}
+
+func truthy(o object.Object) bool {
+	switch o := o.(type) {
+	case *object.Boolean:
+		return o.Value
+	case *object.Null:
+		return false
+	}
+	return true
+}
+
+// equal compares integers, booleans and strings by value and other
+// objects by identity.
+func equal(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	}
+	return a == b
+}
+
+// thrown converts the operand of a throw statement into an error value.
+func thrown(v object.Object) *object.Error {
+	if e, ok := v.(*object.Error); ok {
+		return e
+	}
+	return &object.Error{Kind: object.ErrorKind, Message: v.Inspect(), Value: v}
+}
+
+func newError(kind, format string, args ...any) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// errorf builds an error raised by node.
+func errorf(node ast.Node, kind, format string, args ...any) *object.Error {
+	return raise(newError(kind, format, args...), node)
+}
+
+// raise attaches the position of node to e unless it already carries one,
+// as a rethrown error does. A nil e stays nil.
+func raise(e *object.Error, node ast.Node) *object.Error {
+	if e != nil && e.Pos.Line == 0 {
+		e.Pos = node.Pos()
+	}
+	return e
+}
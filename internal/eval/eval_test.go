@@ -0,0 +1,180 @@
+package eval_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mingo/internal/compiler"
+	"mingo/internal/eval"
+	"mingo/internal/gen"
+	"mingo/internal/module"
+	"mingo/internal/vm"
+)
+
+// runVM compiles and runs the program src in the file path, returning what
+// it printed and the compile or runtime error.
+func runVM(t *testing.T, path, src string) (string, error) {
+	t.Helper()
+	mods, err := (&module.Loader{}).Load(path, src)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	comp := compiler.New()
+	if err := module.Compile(comp, mods); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	machine := vm.NewFromBytecode(comp.Bytecode(), nil)
+	machine.SetOutput(&out)
+	err = machine.Run()
+	return out.String(), err
+}
+
+// runEval is runVM for the interpreter.
+func runEval(t *testing.T, path, src string) (string, error) {
+	t.Helper()
+	mods, err := (&module.Loader{}).Load(path, src)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	var out strings.Builder
+	in := eval.New()
+	in.SetOutput(&out)
+	err = in.Run(mods)
+	return out.String(), err
+}
+
+// compare runs src through the VM and the interpreter and reports where
+// their output or errors differ.
+func compare(t *testing.T, path, src string) {
+	t.Helper()
+	want, wantErr := runVM(t, path, src)
+	got, gotErr := runEval(t, path, src)
+	if got != want {
+		t.Errorf("%s: output differs.\nprogram:\n%s\nvm=  %q\neval=%q", path, src, want, got)
+	}
+	if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+		t.Errorf("%s: error differs.\nprogram:\n%s\nvm=  %v\neval=%v", path, src, wantErr, gotErr)
+	}
+}
+
+func TestExamples(t *testing.T) {
+	paths, err := filepath.Glob("../../examples/*.mg")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no examples: %v", err)
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compare(t, path, string(src))
+	}
+}
+
+func TestPrograms(t *testing.T) {
+	programs := []string{
+		// values and operators
+		`print(1 + 2 * 3 - 4 / 2); print(-7 / 2); print("a" + "b"); print(!0); print(!false);`,
+		`print(1 == 1); print("a" == "a"); print([1] == [1]); print(if (false) { 1; } == false); print(1 != true);`,
+		`print(9223372036854775807 + 1); print(-9223372036854775807 - 1);`,
+		`let a = [1, "two", [3, true]]; print(a); print(a[2][0]); print(a == a);`,
+		`fn f() { print("left"); 1; } fn g() { print("right"); 2; } print(f() < g()); print(f() > g());`,
+		`print(if (1) { "yes"; } else { "no"; }); print(if (false) { 1; }); print(if (true) { let z = 1; });`,
+		// scoping
+		`let x = 1; fn f() { x; } let x = 2; print(f()); print(x);`,
+		`fn f(a) { let b = a + 1; if (a > 0) { let c = b * 2; } c; } print(f(1)); print(f(0));`,
+		`fn f(n) { if (n < 2) { return 1; } n * f(n - 1); } print(f(10));`,
+		`fn f(a, b) { let t = a; a = b; b = t; [a, b]; } print(f(1, 2)); print(f);`,
+		`let f = fn(x) { (fn(y) { y; }); }; print(f(1)); print(f(1) == f(2)); print(f(1)(3));`,
+		`if (false) { let never = 1; } print(never);`,
+		`let i = 0; let s = ""; while (i < 5) { s = s + "x"; i = i + 1; } print(s);`,
+		`fn f() { let i = 0; while (true) { if (i == 3) { return i; } i = i + 1; } } print(f());`,
+		`fn f() { let g = if (true) { return 1; }; 2; } print(f());`,
+		`print(0); return 1; print(1);`,
+		// errors
+		`try { 1 / 0; } catch (e) { print(e); } print(2);`,
+		`fn f(x) { try { return x / 0; } catch (e) { print(e); return -1; } finally { print("done"); } } print(f(1));`,
+		`try { throw [1, 2]; } catch (e) { print(e); try { throw e; } catch (e2) { print(e2 == e); } }`,
+		`try { try { throw 1; } finally { print(2); } } catch { print(3); } finally { print(4); }`,
+		`fn f() { try { return 1; } finally { return 2; } } print(f());`,
+		`fn f() { try { throw 1; } finally { return 2; } } print(f());`,
+		`fn f() { try { return 1; } catch (e) { print(e); } finally { print(2); throw 3; } } try { f(); } catch (e) { print(e); }`,
+		`let y = 0; try { throw 1; } catch (e) { print(y); } finally { let y = 2; } print(y);`,
+		`try { } finally { let x = 1; } print(x); fn f() { try { return 1; } finally { let y = 2; } } print(f);`,
+		`print(1); true + 1;`,
+		`print(1 < "a");`,
+		`-"a";`,
+		`[1, 2][5];`,
+		`let m = 1; m(2);`,
+		`fn f(a) { a; } f();`,
+		`fn f() { f(); } f();`,
+		`throw "x";`,
+		// standard library
+		`import "math" as m; print(m.max(m.abs(-3), 2)); m.abs(1, 2);`,
+		`import "testing" as t; print(t.assert_error(fn() { 1 / 0; })); t.assert_error(fn() { 1; });`,
+		`import "testing" as t; let e = t.assert_error(fn() { [][0]; }); print(e); throw e;`,
+		`import "testing" as t; t.assert_error(fn(x) { x; });`,
+		`import "maps" as maps; let m = maps.new(); maps.set(m, "k", [1]); print(m); print(m["k"]); m["j"];`,
+		`import "arrays" as a; import "json" as j; print(j.stringify(a.push([1], "two"))); print(j.parse("{\"a\": [1, null]}"));`,
+		// compile errors
+		`print(x + y);`,
+		`let a = 1; b = a; fn f(a) { let g = fn() { a; }; }`,
+		`fn f() { if (true) { import "math" as m; } } import "math" as m; import "math" as m; print(n.x); print(m.nope);`,
+		`fn f() { try { return 1; let z = 2; } finally { print(z); } }`,
+		`fn f() { if (true) { export let x = 1; } }`,
+		"fn f(a) {" + strings.Repeat(" let a = a + 1;", 256) + " a; }",
+	}
+	for i, src := range programs {
+		compare(t, fmt.Sprintf("program%d", i), src)
+	}
+}
+
+func TestModules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib.mg":  `import "math" as m; export let k = m.abs(-4); export fn twice(x) { x * 2; } print("lib");`,
+		"bad.mg":  `export fn f() { undefined_name; } return 1;`,
+		"main.mg": `import "lib.mg" as lib; print(lib.twice(lib.k)); lib.twice("a");`,
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	compare(t, filepath.Join(dir, "main.mg"), files["main.mg"])
+	compare(t, filepath.Join(dir, "main.mg"), `import "bad.mg" as bad; import "lib.mg" as lib; print(lib.nope);`)
+}
+
+// The VM and the interpreter agree on generated programs, which raise
+// errors but end. A program they disagree on is reported shrunk.
+func TestGenerated(t *testing.T) {
+	differs := func(src string) bool {
+		if _, err := (&module.Loader{}).Load("", src); err != nil {
+			return false
+		}
+		want, wantErr := runVM(t, "", src)
+		got, gotErr := runEval(t, "", src)
+		return got != want || fmt.Sprint(gotErr) != fmt.Sprint(wantErr)
+	}
+	for seed := range uint64(300) {
+		src := gen.ProgramWithErrors(rand.New(rand.NewPCG(seed, 47)))
+		if differs(src) {
+			compare(t, fmt.Sprintf("seed%d", seed), gen.Shrink(src, differs))
+		}
+	}
+}
+
+func TestCallDepth(t *testing.T) {
+	// the VM's operand stack overflows first; the interpreter only limits
+	// calls
+	src := "fn f(n) { if (n == 0) { return 0; } 1 + f(n - 1); } print(f(1000)); f(2000);"
+	out, err := runEval(t, "", src)
+	if want := "1:42: StackOverflowError: call stack overflow"; out != "1000\n" || err == nil || err.Error() != want {
+		t.Errorf("output %q, error %v; want %q and %q", out, err, "1000\n", want)
+	}
+}
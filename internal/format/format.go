@@ -0,0 +1,358 @@
+// Package format prints Mingo programs in canonical form: two-space
+// indentation, one statement per line, single spaces around binary
+// operators and only the parentheses the grammar needs. Comments and single
+// blank lines between statements are kept. Formatting is idempotent.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"mingo/internal/ast"
+	"mingo/internal/lexer"
+	"mingo/internal/parser"
+	"mingo/internal/token"
+)
+
+// Source formats a program. It fails on the first parse error, since a
+// program that does not parse cannot be printed faithfully.
+func Source(src string) (string, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.RichErrors(); len(errs) > 0 {
+		return "", fmt.Errorf("%d:%d: %s", errs[0].Pos.Line, errs[0].Pos.Column, errs[0].Msg)
+	}
+	pr := &printer{src: src, comments: l.Comments()}
+	pr.statements(program.Statements, len(src)+1)
+	return pr.out.String(), nil
+}
+
+type printer struct {
+	src      string
+	comments []token.Comment // not printed yet
+	out      bytes.Buffer
+	indent   int
+}
+
+func (p *printer) write(s string) { p.out.WriteString(s) }
+
+func (p *printer) writeIndent() { p.write(strings.Repeat("  ", p.indent)) }
+
+// statements prints stmts one per line, with the comments that come before
+// end interleaved. A blank line in the source between two items is kept.
+func (p *printer) statements(stmts []ast.Statement, end int) {
+	first := true
+	for i, s := range stmts {
+		start := startOffset(s)
+		p.flushComments(start, &first)
+		if !first && p.blankBefore(start) {
+			p.write("\n")
+		}
+		first = false
+		p.writeIndent()
+		var next ast.Statement
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+		}
+		p.statement(s, next)
+		p.write("\n")
+	}
+	p.flushComments(end, &first)
+}
+
+// flushComments prints the pending comments before offset. A comment that
+// followed code on its line is appended to the last printed line.
+func (p *printer) flushComments(offset int, first *bool) {
+	for len(p.comments) > 0 && p.comments[0].Pos.Offset < offset {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		text := strings.TrimRight(c.Text, " \t\r")
+		if p.trailing(c) && bytes.HasSuffix(p.out.Bytes(), []byte("\n")) {
+			p.out.Truncate(p.out.Len() - 1)
+			p.write(" " + text + "\n")
+			continue
+		}
+		if !*first && p.blankBefore(c.Pos.Offset) {
+			p.write("\n")
+		}
+		*first = false
+		p.writeIndent()
+		p.write(text + "\n")
+	}
+}
+
+func (p *printer) commentBefore(offset int) bool {
+	return len(p.comments) > 0 && p.comments[0].Pos.Offset < offset
+}
+
+// trailing reports whether code precedes c on its line.
+func (p *printer) trailing(c token.Comment) bool {
+	i := c.Pos.Offset - 1
+	for i >= 0 && (p.src[i] == ' ' || p.src[i] == '\t') {
+		i--
+	}
+	return i >= 0 && p.src[i] != '\n'
+}
+
+// blankBefore reports whether an empty line separates offset from the code
+// or comment before it.
+func (p *printer) blankBefore(offset int) bool {
+	newlines := 0
+	for i := offset - 1; i >= 0 && strings.IndexByte(" \t\r\n", p.src[i]) >= 0; i-- {
+		if p.src[i] == '\n' {
+			newlines++
+		}
+	}
+	return newlines >= 2
+}
+
+// startOffset is where a statement's first token begins. Most statements
+// start at their own token; an assignment's token is its '='.
+func startOffset(s ast.Statement) int {
+	if as, ok := s.(*ast.AssignmentStatement); ok {
+		return as.Name.Token.Pos.Offset
+	}
+	return s.Pos().Offset
+}
+
+func (p *printer) statement(s ast.Statement, next ast.Statement) {
+	switch n := s.(type) {
+	case *ast.LetStatement:
+		p.write("let " + n.Name.String() + " = ")
+		p.expr(n.Value, parser.LOWEST, false)
+		p.write(";")
+	case *ast.AssignmentStatement:
+		p.write(n.Name.Value + " = ")
+		p.expr(n.Value, parser.LOWEST, false)
+		p.write(";")
+	case *ast.ReturnStatement:
+		if n.ReturnValue == nil {
+			p.write("return;")
+			break
+		}
+		p.write("return ")
+		p.expr(n.ReturnValue, parser.LOWEST, false)
+		p.write(";")
+	case *ast.PrintStatement:
+		p.write("print(")
+		p.expr(n.Value, parser.LOWEST, false)
+		p.write(");")
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expr(n.Value, parser.LOWEST, false)
+		p.write(";")
+	case *ast.ExpressionStatement:
+		p.expr(n.Expression, parser.LOWEST, true)
+		// an if statement needs no semicolon unless the next statement
+		// would otherwise continue it as an operand
+		if _, ok := n.Expression.(*ast.IfExpression); !ok || continues(next) {
+			p.write(";")
+		}
+	case *ast.FunctionStatement:
+		p.write("fn " + n.Name.Value)
+		p.params(n.Parameters, n.ReturnType)
+		p.block(n.Body)
+	case *ast.WhileStatement:
+		p.write("while (")
+		p.expr(n.Condition, parser.LOWEST, false)
+		p.write(") ")
+		p.block(n.Body)
+	case *ast.TryStatement:
+		p.write("try ")
+		p.block(n.Block)
+		if n.CatchBlock != nil {
+			p.write(" catch ")
+			if n.CatchParam != nil {
+				p.write("(" + n.CatchParam.Value + ") ")
+			}
+			p.block(n.CatchBlock)
+		}
+		if n.FinallyBlock != nil {
+			p.write(" finally ")
+			p.block(n.FinallyBlock)
+		}
+	case *ast.BlockStatement:
+		p.block(n)
+	case *ast.ImportStatement:
+		p.write("import " + token.Quote(n.Path) + " as " + n.Alias.Value + ";")
+	case *ast.ExportStatement:
+		p.write("export ")
+		p.statement(n.Statement, next)
+	}
+}
+
+func (p *printer) block(b *ast.BlockStatement) {
+	if len(b.Statements) == 0 && !p.commentBefore(b.Rbrace.Offset) {
+		p.write("{}")
+		return
+	}
+	p.write("{\n")
+	p.indent++
+	p.statements(b.Statements, b.Rbrace.Offset)
+	p.indent--
+	p.writeIndent()
+	p.write("}")
+}
+
+// params prints a parameter list with its annotations and the result
+// type, if any.
+func (p *printer) params(params []*ast.Identifier, result ast.TypeExpr) {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.String()
+	}
+	p.write("(" + strings.Join(names, ", ") + ") ")
+	if result != nil {
+		p.write("-> " + result.String() + " ")
+	}
+}
+
+var precedences = map[string]int{
+	"==": parser.EQUALS,
+	"!=": parser.EQUALS,
+	"<":  parser.LESSGREATER,
+	">":  parser.LESSGREATER,
+	"<=": parser.LESSGREATER,
+	">=": parser.LESSGREATER,
+	"+":  parser.SUM,
+	"-":  parser.SUM,
+	"*":  parser.PRODUCT,
+	"/":  parser.PRODUCT,
+}
+
+// precedence is how tightly e binds; literals, identifiers, if and fn
+// expressions bind tighter than any operator.
+func precedence(e ast.Expression) int {
+	switch n := e.(type) {
+	case *ast.InfixExpression:
+		return precedences[n.Operator]
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression:
+		return parser.CALL
+	case *ast.SelectorExpression:
+		return parser.SELECTOR
+	}
+	return parser.SELECTOR + 1
+}
+
+// expr prints e, in parentheses if it binds looser than min. atStart is
+// set while e begins a statement, where fn would start a function
+// statement, so a function literal there is parenthesized.
+func (p *printer) expr(e ast.Expression, min int, atStart bool) {
+	if precedence(e) < min {
+		p.write("(")
+		p.expr(e, parser.LOWEST, false)
+		p.write(")")
+		return
+	}
+	switch n := e.(type) {
+	case *ast.Identifier:
+		p.write(n.Value)
+	case *ast.IntegerLiteral:
+		p.write(n.Token.Literal)
+	case *ast.Boolean:
+		p.write(n.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(token.Quote(n.Value))
+	case *ast.ArrayLiteral:
+		p.write("[")
+		p.list(n.Elements)
+		p.write("]")
+	case *ast.IndexExpression:
+		p.expr(n.Left, parser.CALL, atStart)
+		p.write("[")
+		p.expr(n.Index, parser.LOWEST, false)
+		p.write("]")
+	case *ast.PrefixExpression:
+		p.write(n.Operator)
+		p.expr(n.Right, parser.PREFIX, false)
+	case *ast.InfixExpression:
+		prec := precedences[n.Operator]
+		p.expr(n.Left, prec, atStart)
+		p.write(" " + n.Operator + " ")
+		// operators are left-associative: an equal operator on the right
+		// was grouped explicitly
+		p.expr(n.Right, prec+1, false)
+	case *ast.CallExpression:
+		p.expr(n.Function, parser.CALL, atStart)
+		p.write("(")
+		p.list(n.Arguments)
+		p.write(")")
+	case *ast.SelectorExpression:
+		p.expr(n.X, parser.SELECTOR, atStart)
+		p.write("." + n.Sel.Value)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expr(n.Condition, parser.LOWEST, false)
+		p.write(") ")
+		p.block(n.Consequence)
+		if n.Alternative != nil {
+			p.write(" else ")
+			p.block(n.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		if atStart {
+			p.write("(")
+		}
+		p.write("fn")
+		p.params(n.Parameters, n.ReturnType)
+		p.block(n.Body)
+		if atStart {
+			p.write(")")
+		}
+	}
+}
+
+// list prints expressions separated by commas.
+func (p *printer) list(exprs []ast.Expression) {
+	for i, e := range exprs {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expr(e, parser.LOWEST, false)
+	}
+}
+
+// continues reports whether s, printed after an if statement without a
+// semicolon, would be parsed as part of it: that happens when s starts
+// with '(', '[' or '-', which are also infix operators.
+func continues(s ast.Statement) bool {
+	es, ok := s.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	e := es.Expression
+	for {
+		switch n := e.(type) {
+		case *ast.InfixExpression:
+			if precedence(n.Left) < precedences[n.Operator] {
+				return true
+			}
+			e = n.Left
+		case *ast.CallExpression:
+			if precedence(n.Function) < parser.CALL {
+				return true
+			}
+			e = n.Function
+		case *ast.IndexExpression:
+			if precedence(n.Left) < parser.CALL {
+				return true
+			}
+			e = n.Left
+		case *ast.SelectorExpression:
+			if precedence(n.X) < parser.SELECTOR {
+				return true
+			}
+			e = n.X
+		case *ast.PrefixExpression:
+			return n.Operator == "-"
+		case *ast.FunctionLiteral, *ast.ArrayLiteral:
+			return true
+		default:
+			return false
+		}
+	}
+}
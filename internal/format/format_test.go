@@ -0,0 +1,98 @@
+package format_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mingo/internal/format"
+	"mingo/internal/lexer"
+	"mingo/internal/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"let   x=1+2*3;print( x )", "let x = 1 + 2 * 3;\nprint(x);\n"},
+		// only the parentheses the grammar needs survive
+		{"let a = ((1 + 2)) * (3 - (4 - 5)) - (6 - 7);", "let a = (1 + 2) * (3 - (4 - 5)) - (6 - 7);\n"},
+		{"let b = -(a * b) + (-a) * b + !(a == b);", "let b = -(a * b) + -a * b + !(a == b);\n"},
+		{"let c = (f(1))(2) + (fn(x) { x; })(3);", "let c = f(1)(2) + fn(x) {\n  x;\n}(3);\n"},
+		// fn at the start of a statement would start a declaration
+		{"(fn() { 1; })();", "(fn() {\n  1;\n})();\n"},
+		{"fn f(a,b){if(a<b){return a;}else{return b;}}", "fn f(a, b) {\n  if (a < b) {\n    return a;\n  } else {\n    return b;\n  }\n}\n"},
+		{"while (true) {}", "while (true) {}\n"},
+		{"try { throw 1; } catch { print(2); } finally { print(3); }", "try {\n  throw 1;\n} catch {\n  print(2);\n} finally {\n  print(3);\n}\n"},
+		// an if followed by '-' or '(' keeps its semicolon
+		{"if (x) { 1; }; -1; if (x) { 2; } print(3);", "if (x) {\n  1;\n};\n-1;\nif (x) {\n  2;\n}\nprint(3);\n"},
+		// comments and single blank lines are kept
+		{"// head\n\n\nlet x = 1; // one\n\n\n\n// before y\nlet y = 2;\n", "// head\n\nlet x = 1; // one\n\n// before y\nlet y = 2;\n"},
+		{"fn f() { // opens\n\n  // inside\n  1;   // after\n\n  // last\n}\n// end", "fn f() { // opens\n  // inside\n  1; // after\n\n  // last\n}\n// end\n"},
+		{"let f = fn() {\n// only\n};", "let f = fn() {\n  // only\n};\n"},
+		{`import "lib.mg"as lib
+export fn f(){lib.g(lib.x);}`, "import \"lib.mg\" as lib;\nexport fn f() {\n  lib.g(lib.x);\n}\n"},
+		{`let s="𝒳\t"+t;print([1,-2,["a"]][0][i+1]);`, "let s = \"𝒳\\t\" + t;\nprint([1, -2, [\"a\"]][0][i + 1]);\n"},
+		{"let a = (-xs)[0] + -(xs[0]) + (fn() { [1]; })()[0];", "let a = (-xs)[0] + -xs[0] + fn() {\n  [1];\n}()[0];\n"},
+		// a statement starting with '[' would index the if before it
+		{"if (x) { 1; }; [2];", "if (x) {\n  1;\n};\n[2];\n"},
+		{"let n:int=1; fn f(a:int,g:fn(int)->bool)->bool{g(a);}", "let n: int = 1;\nfn f(a: int, g: fn(int) -> bool) -> bool {\n  g(a);\n}\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := format.Source(tt.input)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.input, err)
+		}
+		if got != tt.expected {
+			t.Fatalf("%q formatted wrong.\nwant=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+		again, err := format.Source(got)
+		if err != nil || again != got {
+			t.Fatalf("%q: formatting is not idempotent.\nfirst=%q\nsecond=%q (%v)", tt.input, got, again, err)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	_, err := format.Source("let x = 1;\nlet = 2;")
+	if err == nil || err.Error() != "2:5: expected next token to be IDENT, got ASSIGN instead" {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+}
+
+// Formatting the examples keeps their meaning and is stable.
+func TestExamples(t *testing.T) {
+	files, _ := filepath.Glob("../../examples/*.mg")
+	if len(files) == 0 {
+		t.Fatalf("no examples found")
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := format.Source(string(src))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if again, _ := format.Source(got); again != got {
+			t.Fatalf("%s: formatting is not idempotent.\nfirst=%q\nsecond=%q", file, got, again)
+		}
+		if want, have := parse(t, string(src)), parse(t, got); want != have {
+			t.Fatalf("%s: formatting changed the program.\nwant=%q\ngot=%q", file, want, have)
+		}
+	}
+}
+
+func parse(t *testing.T, src string) string {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	return program.String()
+}
@@ -0,0 +1,402 @@
+// Package gen generates random programs for property tests of the
+// toolchain, and shrinks a program that breaks a property to a smaller one
+// that still does.
+//
+// The programs use lets, assignments, integer and boolean arithmetic, if,
+// while and calls of functions. They are well-typed, with some of their
+// types annotated, and raise no errors: they only divide by non-zero
+// constants and only call functions defined before the caller, so that
+// nothing recurses. Every while loop counts up to a constant, so every
+// program ends. A program finishes by printing its top-level variables.
+//
+// ProgramWithErrors adds code that raises errors, and try statements that
+// catch some of them, to the same kinds of programs.
+package gen
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"mingo/internal/types"
+)
+
+// Program returns a random program drawn from r.
+func Program(r *rand.Rand) string {
+	g := &generator{r: r}
+	return g.program()
+}
+
+// ProgramWithErrors returns a random program drawn from r that can raise
+// errors: it throws, divides by any value, indexes arrays out of range,
+// mixes up types and calls functions with the wrong number of arguments.
+// Most top-level statements are in try statements that print the error,
+// so the program runs on past it. It also calls the arrays and testing
+// modules. It still ends, but is not well-typed.
+func ProgramWithErrors(r *rand.Rand) string {
+	g := &generator{r: r, errors: true}
+	return g.program()
+}
+
+type variable struct {
+	name string
+	typ  types.Type
+}
+
+type function struct {
+	name   string
+	params []types.Type
+	result types.Type
+}
+
+type generator struct {
+	r       *rand.Rand
+	b       strings.Builder
+	errors  bool
+	vars    []variable // in scope and assignable
+	reads   []variable // loop counters, which are only read
+	funcs   []function // the functions defined so far
+	result  types.Type // of the function being written, nil outside one
+	globals []variable // the variables outside the function being written
+	names   int
+	depth   int
+	tries   int // try and catch blocks being written
+}
+
+func (g *generator) program() string {
+	if g.errors {
+		g.b.WriteString("import \"arrays\" as arrays;\nimport \"testing\" as testing;\n")
+	}
+	for range 1 + g.r.IntN(3) {
+		g.guarded(g.let)
+	}
+	for range g.r.IntN(4) {
+		g.function()
+	}
+	for range 3 + g.r.IntN(10) {
+		g.guarded(g.statement)
+	}
+	for _, v := range g.vars {
+		fmt.Fprintf(&g.b, "print(%s);\n", v.name)
+	}
+	return g.b.String()
+}
+
+// guarded writes a statement, with errors mostly inside a try statement
+// printing the error it raises.
+func (g *generator) guarded(statement func()) {
+	if !g.errors || g.r.IntN(8) == 0 {
+		statement()
+		return
+	}
+	g.b.WriteString("try {\n")
+	g.tries++
+	statement()
+	g.tries--
+	fmt.Fprintf(&g.b, "} catch (%s) {\nprint(%[1]s);\n}\n", g.name("e"))
+}
+
+func (g *generator) name(prefix string) string {
+	g.names++
+	return fmt.Sprintf("%s%d", prefix, g.names)
+}
+
+func (g *generator) typ() types.Type {
+	if g.r.IntN(3) == 0 {
+		return types.Bool
+	}
+	return types.Int
+}
+
+// annotation returns ": t" for some of the names it is asked for.
+func (g *generator) annotation(t types.Type) string {
+	if g.r.IntN(3) == 0 {
+		return ": " + t.String()
+	}
+	return ""
+}
+
+func (g *generator) let() {
+	v := variable{name: g.name("v"), typ: g.typ()}
+	fmt.Fprintf(&g.b, "let %s%s = %s;\n", v.name, g.annotation(v.typ), g.expr(v.typ))
+	g.vars = append(g.vars, v)
+}
+
+func (g *generator) function() {
+	f := function{name: fmt.Sprintf("f%d", len(g.funcs)), result: g.typ()}
+	outer := g.vars
+	g.vars = g.vars[:len(g.vars):len(g.vars)]
+	params := make([]string, g.r.IntN(3))
+	for i := range params {
+		p := variable{name: g.name("p"), typ: g.typ()}
+		params[i] = p.name + g.annotation(p.typ)
+		f.params = append(f.params, p.typ)
+		g.vars = append(g.vars, p)
+	}
+	result := ""
+	if g.r.IntN(2) == 0 {
+		result = " -> " + f.result.String()
+	}
+	fmt.Fprintf(&g.b, "fn %s(%s)%s {\n", f.name, strings.Join(params, ", "), result)
+	g.result, g.globals = f.result, outer
+	for range g.r.IntN(4) {
+		g.statement()
+	}
+	fmt.Fprintf(&g.b, "%s;\n}\n", g.expr(f.result))
+	g.result, g.globals = nil, nil
+	g.vars = outer
+	g.funcs = append(g.funcs, f)
+}
+
+// block writes a block of statements. Names defined in it go out of scope
+// at its end: if it does not run they would be null.
+func (g *generator) block() {
+	vars := g.vars
+	g.b.WriteString("{\n")
+	for range 1 + g.r.IntN(3) {
+		g.statement()
+	}
+	g.b.WriteString("}")
+	g.vars = vars
+}
+
+func (g *generator) statement() {
+	g.depth++
+	defer func() { g.depth-- }()
+	if g.errors && g.r.IntN(6) == 0 {
+		g.faultStatement()
+		return
+	}
+	n := 5
+	if g.depth < 3 {
+		n = 8
+	}
+	switch g.r.IntN(n) {
+	case 0, 1:
+		fmt.Fprintf(&g.b, "print(%s);\n", g.expr(g.typ()))
+	case 2:
+		g.let()
+	case 3:
+		if len(g.vars) == 0 {
+			g.let()
+			return
+		}
+		v := g.vars[g.r.IntN(len(g.vars))]
+		fmt.Fprintf(&g.b, "%s = %s;\n", v.name, g.expr(v.typ))
+	case 4:
+		switch {
+		// a return compiles the finally blocks it runs, which must not
+		// use names the try statement defines later
+		case g.result != nil && g.tries == 0 && g.r.IntN(2) == 0:
+			fmt.Fprintf(&g.b, "return %s;\n", g.expr(g.result))
+		case len(g.funcs) > 0:
+			fmt.Fprintf(&g.b, "%s;\n", g.call(g.funcs[g.r.IntN(len(g.funcs))]))
+		default:
+			fmt.Fprintf(&g.b, "print(%s);\n", g.expr(types.Int))
+		}
+	case 5, 6:
+		fmt.Fprintf(&g.b, "if (%s) ", g.expr(types.Bool))
+		g.block()
+		if g.r.IntN(2) == 0 {
+			g.b.WriteString(" else ")
+			g.block()
+		}
+		// so that a ( starting the next statement is not a call of the
+		// if expression
+		g.b.WriteString(";\n")
+	case 7:
+		// Shrink relies on the counter being incremented first
+		i := variable{name: g.name("i"), typ: types.Int}
+		fmt.Fprintf(&g.b, "let %s = 0;\nwhile (%[1]s < %d) {\n%[1]s = %[1]s + 1;\n", i.name, g.r.IntN(5))
+		g.reads = append(g.reads, i)
+		vars := g.vars
+		for range 1 + g.r.IntN(3) {
+			g.statement()
+		}
+		g.vars = vars
+		g.reads = g.reads[:len(g.reads)-1]
+		g.b.WriteString("}\n")
+	}
+}
+
+func (g *generator) expr(t types.Type) string {
+	g.depth++
+	defer func() { g.depth-- }()
+	if g.errors && g.r.IntN(10) == 0 {
+		return g.fault(t)
+	}
+	n := 3
+	if g.depth < 5 {
+		n = 8
+	}
+	switch g.r.IntN(n) {
+	case 0:
+		if t == types.Bool {
+			return [...]string{"true", "false"}[g.r.IntN(2)]
+		}
+		return fmt.Sprint(g.r.IntN(100))
+	case 1, 2:
+		var vars []variable
+		for _, v := range append(g.vars[:len(g.vars):len(g.vars)], g.reads...) {
+			if v.typ == t {
+				vars = append(vars, v)
+			}
+		}
+		if len(vars) == 0 {
+			return g.expr(t)
+		}
+		return vars[g.r.IntN(len(vars))].name
+	case 3, 4:
+		if t == types.Bool {
+			if g.r.IntN(4) == 0 {
+				op := [...]string{"==", "!="}[g.r.IntN(2)]
+				return "(" + g.expr(types.Bool) + " " + op + " " + g.expr(types.Bool) + ")"
+			}
+			op := [...]string{"<", "<=", ">", ">=", "==", "!="}[g.r.IntN(6)]
+			return "(" + g.expr(types.Int) + " " + op + " " + g.expr(types.Int) + ")"
+		}
+		if g.r.IntN(5) == 0 {
+			return fmt.Sprintf("(%s / %d)", g.expr(types.Int), 1+g.r.IntN(9))
+		}
+		op := [...]string{"+", "-", "*"}[g.r.IntN(3)]
+		return "(" + g.expr(types.Int) + " " + op + " " + g.expr(types.Int) + ")"
+	case 5:
+		if t == types.Bool {
+			return "!" + g.expr(types.Bool)
+		}
+		return "-" + g.expr(types.Int)
+	case 6:
+		var funcs []function
+		for _, f := range g.funcs {
+			if f.result == t {
+				funcs = append(funcs, f)
+			}
+		}
+		if len(funcs) == 0 {
+			return g.expr(t)
+		}
+		return g.call(funcs[g.r.IntN(len(funcs))])
+	default:
+		return "(if (" + g.expr(types.Bool) + ") { " + g.expr(t) + " } else { " + g.expr(t) + " })"
+	}
+}
+
+func (g *generator) call(f function) string {
+	args := make([]string, len(f.params))
+	for i, t := range f.params {
+		args[i] = g.expr(t)
+	}
+	return fmt.Sprintf("%s(%s)", f.name, strings.Join(args, ", "))
+}
+
+// faultStatement writes a statement that can raise an error, or catches
+// one.
+func (g *generator) faultStatement() {
+	switch g.r.IntN(4) {
+	case 0:
+		fmt.Fprintf(&g.b, "throw %s;\n", g.expr(g.typ()))
+	case 1:
+		fmt.Fprintf(&g.b, "print(testing.assert_error(%s));\n", g.literal())
+	default:
+		if g.depth > 3 {
+			fmt.Fprintf(&g.b, "print(%s);\n", g.fault(g.typ()))
+			return
+		}
+		g.try()
+	}
+}
+
+// try writes a try statement whose catch block, if it has one, prints the
+// error it catches.
+func (g *generator) try() {
+	g.tries++
+	g.b.WriteString("try ")
+	g.block()
+	catch := g.r.IntN(3) > 0
+	if catch {
+		// the finally block is compiled before the catch block, so only
+		// the catch block can use its names
+		vars := g.vars
+		e := g.name("e")
+		fmt.Fprintf(&g.b, " catch (%s) {\nprint(%[1]s);\n", e)
+		for range g.r.IntN(3) {
+			g.statement()
+		}
+		g.b.WriteString("}")
+		g.vars = vars
+	}
+	g.tries--
+	if !catch || g.r.IntN(2) == 0 {
+		g.b.WriteString(" finally ")
+		g.block()
+	}
+	g.b.WriteString("\n")
+}
+
+// literal returns a function literal without parameters whose body is an
+// expression.
+func (g *generator) literal() string {
+	// a function literal cannot reach the locals around it
+	vars, reads := g.vars, g.reads
+	if g.result != nil {
+		g.vars, g.reads = g.globals, nil
+	}
+	defer func() { g.vars, g.reads = vars, reads }()
+	return "fn() { " + g.expr(g.typ()) + "; }"
+}
+
+// fault returns an expression that should have type t but can raise an
+// error.
+func (g *generator) fault(t types.Type) string {
+	switch g.r.IntN(6) {
+	case 0:
+		if g.r.IntN(2) == 0 {
+			return [...]string{`"a"`, `"bc"`, `""`}[g.r.IntN(3)]
+		}
+		if t == types.Bool {
+			return g.expr(types.Int)
+		}
+		return g.expr(types.Bool)
+	case 1:
+		return "[" + g.expr(t) + ", " + g.expr(t) + "][" + g.expr(types.Int) + "]"
+	case 2:
+		n := "(" + g.expr(types.Int) + " / " + g.expr(types.Int) + ")"
+		if t == types.Bool {
+			return "(" + n + " == " + g.expr(types.Int) + ")"
+		}
+		return n
+	case 3:
+		a := g.expr(g.typ())
+		switch g.r.IntN(3) {
+		case 0:
+			a = "[" + a + "]"
+		case 1:
+			a = "arrays.push([" + a + "], " + g.expr(g.typ()) + ")"
+		}
+		n := "arrays.len(" + a + ")"
+		if t == types.Bool {
+			return "(" + n + " > " + g.expr(types.Int) + ")"
+		}
+		return n
+	case 4:
+		// a call with one argument too many or too few
+		var funcs []function
+		for _, f := range g.funcs {
+			if f.result == t {
+				funcs = append(funcs, f)
+			}
+		}
+		if len(funcs) == 0 {
+			return g.expr(t)
+		}
+		f := funcs[g.r.IntN(len(funcs))]
+		if len(f.params) > 0 && g.r.IntN(2) == 0 {
+			f.params = f.params[1:]
+		} else {
+			f.params = append(f.params[:len(f.params):len(f.params)], g.typ())
+		}
+		return g.call(f)
+	default:
+		return "testing.assert_error(" + g.literal() + ")"
+	}
+}
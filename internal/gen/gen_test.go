@@ -0,0 +1,112 @@
+package gen_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"mingo/internal/compiler"
+	"mingo/internal/format"
+	"mingo/internal/gen"
+	"mingo/internal/lexer"
+	"mingo/internal/module"
+	"mingo/internal/parser"
+	"mingo/internal/vm"
+)
+
+const seeds = 200
+
+func program(seed uint64) string {
+	return gen.Program(rand.New(rand.NewPCG(seed, 49)))
+}
+
+// check reports the programs that break prop, shrunk.
+func check(t *testing.T, prop func(src string) error) {
+	t.Helper()
+	for seed := range uint64(seeds) {
+		src := program(seed)
+		if err := prop(src); err != nil {
+			small := gen.Shrink(src, func(src string) bool { return prop(src) != nil })
+			t.Errorf("seed %d: %v\nprogram:\n%s\nshrunk to:\n%s\n%v", seed, err, src, small, prop(small))
+		}
+	}
+}
+
+// Generated programs type-check and run without errors.
+func TestPrograms(t *testing.T) {
+	for seed := range uint64(seeds) {
+		src := program(seed)
+		mods, err := (&module.Loader{}).Load("", src)
+		if err != nil {
+			t.Fatalf("seed %d: %v\n%s", seed, err, src)
+		}
+		for _, d := range module.Check(mods) {
+			if d.Severity == compiler.SeverityError {
+				t.Errorf("seed %d: %v\n%s", seed, d, src)
+			}
+		}
+		if _, err := runVM(mods); err != nil {
+			t.Errorf("seed %d: %v\n%s", seed, err, src)
+		}
+	}
+}
+
+// Formatted programs parse to the same syntax tree.
+func TestFormatReparses(t *testing.T) {
+	check(t, func(src string) error {
+		want, ok := parse(src)
+		if !ok {
+			return nil
+		}
+		formatted, err := format.Source(src)
+		if err != nil {
+			return err
+		}
+		if got, ok := parse(formatted); !ok || got != want {
+			return fmt.Errorf("formatted as %q, which parses to %q", formatted, got)
+		}
+		return nil
+	})
+}
+
+func TestShrink(t *testing.T) {
+	for seed := range uint64(40) {
+		src := program(seed)
+		if !strings.Contains(src, "*") {
+			continue
+		}
+		got := gen.Shrink(src, func(src string) bool { return strings.Contains(src, "*") })
+		p := parser.New(lexer.New(got))
+		if program := p.ParseProgram(); len(program.Statements) != 1 || !strings.Contains(got, "(0 * 0)") {
+			t.Errorf("seed %d: shrunk to %q, want one statement such as %q", seed, got, "print((0 * 0));")
+		}
+	}
+}
+
+// Loops keep their counters, so shrinking a program leaves it ending.
+func TestShrinkKeepsLoopsBounded(t *testing.T) {
+	src := "let i = 0;\nwhile (i < 3) {\ni = i + 1;\nprint(i * 2);\n}\n"
+	got := gen.Shrink(src, func(src string) bool { return strings.Contains(src, "while") })
+	if want := "while ((i < 3)) { i = (i + 1); }"; got != want {
+		t.Errorf("wrong program.\nwant=%q\ngot=%q", want, got)
+	}
+}
+
+func runVM(mods []*module.Module) (string, error) {
+	comp := compiler.New()
+	if err := module.Compile(comp, mods); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	machine := vm.NewFromBytecode(comp.Bytecode(), nil)
+	machine.SetOutput(&out)
+	err := machine.Run()
+	return out.String(), err
+}
+
+func parse(src string) (string, bool) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	return program.String(), len(p.Errors()) == 0
+}
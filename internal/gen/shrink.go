@@ -0,0 +1,215 @@
+package gen
+
+import (
+	"mingo/internal/ast"
+	"mingo/internal/lexer"
+	"mingo/internal/parser"
+	"mingo/internal/token"
+)
+
+// Shrink returns the smallest variant of src it finds for which fails
+// still returns true, src itself if there is none. It tries one reduction
+// at a time, keeping each that fails: dropping a statement or an else
+// block, replacing a statement with those of its body, or replacing an
+// expression with one of its operands, the value of one of its branches
+// or 0. Variants are printed from the syntax tree, so they are formatted
+// like ast.Program.String.
+//
+// The condition of each while loop and the first statement of its body,
+// which Program makes the increment of the loop's counter, are kept, so
+// no variant of a generated program loops forever. A variant can fail to
+// compile, or even to parse; fails should return false for those unless
+// that is the failure being shrunk.
+func Shrink(src string, fails func(src string) bool) string {
+	for {
+		shrunk := false
+		for k := 0; ; {
+			variant, ok := reduce(src, k)
+			if !ok {
+				break
+			}
+			if variant != src && fails(variant) {
+				// the k-th reduction is now the one after it; those
+				// before it are tried again in the next pass
+				src, shrunk = variant, true
+				continue
+			}
+			k++
+		}
+		if !shrunk {
+			return src
+		}
+	}
+}
+
+// reduce applies the k-th reduction of src in the order of ast.Rewrite. It
+// reports false when src has no more than k.
+func reduce(src string, k int) (string, bool) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return "", false
+	}
+	r := &reducer{k: k, keep: map[ast.Node]bool{}}
+	keep := func(n ast.Node) bool {
+		if n != nil {
+			r.keep[n] = true
+		}
+		return true
+	}
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.WhileStatement:
+			ast.Inspect(n.Condition, keep)
+			if len(n.Body.Statements) > 0 {
+				ast.Inspect(n.Body.Statements[0], keep)
+			}
+		// names, unlike identifiers as values, cannot become 0
+		case *ast.LetStatement:
+			r.keep[n.Name] = true
+		case *ast.AssignmentStatement:
+			r.keep[n.Name] = true
+		case *ast.FunctionStatement:
+			r.keep[n.Name] = true
+			for _, p := range n.Parameters {
+				r.keep[p] = true
+			}
+		case *ast.FunctionLiteral:
+			for _, p := range n.Parameters {
+				r.keep[p] = true
+			}
+		case *ast.TryStatement:
+			if n.CatchParam != nil {
+				r.keep[n.CatchParam] = true
+			}
+		case *ast.ImportStatement:
+			r.keep[n.Alias] = true
+		case *ast.CallExpression:
+			r.keep[n.Function] = true
+		case *ast.SelectorExpression:
+			r.keep[n.X] = true
+			r.keep[n.Sel] = true
+		}
+		return true
+	})
+	ast.Rewrite(program, r.reduce)
+	if r.seen <= k {
+		return "", false
+	}
+	return program.String(), true
+}
+
+type reducer struct {
+	k    int // the reduction to apply
+	seen int // the number of reductions passed
+	keep map[ast.Node]bool
+}
+
+// next reports whether the reduction just found is the one to apply.
+func (r *reducer) next() bool {
+	r.seen++
+	return r.seen == r.k+1
+}
+
+func (r *reducer) reduce(node ast.Node) ast.Node {
+	if r.keep[node] || r.seen > r.k {
+		return node
+	}
+	switch n := node.(type) {
+	case *ast.Program:
+		n.Statements = r.splice(n.Statements)
+		return n
+	case *ast.BlockStatement:
+		n.Statements = r.splice(n.Statements)
+		return n
+	case *ast.ExportStatement:
+		return node
+	case *ast.IfExpression:
+		if n.Alternative != nil && r.next() {
+			n.Alternative = nil
+			return n
+		}
+		return r.expression(n, n.Condition, value(n.Consequence), value(n.Alternative))
+	case ast.Statement:
+		if r.next() {
+			return nil
+		}
+		return node
+	case *ast.InfixExpression:
+		return r.expression(n, n.Left, n.Right)
+	case *ast.PrefixExpression:
+		return r.expression(n, n.Right)
+	case *ast.IndexExpression:
+		return r.expression(n, n.Left, n.Index)
+	case *ast.CallExpression:
+		return r.expression(n, n.Arguments...)
+	case *ast.ArrayLiteral:
+		return r.expression(n, n.Elements...)
+	case *ast.IntegerLiteral:
+		if n.Value == 0 {
+			return n
+		}
+		return r.expression(n)
+	case ast.Expression:
+		return r.expression(n)
+	}
+	return node
+}
+
+// splice replaces a statement of stmts with the statements of its body, if
+// that is the reduction to apply: the consequence or the alternative of an
+// if, or the body of a while, function or try block.
+func (r *reducer) splice(stmts []ast.Statement) []ast.Statement {
+	for i, s := range stmts {
+		if r.keep[s] {
+			continue
+		}
+		for _, body := range bodies(s) {
+			if body != nil && r.next() {
+				return append(stmts[:i:i], append(body.Statements, stmts[i+1:]...)...)
+			}
+		}
+	}
+	return stmts
+}
+
+func bodies(s ast.Statement) []*ast.BlockStatement {
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		if n, ok := s.Expression.(*ast.IfExpression); ok {
+			return []*ast.BlockStatement{n.Consequence, n.Alternative}
+		}
+	case *ast.WhileStatement:
+		return []*ast.BlockStatement{s.Body}
+	case *ast.FunctionStatement:
+		return []*ast.BlockStatement{s.Body}
+	case *ast.TryStatement:
+		return []*ast.BlockStatement{s.Block, s.CatchBlock, s.FinallyBlock}
+	}
+	return nil
+}
+
+// value returns the expression a block ends with, or nil.
+func value(b *ast.BlockStatement) ast.Expression {
+	if b == nil || len(b.Statements) == 0 {
+		return nil
+	}
+	if s, ok := b.Statements[len(b.Statements)-1].(*ast.ExpressionStatement); ok {
+		return s.Expression
+	}
+	return nil
+}
+
+// expression replaces e with one of its operands or with 0, if one of
+// those is the reduction to apply.
+func (r *reducer) expression(e ast.Expression, operands ...ast.Expression) ast.Node {
+	for _, o := range operands {
+		if o != nil && r.next() {
+			return o
+		}
+	}
+	if r.next() {
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "0", Pos: e.Pos()}, Value: 0}
+	}
+	return e
+}
@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"fmt"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -8,6 +10,7 @@ import (
 )
 
 type Lexer struct {
+	file         string // recorded in every position
 	input        string
 	position     int // byte offset of current char
 	readPosition int // byte offset of next char
@@ -16,16 +19,31 @@ type Lexer struct {
 
 	line   int
 	column int
+
+	comments []token.Comment
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input, line: 1, column: 0}
+	return NewFile("", input)
+}
+
+// NewFile returns a lexer whose positions name file, for the source of an
+// imported module.
+func NewFile(file, input string) *Lexer {
+	l := &Lexer{file: file, input: input, line: 1, column: 0}
 	l.readRune()
 	return l
 }
 
 func (l *Lexer) readRune() {
 	if l.readPosition >= len(l.input) {
+		// the end of the input is a position of its own, one past the
+		// last character, so that literals ending there are read whole
+		if l.readPosition == len(l.input) {
+			l.position = l.readPosition
+			l.readPosition++
+			l.column++
+		}
 		l.width = 0
 		l.ch = 0
 		return
@@ -52,16 +70,34 @@ func (l *Lexer) peekRune() rune {
 	return r
 }
 
+// skipWhitespace skips spaces and // comments, recording the comments.
 func (l *Lexer) skipWhitespace() {
-	for unicode.IsSpace(l.ch) {
-		l.readRune()
+	for {
+		for unicode.IsSpace(l.ch) {
+			l.readRune()
+		}
+		if l.ch != '/' || l.peekRune() != '/' {
+			return
+		}
+		pos := token.Position{File: l.file, Line: l.line, Column: l.column, Offset: l.position}
+		for l.ch != '\n' && l.ch != 0 {
+			l.readRune()
+		}
+		end := l.position
+		if l.ch == 0 {
+			end = len(l.input)
+		}
+		l.comments = append(l.comments, token.Comment{Text: l.input[pos.Offset:end], Pos: pos})
 	}
 }
 
+// Comments returns the comments read so far, in source order.
+func (l *Lexer) Comments() []token.Comment { return l.comments }
+
 func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespace()
 
-	tok := token.Token{Pos: token.Position{Line: l.line, Column: l.column, Offset: l.position}}
+	tok := token.Token{Pos: token.Position{File: l.file, Line: l.line, Column: l.column, Offset: l.position}}
 
 	switch l.ch {
 	case '=':
@@ -77,8 +113,14 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Type = token.PLUS
 		tok.Literal = "+"
 	case '-':
-		tok.Type = token.MINUS
-		tok.Literal = "-"
+		if l.peekRune() == '>' {
+			l.readRune()
+			tok.Type = token.ARROW
+			tok.Literal = "->"
+		} else {
+			tok.Type = token.MINUS
+			tok.Literal = "-"
+		}
 	case '*':
 		tok.Type = token.ASTER
 		tok.Literal = "*"
@@ -118,12 +160,26 @@ func (l *Lexer) NextToken() token.Token {
 	case ';':
 		tok.Type = token.SEMICOLON
 		tok.Literal = ";"
+	case ':':
+		tok.Type = token.COLON
+		tok.Literal = ":"
+	case '.':
+		tok.Type = token.DOT
+		tok.Literal = "."
+	case '"':
+		tok.Type, tok.Literal = l.readString()
 	case '(':
 		tok.Type = token.LPAREN
 		tok.Literal = "("
 	case ')':
 		tok.Type = token.RPAREN
 		tok.Literal = ")"
+	case '[':
+		tok.Type = token.LBRACKET
+		tok.Literal = "["
+	case ']':
+		tok.Type = token.RBRACKET
+		tok.Literal = "]"
 	case '{':
 		tok.Type = token.LBRACE
 		tok.Literal = "{"
@@ -164,6 +220,38 @@ func (l *Lexer) readIdentifier() string {
 	return lit
 }
 
+// readString reads a string literal from its opening quote to, but not
+// past, the closing one, and returns its unescaped text. A string must end
+// on the line it starts; \n, \t, \" and \\ are its escapes.
+func (l *Lexer) readString() (token.Type, string) {
+	var out strings.Builder
+	for {
+		l.readRune()
+		switch l.ch {
+		case '"':
+			return token.STRING, out.String()
+		case 0, '\n':
+			return token.ILLEGAL, "unterminated string"
+		case '\\':
+			l.readRune()
+			switch l.ch {
+			case 'n':
+				out.WriteRune('\n')
+			case 't':
+				out.WriteRune('\t')
+			case '"', '\\':
+				out.WriteRune(l.ch)
+			case 0, '\n':
+				return token.ILLEGAL, "unterminated string"
+			default:
+				return token.ILLEGAL, fmt.Sprintf("unknown escape \\%c in string", l.ch)
+			}
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
 func (l *Lexer) readNumber() string {
 	start := l.position
 	for unicode.IsDigit(l.ch) {
@@ -1,6 +1,9 @@
 package lexer_test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"mingo/internal/lexer"
@@ -29,6 +32,8 @@ if (5 < 10) {
 11 >= 10;
 print(result);
 while (five < ten) { five = five + 1; }
+fn(a: int) -> int {} 1 - -1;
+[1, "𝒳"][0];
 `
 
 	tests := []struct {
@@ -135,6 +140,30 @@ while (five < ten) { five = five + 1; }
 		{token.INT, "1"},
 		{token.SEMICOLON, ";"},
 		{token.RBRACE, "}"},
+		{token.FN, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "->"},
+		{token.IDENT, "int"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.INT, "1"},
+		{token.MINUS, "-"},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.STRING, "𝒳"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 
@@ -152,3 +181,168 @@ while (five < ten) { five = five + 1; }
 		}
 	}
 }
+
+func TestKeywords(t *testing.T) {
+	input := "try throw catch finally trying"
+	expected := []token.Type{token.TRY, token.THROW, token.CATCH, token.FINALLY, token.IDENT, token.EOF}
+
+	l := lexer.New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Type != want {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q (literal %q)", i, want, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{`"lib/a.mg"`, token.STRING, "lib/a.mg"},
+		{`"tab\tnl\n\"q\" \\"`, token.STRING, "tab\tnl\n\"q\" \\"},
+		{`""`, token.STRING, ""},
+		{`"open`, token.ILLEGAL, "unterminated string"},
+		{"\"line\nbreak\"", token.ILLEGAL, "unterminated string"},
+		{`"\q"`, token.ILLEGAL, "unknown escape \\q in string"},
+	}
+	for _, tt := range tests {
+		tok := lexer.New(tt.input).NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("%q: wrong token. expected=%q %q, got=%q %q", tt.input, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	l := lexer.NewFile("lib/a.mg", "import \"b.mg\" as b;\nexport let x = b.y;")
+	expected := []token.Type{
+		token.IMPORT, token.STRING, token.IDENT, token.IDENT, token.SEMICOLON,
+		token.EXPORT, token.LET, token.IDENT, token.ASSIGN, token.IDENT, token.DOT, token.IDENT, token.SEMICOLON, token.EOF,
+	}
+	var last token.Token
+	for i, want := range expected {
+		last = l.NextToken()
+		if last.Type != want {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q (literal %q)", i, want, last.Type, last.Literal)
+		}
+		if i == 10 && last.Pos.String() != "lib/a.mg:2:17" {
+			t.Fatalf("wrong position for the dot. got=%s", last.Pos)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// head\nlet x = 4 / 2; // 𝒳 trailing\n//\nx // last"
+	expected := []token.Type{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SLASH, token.INT, token.SEMICOLON, token.IDENT, token.EOF}
+
+	l := lexer.New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Type != want {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q (literal %q)", i, want, tok.Type, tok.Literal)
+		}
+	}
+
+	comments := []token.Comment{
+		{Text: "// head", Pos: token.Position{Line: 1, Column: 1, Offset: 0}},
+		{Text: "// 𝒳 trailing", Pos: token.Position{Line: 2, Column: 16, Offset: 23}},
+		{Text: "//", Pos: token.Position{Line: 3, Column: 1, Offset: 40}},
+		{Text: "// last", Pos: token.Position{Line: 4, Column: 3, Offset: 45}},
+	}
+	got := l.Comments()
+	if len(got) != len(comments) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%+v", len(comments), got)
+	}
+	for i, want := range comments {
+		if got[i] != want {
+			t.Fatalf("comments[%d] wrong. expected=%+v, got=%+v", i, want, got[i])
+		}
+	}
+}
+
+// The end of the input follows its last character, which may end a name
+// or a number.
+func TestEndOfInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"", []token.Token{{Type: token.EOF, Pos: token.Position{Line: 1, Column: 1}}}},
+		{"x", []token.Token{
+			{Type: token.IDENT, Literal: "x", Pos: token.Position{Line: 1, Column: 1}},
+			{Type: token.EOF, Pos: token.Position{Line: 1, Column: 2, Offset: 1}},
+		}},
+		{"a 𝒳12", []token.Token{
+			{Type: token.IDENT, Literal: "a", Pos: token.Position{Line: 1, Column: 1}},
+			{Type: token.IDENT, Literal: "𝒳12", Pos: token.Position{Line: 1, Column: 3, Offset: 2}},
+			{Type: token.EOF, Pos: token.Position{Line: 1, Column: 6, Offset: 8}},
+		}},
+		{"12\n", []token.Token{
+			{Type: token.INT, Literal: "12", Pos: token.Position{Line: 1, Column: 1}},
+			{Type: token.EOF, Pos: token.Position{Line: 2, Column: 1, Offset: 3}},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		for i, want := range tt.expected {
+			if got := l.NextToken(); got != want {
+				t.Fatalf("%q: tokens[%d] wrong. expected=%+v, got=%+v", tt.input, i, want, got)
+			}
+		}
+	}
+}
+
+// NextToken ends every input with EOF, moving forward on each token.
+func FuzzNextToken(f *testing.F) {
+	addExamples(f)
+	f.Add("\"unterminated")
+	f.Add("let 𝒳 = 1 // \x00")
+	f.Fuzz(func(t *testing.T, input string) {
+		l := lexer.New(input)
+		var prev token.Token
+		// every token but EOF takes up at least one byte
+		for i := 0; ; i++ {
+			if i > len(input) {
+				t.Fatalf("%q: no EOF after %d tokens", input, i)
+			}
+			tok := l.NextToken()
+			if tok.Type == token.EOF {
+				// a NUL character ends the input early
+				if !strings.ContainsRune(input, 0) && tok.Pos.Offset != len(input) {
+					t.Fatalf("%q: EOF at %+v", input, tok.Pos)
+				}
+				break
+			}
+			if i > 0 && !after(tok.Pos, prev.Pos) {
+				t.Fatalf("%q: %s %q at %+v does not follow %s %q at %+v", input, tok.Type, tok.Literal, tok.Pos, prev.Type, prev.Literal, prev.Pos)
+			}
+			prev = tok
+		}
+	})
+}
+
+// after reports whether p lies past q in both offset and line:column.
+func after(p, q token.Position) bool {
+	if p.Offset <= q.Offset || p.Line < q.Line {
+		return false
+	}
+	return p.Line > q.Line || p.Column > q.Column
+}
+
+// addExamples seeds f with the example programs.
+func addExamples(f *testing.F) {
+	files, _ := filepath.Glob("../../examples/*.mg")
+	lib, _ := filepath.Glob("../../examples/lib/*.mg")
+	if len(files) == 0 {
+		f.Fatalf("no examples found")
+	}
+	for _, file := range append(files, lib...) {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(src))
+	}
+}
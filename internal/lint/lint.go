@@ -0,0 +1,174 @@
+// Package lint finds suspicious code in Mingo programs. Each check is a
+// Rule with an ID; rules run over the syntax tree and the resolved symbols
+// of a program and report Diagnostics. Rules can be turned off in a Config
+// or, for one line, with a comment:
+//
+//	let unused = 1; // lint:ignore unused-variable
+//
+// A lint:ignore comment on a line of its own applies to the next line, and
+// "// lint:file-ignore ID,ID" anywhere applies to the whole file.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"mingo/internal/ast"
+	"mingo/internal/lexer"
+	"mingo/internal/parser"
+	"mingo/internal/resolve"
+	"mingo/internal/token"
+)
+
+// Diagnostic is one finding. End is zero when only the start is known.
+type Diagnostic struct {
+	Rule string
+	Pos  token.Position
+	End  token.Position
+	Msg  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", d.Pos.Line, d.Pos.Column, d.Msg, d.Rule)
+}
+
+// Rule is one check. Run inspects the pass's program and calls Report for
+// each problem.
+type Rule struct {
+	ID  string
+	Doc string
+	Run func(*Pass)
+}
+
+// Pass is what a rule sees of the program being linted.
+type Pass struct {
+	Program *ast.Program
+	Info    *resolve.Info
+
+	rule  *Rule
+	diags []Diagnostic
+}
+
+// Report records a problem of the running rule.
+func (p *Pass) Report(pos, end token.Position, format string, args ...any) {
+	p.diags = append(p.diags, Diagnostic{Rule: p.rule.ID, Pos: pos, End: end, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Config turns rules on and off. Rules it does not mention are on.
+type Config struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// LoadConfig reads a JSON config file such as
+//
+//	{"rules": {"shadow": false}}
+//
+// and checks that it names only known rules.
+func LoadConfig(path string, rules []*Rule) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for id := range cfg.Rules {
+		if find(rules, id) == nil {
+			return nil, fmt.Errorf("%s: unknown rule %q", path, id)
+		}
+	}
+	return &cfg, nil
+}
+
+func (c *Config) enabled(r *Rule) bool {
+	if c == nil {
+		return true
+	}
+	on, ok := c.Rules[r.ID]
+	return !ok || on
+}
+
+func find(rules []*Rule, id string) *Rule {
+	for _, r := range rules {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// ParseErrors is the error Run returns for a program that does not parse.
+type ParseErrors []parser.ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, pe := range e {
+		msgs[i] = fmt.Sprintf("%d:%d: %s", pe.Pos.Line, pe.Pos.Column, pe.Msg)
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Run lints a program with the enabled rules and returns what they found,
+// minus suppressed findings, sorted by position.
+func Run(src string, rules []*Rule, cfg *Config) ([]Diagnostic, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.RichErrors(); len(errs) > 0 {
+		return nil, ParseErrors(errs)
+	}
+
+	pass := &Pass{Program: program, Info: resolve.Program(program)}
+	for _, r := range rules {
+		if cfg.enabled(r) {
+			pass.rule = r
+			r.Run(pass)
+		}
+	}
+
+	ignored := suppressions(src, l.Comments())
+	var diags []Diagnostic
+	for _, d := range pass.diags {
+		if !ignored[d.Pos.Line][d.Rule] && !ignored[0][d.Rule] {
+			diags = append(diags, d)
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Pos.Offset < diags[j].Pos.Offset })
+	return diags, nil
+}
+
+// suppressions maps a line to the rules ignored on it; line 0 holds the
+// rules ignored in the whole file.
+func suppressions(src string, comments []token.Comment) map[int]map[string]bool {
+	ignored := map[int]map[string]bool{}
+	add := func(line int, ids string) {
+		if ignored[line] == nil {
+			ignored[line] = map[string]bool{}
+		}
+		for _, id := range strings.Split(ids, ",") {
+			ignored[line][id] = true
+		}
+	}
+	for _, c := range comments {
+		fields := strings.Fields(strings.TrimPrefix(c.Text, "//"))
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "lint:ignore":
+			// the comment's own line, for a trailing comment, or the next
+			add(c.Pos.Line, fields[1])
+			start := strings.LastIndexByte(src[:c.Pos.Offset], '\n') + 1
+			if strings.TrimSpace(src[start:c.Pos.Offset]) == "" {
+				add(c.Pos.Line+1, fields[1])
+			}
+		case "lint:file-ignore":
+			add(0, fields[1])
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,122 @@
+package lint_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mingo/internal/lint"
+)
+
+func run(t *testing.T, input string, cfg *lint.Config) []string {
+	t.Helper()
+	diags, err := lint.Run(input, lint.Rules, cfg)
+	if err != nil {
+		t.Fatalf("%q: %v", input, err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	return got
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1; print(a);", nil},
+		{"let a = 1; fn f() { let b = 2; }", []string{
+			"1:5: a is declared but never used (unused-variable)",
+			"1:25: b is declared but never used (unused-variable)",
+		}},
+		{"fn f(a, b) { b; } f(1, 2);", []string{"1:6: parameter a of f is never used (unused-parameter)"}},
+		{"let a = 1; a = 2; fn f(x) { x = 1; }", []string{
+			"1:5: a is assigned but never read (assigned-not-read)",
+			"1:24: x is assigned but never read (assigned-not-read)",
+		}},
+		{"let a = 1; fn f(a) { let c = a; let c = c + 1; c; } f(a);", []string{
+			"1:17: a shadows the global declared at line 1 (shadow)",
+			"1:37: c redeclares c from line 1 (shadow)",
+		}},
+		// a later global is not visible in an earlier function
+		{"fn f() { let a = 1; a; } let a = 2; print(a); f();", nil},
+		{"if (1 < 2) { print(1); } while (!false) { print(2); } let x = 1; if (x < 2) { print(x); }", []string{
+			"1:5: if condition is constant (constant-condition)",
+			"1:33: while condition is constant (constant-condition)",
+		}},
+		{"while (true) { print(1); }", []string{"1:1: while (true) loop has no return or throw, so it never ends (infinite-loop)"}},
+		{"fn f() { while (true) { if (g()) { return 1; } } } fn g() { while (true) { throw 1; } }", nil},
+		{"fn f() { while (true) { let h = fn() { return 1; }; h(); } }", []string{
+			"1:10: while (true) loop has no return or throw, so it never ends (infinite-loop)",
+		}},
+		{"fn f() { return 1; print(2); print(3); } fn g() { if (f()) { throw 1; g(); } }", []string{
+			"1:20: unreachable code (unreachable)",
+			"1:71: unreachable code (unreachable)",
+		}},
+		{"let a = 1; print(1 == true); print(-1 < !false); print(a == 1);", []string{
+			"1:18: comparison of int literal with bool literal (literal-compare)",
+			"1:36: comparison of int literal with bool literal (literal-compare)",
+		}},
+		{"fn f(a, b) { a + b; } f(1); f(1, 2); f(1, 2, 3); let g = fn(x) { x; }; g();", []string{
+			"1:23: f takes 2 arguments but is called with 1 (call-arity)",
+			"1:38: f takes 2 arguments but is called with 3 (call-arity)",
+		}},
+		{"fn one(a) { a; } one();", []string{"1:18: one takes 1 argument but is called with 0 (call-arity)"}},
+	}
+
+	for _, tt := range tests {
+		got := run(t, tt.input, nil)
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: wrong diagnostics.\nwant=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	input := `// lint:file-ignore unused-parameter
+fn f(a) { 1; }
+let b = 1; // lint:ignore unused-variable
+// lint:ignore unused-variable,shadow
+let b = 2;
+let c = 3; // lint:ignore shadow
+let d = 4; // lint:ignore unused-variable
+let e = 5;
+`
+	got := run(t, input, nil)
+	expected := []string{
+		"6:5: c is declared but never used (unused-variable)",
+		"8:5: e is declared but never used (unused-variable)",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("wrong diagnostics.\nwant=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lint.json")
+	os.WriteFile(path, []byte(`{"rules": {"unused-variable": false, "shadow": true}}`), 0o644)
+	cfg, err := lint.LoadConfig(path, lint.Rules)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	got := run(t, "let a = 1; let a = 2;", cfg)
+	if strings.Join(got, "\n") != "1:16: a redeclares a from line 1 (shadow)" {
+		t.Fatalf("wrong diagnostics. got=%q", got)
+	}
+
+	os.WriteFile(path, []byte(`{"rules": {"no-such-rule": false}}`), 0o644)
+	if _, err := lint.LoadConfig(path, lint.Rules); err == nil || !strings.Contains(err.Error(), `unknown rule "no-such-rule"`) {
+		t.Fatalf("unknown rule accepted. got=%v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := lint.Run("let = 1;", lint.Rules, nil)
+	if _, ok := err.(lint.ParseErrors); !ok || !strings.HasPrefix(err.Error(), "1:5: expected next token to be IDENT") {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+}
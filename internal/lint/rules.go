@@ -0,0 +1,216 @@
+package lint
+
+import (
+	"fmt"
+
+	"mingo/internal/ast"
+	"mingo/internal/resolve"
+	"mingo/internal/token"
+)
+
+// Rules are the built-in rules, in the order they run.
+var Rules = []*Rule{
+	{ID: "unused-variable", Doc: "let binding that is never used", Run: unusedVariables},
+	{ID: "unused-parameter", Doc: "function parameter that is never used", Run: unusedParameters},
+	{ID: "assigned-not-read", Doc: "variable that is assigned but never read", Run: assignedNotRead},
+	{ID: "shadow", Doc: "definition that hides a global or an earlier definition in the same scope", Run: shadowing},
+	{ID: "constant-condition", Doc: "if or while condition built only from literals", Run: constantConditions},
+	{ID: "infinite-loop", Doc: "while (true) loop without a return or throw", Run: infiniteLoops},
+	{ID: "unreachable", Doc: "code after a return or throw", Run: unreachable},
+	{ID: "literal-compare", Doc: "comparison of literals of different types", Run: literalCompares},
+	{ID: "call-arity", Doc: "call of a fn statement with the wrong number of arguments", Run: callArity},
+}
+
+// letNames returns the identifiers that let statements define, except
+// exported ones, which importers may use.
+func letNames(program *ast.Program) map[*ast.Identifier]bool {
+	names := map[*ast.Identifier]bool{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ExportStatement:
+			return false
+		case *ast.LetStatement:
+			names[n.Name] = true
+		}
+		return true
+	})
+	return names
+}
+
+func unusedVariables(p *Pass) {
+	lets := letNames(p.Program)
+	for _, sym := range p.Info.Symbols {
+		if lets[sym.Def] && len(sym.Refs) == 0 {
+			p.Report(sym.Def.Pos(), ast.End(sym.Def.Token), "%s is declared but never used", sym.Name)
+		}
+	}
+}
+
+func unusedParameters(p *Pass) {
+	for _, sym := range p.Info.Symbols {
+		if sym.Kind == resolve.Parameter && len(sym.Refs) == 0 {
+			p.Report(sym.Def.Pos(), ast.End(sym.Def.Token), "parameter %s of %s is never used", sym.Name, sym.Owner)
+		}
+	}
+}
+
+func assignedNotRead(p *Pass) {
+	lets := letNames(p.Program)
+	for _, sym := range p.Info.Symbols {
+		if (lets[sym.Def] || sym.Kind == resolve.Parameter) && len(sym.Refs) > 0 && sym.Reads() == 0 {
+			p.Report(sym.Def.Pos(), ast.End(sym.Def.Token), "%s is assigned but never read", sym.Name)
+		}
+	}
+}
+
+func shadowing(p *Pass) {
+	for _, sym := range p.Info.Symbols {
+		prev := sym.Shadows
+		switch {
+		case prev == nil:
+		case prev.Owner != sym.Owner:
+			p.Report(sym.Def.Pos(), ast.End(sym.Def.Token), "%s shadows the global declared at line %d", sym.Name, prev.Def.Pos().Line)
+		default:
+			p.Report(sym.Def.Pos(), ast.End(sym.Def.Token), "%s redeclares %s from line %d", sym.Name, sym.Name, prev.Def.Pos().Line)
+		}
+	}
+}
+
+func constantConditions(p *Pass) {
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfExpression:
+			if constant(n.Condition) {
+				p.Report(ast.Start(n.Condition), token.Position{}, "if condition is constant")
+			}
+		case *ast.WhileStatement:
+			if b, ok := n.Condition.(*ast.Boolean); ok && b.Value {
+				break // the infinite-loop rule's business
+			}
+			if constant(n.Condition) {
+				p.Report(ast.Start(n.Condition), token.Position{}, "while condition is constant")
+			}
+		}
+		return true
+	})
+}
+
+// constant reports whether e is built only from literals and operators.
+func constant(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.StringLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return constant(e.Right)
+	case *ast.InfixExpression:
+		return constant(e.Left) && constant(e.Right)
+	}
+	return false
+}
+
+func infiniteLoops(p *Pass) {
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		loop, ok := n.(*ast.WhileStatement)
+		if !ok {
+			return true
+		}
+		if b, ok := loop.Condition.(*ast.Boolean); ok && b.Value && !exits(loop.Body) {
+			p.Report(loop.Pos(), token.Position{}, "while (true) loop has no return or throw, so it never ends")
+		}
+		return true
+	})
+}
+
+// exits reports whether a return or throw of the enclosing function occurs
+// in node; those in nested functions do not count.
+func exits(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			found = true
+		case *ast.FunctionLiteral, *ast.FunctionStatement:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+func unreachable(p *Pass) {
+	check := func(stmts []ast.Statement) {
+		for i, s := range stmts[:max(len(stmts)-1, 0)] {
+			switch s.(type) {
+			case *ast.ReturnStatement, *ast.ThrowStatement:
+				p.Report(ast.Start(stmts[i+1]), token.Position{}, "unreachable code")
+				return
+			}
+		}
+	}
+	check(p.Program.Statements)
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		if b, ok := n.(*ast.BlockStatement); ok {
+			check(b.Statements)
+		}
+		return true
+	})
+}
+
+func literalCompares(p *Pass) {
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		in, ok := n.(*ast.InfixExpression)
+		if !ok {
+			return true
+		}
+		switch in.Operator {
+		case "==", "!=", "<", ">", "<=", ">=":
+			l, r := literalType(in.Left), literalType(in.Right)
+			if l != "" && r != "" && l != r {
+				p.Report(ast.Start(in.Left), token.Position{}, "comparison of %s literal with %s literal", l, r)
+			}
+		}
+		return true
+	})
+}
+
+func literalType(e ast.Expression) string {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return "int"
+	case *ast.Boolean:
+		return "bool"
+	case *ast.StringLiteral:
+		return "string"
+	case *ast.PrefixExpression:
+		if t := literalType(e.Right); t == "int" && e.Operator == "-" || t == "bool" && e.Operator == "!" {
+			return t
+		}
+	}
+	return ""
+}
+
+func callArity(p *Pass) {
+	ast.Inspect(p.Program, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		id, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return true
+		}
+		if sym := p.Info.SymbolOf(id); sym != nil && sym.Fn != nil {
+			if want, got := len(sym.Fn.Parameters), len(call.Arguments); want != got {
+				p.Report(id.Pos(), ast.End(id.Token), "%s takes %s but is called with %d", id.Value, plural(want, "argument"), got)
+			}
+		}
+		return true
+	})
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
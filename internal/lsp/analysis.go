@@ -0,0 +1,155 @@
+package lsp
+
+import (
+	"sort"
+
+	"mingo/internal/ast"
+	"mingo/internal/compiler"
+	"mingo/internal/lexer"
+	"mingo/internal/module"
+	"mingo/internal/parser"
+	"mingo/internal/resolve"
+	"mingo/internal/token"
+)
+
+type diagnostic struct {
+	pos      token.Position
+	end      int // byte offset; 0 marks a single character
+	severity int
+	msg      string
+}
+
+// analysis is what the server knows about one version of a document.
+type analysis struct {
+	program     *ast.Program
+	diagnostics []diagnostic
+	info        *resolve.Info
+}
+
+// analyze parses text, the document in the file path, and resolves every
+// identifier. Diagnostics come from the parser or, for a program that
+// parses, from loading its imports, the type checker and the compiler.
+// Errors in imported modules are reported at the document's imports.
+func analyze(path, text string) *analysis {
+	p := parser.New(lexer.New(text))
+	a := &analysis{program: p.ParseProgram()}
+	for _, e := range p.RichErrors() {
+		a.diagnostics = append(a.diagnostics, diagnostic{pos: e.Pos, severity: SeverityError, msg: e.Msg})
+	}
+	a.info = resolve.Program(a.program)
+	if len(a.diagnostics) > 0 {
+		return a
+	}
+
+	mods, err := (&module.Loader{}).LoadProgram(path, a.program)
+	if err != nil {
+		errs, ok := err.(module.ErrorList)
+		if !ok {
+			errs = module.ErrorList{{Msg: err.Error()}}
+		}
+		for _, e := range errs {
+			d := diagnostic{pos: e.Pos, severity: SeverityError, msg: e.Msg}
+			if e.Import != nil {
+				d.pos, d.msg = e.Import.Token.Pos, e.Error()
+			}
+			a.diagnostics = append(a.diagnostics, d)
+		}
+		return a
+	}
+	c := compiler.New()
+	module.Compile(c, mods)
+	for _, d := range append(module.Check(mods), c.Diagnostics()...) {
+		d, ok := module.InMain(mods, d)
+		if !ok {
+			continue
+		}
+		severity := SeverityError
+		if d.Severity == compiler.SeverityWarning {
+			severity = SeverityWarning
+		}
+		a.diagnostics = append(a.diagnostics, diagnostic{pos: d.Pos, end: d.End.Offset, severity: severity, msg: d.Msg})
+	}
+	return a
+}
+
+// referenceAt returns the identifier touching offset, if any. An offset
+// just past the end of an identifier counts, as it does for a cursor.
+func (a *analysis) referenceAt(offset int) (resolve.Ref, bool) {
+	refs := a.info.Refs
+	i := sort.Search(len(refs), func(i int) bool {
+		id := refs[i].Ident
+		return id.Token.Pos.Offset+len(id.Value) >= offset
+	})
+	if i < len(refs) && refs[i].Ident.Token.Pos.Offset <= offset {
+		return refs[i], true
+	}
+	return resolve.Ref{}, false
+}
+
+// visible returns the symbols a name at offset could refer to: the
+// innermost function's parameters and locals and the globals, each defined
+// before offset. Later definitions shadow earlier ones.
+func (a *analysis) visible(offset int) []*resolve.Symbol {
+	scopes := a.info.Scopes
+	inner := scopes[0]
+	for _, s := range scopes[1:] {
+		if s.Start <= offset && offset <= s.End && s.Start >= inner.Start {
+			inner = s
+		}
+	}
+	seen := map[string]bool{}
+	var syms []*resolve.Symbol
+	add := func(s *resolve.Scope) {
+		for i := len(s.Symbols) - 1; i >= 0; i-- {
+			sym := s.Symbols[i]
+			if sym.Def.Token.Pos.Offset < offset && !seen[sym.Name] {
+				seen[sym.Name] = true
+				syms = append(syms, sym)
+			}
+		}
+	}
+	add(inner)
+	if inner != scopes[0] {
+		add(scopes[0])
+	}
+	sort.Slice(syms, func(i, j int) bool { return syms[i].Name < syms[j].Name })
+	return syms
+}
+
+// signature renders a symbol the way it was declared.
+func signature(sym *resolve.Symbol) string {
+	if sym.Fn == nil {
+		// the definition carries the annotation, if any
+		if sym.Kind == resolve.Parameter {
+			return sym.Def.String()
+		}
+		return "let " + sym.Def.String()
+	}
+	s := "fn " + sym.Name + "("
+	for i, p := range sym.Fn.Parameters {
+		if i > 0 {
+			s += ", "
+		}
+		s += p.String()
+	}
+	s += ")"
+	if sym.Fn.ReturnType != nil {
+		s += " -> " + sym.Fn.ReturnType.String()
+	}
+	return s
+}
+
+func description(sym *resolve.Symbol) string {
+	switch {
+	case sym.Kind == resolve.Parameter:
+		return "parameter of " + sym.Owner
+	case sym.Kind == resolve.Function && sym.Owner == "":
+		return "function"
+	case sym.Kind == resolve.Function:
+		return "local function of " + sym.Owner
+	case sym.Owner == "":
+		return "global variable"
+	default:
+		return "local variable of " + sym.Owner
+	}
+}
@@ -0,0 +1,82 @@
+package lsp
+
+import (
+	"net/url"
+	"sort"
+	"unicode/utf8"
+
+	"mingo/internal/token"
+)
+
+// document is an open text document and its latest analysis.
+type document struct {
+	uri      string
+	version  int
+	text     string
+	lines    []int // byte offset where each line starts
+	analysis *analysis
+}
+
+func newDocument(uri, text string, version int) *document {
+	d := &document{uri: uri, version: version, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	d.analysis = analyze(filePath(uri), text)
+	return d
+}
+
+// filePath returns the file a file: URI names, or "" for other URIs.
+func filePath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return u.Path
+}
+
+// position converts a byte offset into an LSP position.
+func (d *document) position(offset int) Position {
+	offset = min(max(offset, 0), len(d.text))
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+	char := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		char += utf16Len(r)
+	}
+	return Position{Line: line, Character: char}
+}
+
+// offset converts an LSP position into a byte offset, clamping positions
+// past the end of a line or of the document.
+func (d *document) offset(p Position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset, char := d.lines[p.Line], 0
+	for offset < len(d.text) && char < p.Character {
+		r, w := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		char += utf16Len(r)
+		offset += w
+	}
+	return offset
+}
+
+// span returns the range covering length bytes from pos.
+func (d *document) span(pos token.Position, length int) Range {
+	return Range{Start: d.position(pos.Offset), End: d.position(pos.Offset + length)}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
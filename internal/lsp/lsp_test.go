@@ -0,0 +1,359 @@
+package lsp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"mingo/internal/lsp"
+	"mingo/internal/wire"
+)
+
+const uri = "file:///test.mg"
+
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int
+		Message string
+	} `json:"error"`
+}
+
+// client is a scripted LSP client talking to an in-process server.
+type client struct {
+	t             *testing.T
+	w             io.WriteCloser
+	in            chan message
+	notifications []message
+	id            int
+	version       int
+}
+
+func start(t *testing.T) *client {
+	t.Helper()
+	toServer, clientW := io.Pipe()
+	clientR, fromServer := io.Pipe()
+	c := &client{t: t, w: clientW, in: make(chan message, 64)}
+	done := make(chan error, 1)
+	go func() {
+		err := lsp.New(toServer, fromServer).Serve()
+		fromServer.Close()
+		done <- err
+	}()
+	go func() {
+		r := bufio.NewReader(clientR)
+		for {
+			body, err := wire.ReadMessage(r)
+			if err != nil {
+				close(c.in)
+				return
+			}
+			var m message
+			if err := json.Unmarshal(body, &m); err != nil {
+				t.Errorf("bad message %s: %v", body, err)
+			}
+			c.in <- m
+		}
+	}()
+	t.Cleanup(func() {
+		c.send(map[string]any{"jsonrpc": "2.0", "method": "exit"})
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Serve: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("server did not exit")
+		}
+		clientW.Close()
+	})
+
+	var init struct{ Capabilities map[string]any }
+	c.call("initialize", map[string]any{"capabilities": map[string]any{}}, &init)
+	for _, capability := range []string{"hoverProvider", "definitionProvider", "referencesProvider", "documentSymbolProvider", "completionProvider", "documentFormattingProvider"} {
+		if init.Capabilities[capability] == nil {
+			t.Fatalf("capability %s missing. got=%v", capability, init.Capabilities)
+		}
+	}
+	c.send(map[string]any{"jsonrpc": "2.0", "method": "initialized", "params": map[string]any{}})
+	return c
+}
+
+func (c *client) send(msg any) {
+	c.t.Helper()
+	if err := wire.WriteMessage(c.w, msg); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+func (c *client) next() message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.in:
+		if !ok {
+			c.t.Fatalf("server closed the connection")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+	}
+	return message{}
+}
+
+// call sends a request and decodes its result into result.
+func (c *client) call(method string, params any, result any) {
+	c.t.Helper()
+	c.id++
+	c.send(map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+	for {
+		m := c.next()
+		if m.ID == nil {
+			c.notifications = append(c.notifications, m)
+			continue
+		}
+		if *m.ID != c.id {
+			c.t.Fatalf("response to %d, want %d", *m.ID, c.id)
+		}
+		if m.Error != nil {
+			c.t.Fatalf("%s failed: %s", method, m.Error.Message)
+		}
+		if result != nil {
+			if err := json.Unmarshal(m.Result, result); err != nil {
+				c.t.Fatalf("%s result %s: %v", method, m.Result, err)
+			}
+		}
+		return
+	}
+}
+
+// open sends the document text (didOpen the first time, didChange after)
+// and returns the diagnostics published for it.
+func (c *client) open(text string) []lsp.Diagnostic {
+	c.t.Helper()
+	c.version++
+	if c.version == 1 {
+		c.send(map[string]any{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": "mingo", "version": c.version, "text": text},
+		}})
+	} else {
+		c.send(map[string]any{"jsonrpc": "2.0", "method": "textDocument/didChange", "params": map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": c.version},
+			"contentChanges": []map[string]any{{"text": text}},
+		}})
+	}
+	for {
+		m := c.next()
+		if m.Method != "textDocument/publishDiagnostics" {
+			c.t.Fatalf("unexpected message %+v", m)
+		}
+		var params struct {
+			URI         string
+			Version     int
+			Diagnostics []lsp.Diagnostic
+		}
+		json.Unmarshal(m.Params, &params)
+		if params.Version == c.version {
+			return params.Diagnostics
+		}
+	}
+}
+
+func at(line, char int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": char},
+	}
+}
+
+func rng(r lsp.Range) [4]int {
+	return [4]int{r.Start.Line, r.Start.Character, r.End.Line, r.End.Character}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := start(t)
+	tests := []struct {
+		input string
+		want  []string // "line:char-char severity message"
+	}{
+		{"let a = 1;\nprint(a);\n", nil},
+		{"let a = 1;\nprint(a + b);\n", []string{"1:10-11 1 undefined variable b"}},
+		{"let = 1;", []string{
+			"0:4-5 1 expected next token to be IDENT, got ASSIGN instead",
+			"0:4-5 1 no prefix parse function for ASSIGN found",
+		}},
+		{"fn outer(x) {\n  let f = fn() { x; };\n}\n", []string{
+			"1:17-18 1 cannot use x: local of an enclosing function (closures are not supported)",
+			"1:6-7 2 unused variable f",
+		}},
+		{"let a = 1;\nlet b = a + c + d;\nc = 2;\n", []string{
+			"1:12-13 1 undefined variable c",
+			"1:16-17 1 undefined variable d",
+			"2:0-1 1 assignment to undeclared variable c (declare it with let)",
+		}},
+		{"fn f(n) { if (n < 1) { return 0; } f(n - 1); }\nprint(f(3));\n", nil},
+		{"let n: int = 1;\nprint(n + true);\n", []string{"1:8-9 1 operator + requires int operands, got int and bool"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, d := range c.open(tt.input) {
+			r := rng(d.Range)
+			if r[0] != r[2] {
+				t.Fatalf("unexpected diagnostic %+v", d)
+			}
+			got = append(got, fmt.Sprintf("%d:%d-%d %d %s", r[0], r[1], r[3], d.Severity, d.Message))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Fatalf("wrong diagnostics for %q.\nwant=%q\ngot=%q", tt.input, tt.want, got)
+		}
+	}
+}
+
+const program = `fn add(a, b) {
+  let sum = a + b;
+  sum;
+}
+let total = add(1, 2);
+if (total > 2) {
+  let big = total;
+}
+print(add(total, 𝒳));
+`
+
+func TestNavigation(t *testing.T) {
+	c := start(t)
+	diags := c.open(program)
+	if len(diags) != 1 || diags[0].Message != "undefined variable 𝒳" || rng(diags[0].Range) != [4]int{8, 17, 8, 19} {
+		t.Fatalf("wrong diagnostics. got=%+v", diags)
+	}
+
+	var hover struct {
+		Contents lsp.MarkupContent
+		Range    lsp.Range
+	}
+	c.call("textDocument/hover", at(4, 13), &hover)
+	if !strings.Contains(hover.Contents.Value, "fn add(a, b)") || rng(hover.Range) != [4]int{4, 12, 4, 15} {
+		t.Fatalf("wrong hover. got=%+v", hover)
+	}
+	c.call("textDocument/hover", at(1, 12), &hover)
+	if !strings.Contains(hover.Contents.Value, "parameter of add") {
+		t.Fatalf("wrong hover. got=%+v", hover)
+	}
+
+	var def lsp.Location
+	c.call("textDocument/definition", at(8, 12), &def) // total in print
+	if def.URI != uri || rng(def.Range) != [4]int{4, 4, 4, 9} {
+		t.Fatalf("wrong definition. got=%+v", def)
+	}
+
+	var refs []lsp.Location
+	c.call("textDocument/references", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 4, "character": 6},
+		"context":      map[string]any{"includeDeclaration": true},
+	}, &refs)
+	var lines []int
+	for _, r := range refs {
+		lines = append(lines, r.Range.Start.Line)
+	}
+	if len(lines) != 4 || lines[0] != 4 || lines[1] != 5 || lines[2] != 6 || lines[3] != 8 {
+		t.Fatalf("wrong references. got=%+v", refs)
+	}
+
+	var syms []lsp.DocumentSymbol
+	c.call("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}}, &syms)
+	var names []string
+	for _, s := range syms {
+		names = append(names, s.Name)
+		for _, child := range s.Children {
+			names = append(names, s.Name+"."+child.Name)
+		}
+	}
+	if strings.Join(names, " ") != "add add.sum total big" {
+		t.Fatalf("wrong symbols. got=%q", names)
+	}
+	if syms[0].Kind != lsp.SymbolFunction || rng(syms[0].Range) != [4]int{0, 0, 3, 1} {
+		t.Fatalf("wrong function symbol. got=%+v", syms[0])
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := start(t)
+	c.open(`let apple = 1;
+fn fruit(avocado) {
+  let banana = avocado;
+  let f = fn(cherry) {  };
+  a
+}
+let apricot = 2;
+`)
+	complete := func(line, char int) []string {
+		var items []lsp.CompletionItem
+		c.call("textDocument/completion", at(line, char), &items)
+		var labels []string
+		for _, it := range items {
+			if it.Kind != lsp.CompletionKeyword {
+				labels = append(labels, it.Label)
+			}
+		}
+		sort.Strings(labels)
+		return labels
+	}
+	tests := []struct {
+		line, char int
+		want       string
+	}{
+		{4, 3, "apple avocado"},                // prefix "a" in fruit
+		{4, 2, "apple avocado banana f fruit"}, // everything visible in fruit
+		{3, 22, "apple cherry fruit"},          // the literal sees its own params and globals only
+		{7, 0, "apple apricot fruit"},          // top level, after everything
+	}
+	for _, tt := range tests {
+		if got := strings.Join(complete(tt.line, tt.char), " "); got != tt.want {
+			t.Fatalf("completion at %d:%d wrong. want=%q, got=%q", tt.line, tt.char, tt.want, got)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	c := start(t)
+	c.call("shutdown", nil, nil)
+	c.id++
+	c.send(map[string]any{"jsonrpc": "2.0", "id": c.id, "method": "textDocument/hover", "params": at(0, 0)})
+	if m := c.next(); m.Error == nil {
+		t.Fatalf("request after shutdown succeeded: %+v", m)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := start(t)
+	format := func() []lsp.TextEdit {
+		var edits []lsp.TextEdit
+		c.call("textDocument/formatting", map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+			"options":      map[string]any{"tabSize": 4, "insertSpaces": true},
+		}, &edits)
+		return edits
+	}
+
+	c.open("let 𝒳=1;\nprint( 𝒳 ) // show")
+	edits := format()
+	if len(edits) != 1 || rng(edits[0].Range) != [4]int{0, 0, 1, 19} || edits[0].NewText != "let 𝒳 = 1;\nprint(𝒳); // show\n" {
+		t.Fatalf("wrong edits. got=%+v", edits)
+	}
+	c.open(edits[0].NewText)
+	if edits := format(); len(edits) != 0 {
+		t.Fatalf("formatted document changed. got=%+v", edits)
+	}
+	c.open("let = 1;")
+	if edits := format(); len(edits) != 0 {
+		t.Fatalf("document with parse errors changed. got=%+v", edits)
+	}
+}
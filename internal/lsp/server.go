@@ -0,0 +1,354 @@
+// Package lsp is a Language Server Protocol server for Mingo. It keeps open
+// documents in memory, re-analyzes them on every change and answers
+// diagnostics, hover, definition, references, document symbol and
+// completion requests from that analysis, and formats documents.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"mingo/internal/ast"
+	"mingo/internal/format"
+	"mingo/internal/resolve"
+	"mingo/internal/wire"
+)
+
+var keywords = []string{"fn", "let", "if", "else", "while", "return", "print", "true", "false", "try", "catch", "finally", "throw"}
+
+// Server handles one client connection. Requests are answered in order on
+// the goroutine that calls Serve.
+type Server struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs map[string]*document
+
+	shutdown bool
+}
+
+func New(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, docs: make(map[string]*document)}
+}
+
+// Serve handles messages until the client sends exit or closes the input.
+func (s *Server) Serve() error {
+	for {
+		body, err := wire.ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return fmt.Errorf("lsp: %w", err)
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if msg.ID == nil {
+			s.notification(&msg)
+			continue
+		}
+		result, rerr := s.request(&msg)
+		s.reply(msg.ID, result, rerr)
+	}
+}
+
+func (s *Server) request(msg *message) (any, *responseError) {
+	if s.shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"}
+	}
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":           map[string]any{"openClose": true, "change": 1}, // full text
+				"hoverProvider":              true,
+				"definitionProvider":         true,
+				"referencesProvider":         true,
+				"documentSymbolProvider":     true,
+				"completionProvider":         map[string]any{},
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "mingo"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/hover":
+		return withPosition(s, msg.Params, s.hover)
+	case "textDocument/definition":
+		return withPosition(s, msg.Params, s.definition)
+	case "textDocument/completion":
+		return withPosition(s, msg.Params, s.completion)
+	case "textDocument/references":
+		var params referenceParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return nil, unknownDocument(params.TextDocument.URI)
+		}
+		return s.references(doc, doc.offset(params.Position), params.Context.IncludeDeclaration), nil
+	case "textDocument/documentSymbol":
+		var params documentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return nil, unknownDocument(params.TextDocument.URI)
+		}
+		return documentSymbols(doc, doc.analysis.program.Statements), nil
+	case "textDocument/formatting":
+		// the formatting options are ignored: the format is canonical
+		var params documentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return nil, unknownDocument(params.TextDocument.URI)
+		}
+		return formatting(doc), nil
+	default:
+		return nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method}
+	}
+}
+
+// withPosition decodes text document position params and calls handle with
+// the document and the byte offset they point at.
+func withPosition(s *Server, raw json.RawMessage, handle func(doc *document, offset int) any) (any, *responseError) {
+	var params positionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams(err)
+	}
+	doc := s.docs[params.TextDocument.URI]
+	if doc == nil {
+		return nil, unknownDocument(params.TextDocument.URI)
+	}
+	return handle(doc, doc.offset(params.Position)), nil
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+func unknownDocument(uri string) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: "unknown document " + uri}
+}
+
+func (s *Server) notification(msg *message) {
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text, params.TextDocument.Version)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			// full sync: the last change holds the whole text
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			s.update(params.TextDocument.URI, text, params.TextDocument.Version)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(s.docs, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		}
+	}
+}
+
+// update replaces a document's text and publishes its diagnostics.
+func (s *Server) update(uri, text string, version int) {
+	doc := newDocument(uri, text, version)
+	s.docs[uri] = doc
+	diags := make([]Diagnostic, 0, len(doc.analysis.diagnostics))
+	for _, d := range doc.analysis.diagnostics {
+		r := Range{Start: doc.position(d.pos.Offset), End: doc.position(max(d.end, d.pos.Offset+1))}
+		diags = append(diags, Diagnostic{
+			Range:    r,
+			Severity: d.severity,
+			Source:   "mingo",
+			Message:  d.msg,
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Version: version, Diagnostics: diags})
+}
+
+func (s *Server) hover(doc *document, offset int) any {
+	ref, ok := doc.analysis.referenceAt(offset)
+	if !ok {
+		return nil
+	}
+	r := identRange(doc, ref.Ident)
+	return &Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: "```mingo\n" + signature(ref.Sym) + "\n```\n" + description(ref.Sym),
+		},
+		Range: &r,
+	}
+}
+
+func (s *Server) definition(doc *document, offset int) any {
+	ref, ok := doc.analysis.referenceAt(offset)
+	if !ok {
+		return nil
+	}
+	return Location{URI: doc.uri, Range: identRange(doc, ref.Sym.Def)}
+}
+
+func (s *Server) references(doc *document, offset int, withDecl bool) []Location {
+	ref, ok := doc.analysis.referenceAt(offset)
+	if !ok {
+		return []Location{}
+	}
+	locs := []Location{}
+	if withDecl {
+		locs = append(locs, Location{URI: doc.uri, Range: identRange(doc, ref.Sym.Def)})
+	}
+	for _, r := range ref.Sym.Refs {
+		locs = append(locs, Location{URI: doc.uri, Range: identRange(doc, r.Ident)})
+	}
+	return locs
+}
+
+func (s *Server) completion(doc *document, offset int) any {
+	// complete the identifier being typed from its start
+	start := offset
+	for start > 0 && isIdentByte(doc.text[start-1]) {
+		start--
+	}
+	prefix := doc.text[start:offset]
+
+	items := []CompletionItem{}
+	for _, sym := range doc.analysis.visible(start) {
+		if !strings.HasPrefix(sym.Name, prefix) {
+			continue
+		}
+		kind := CompletionVariable
+		if sym.Fn != nil {
+			kind = CompletionFunction
+		}
+		items = append(items, CompletionItem{Label: sym.Name, Kind: kind, Detail: signature(sym)})
+	}
+	for _, kw := range keywords {
+		if strings.HasPrefix(kw, prefix) {
+			items = append(items, CompletionItem{Label: kw, Kind: CompletionKeyword})
+		}
+	}
+	return items
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b >= 0x80
+}
+
+// documentSymbols lists the functions and let bindings in stmts, nesting
+// those found inside a function under it.
+func documentSymbols(doc *document, stmts []ast.Statement) []DocumentSymbol {
+	syms := []DocumentSymbol{}
+	for _, stmt := range stmts {
+		switch n := stmt.(type) {
+		case *ast.FunctionStatement:
+			if n == nil || n.Name == nil || n.Body == nil {
+				continue
+			}
+			syms = append(syms, DocumentSymbol{
+				Name:           n.Name.Value,
+				Detail:         signature(&resolve.Symbol{Name: n.Name.Value, Fn: n}),
+				Kind:           SymbolFunction,
+				Range:          Range{Start: doc.position(n.Token.Pos.Offset), End: doc.position(n.Body.Rbrace.Offset + 1)},
+				SelectionRange: identRange(doc, n.Name),
+				Children:       documentSymbols(doc, n.Body.Statements),
+			})
+		case *ast.LetStatement:
+			if n == nil || n.Name == nil {
+				continue
+			}
+			sym := DocumentSymbol{
+				Name:           n.Name.Value,
+				Kind:           SymbolVariable,
+				Range:          Range{Start: doc.position(n.Token.Pos.Offset), End: identRange(doc, n.Name).End},
+				SelectionRange: identRange(doc, n.Name),
+			}
+			if lit, ok := n.Value.(*ast.FunctionLiteral); ok && lit != nil && lit.Body != nil {
+				sym.Kind = SymbolFunction
+				sym.Range.End = doc.position(lit.Body.Rbrace.Offset + 1)
+				sym.Children = documentSymbols(doc, lit.Body.Statements)
+			}
+			syms = append(syms, sym)
+		default:
+			// bindings inside if, while and try blocks belong to the
+			// enclosing scope
+			syms = append(syms, documentSymbols(doc, nestedStatements(stmt))...)
+		}
+	}
+	return syms
+}
+
+// nestedStatements returns the statements in the blocks of a compound
+// statement.
+func nestedStatements(stmt ast.Statement) []ast.Statement {
+	var blocks []*ast.BlockStatement
+	switch n := stmt.(type) {
+	case *ast.ExpressionStatement:
+		if n != nil {
+			if ifx, ok := n.Expression.(*ast.IfExpression); ok && ifx != nil {
+				blocks = append(blocks, ifx.Consequence, ifx.Alternative)
+			}
+		}
+	case *ast.WhileStatement:
+		if n != nil {
+			blocks = append(blocks, n.Body)
+		}
+	case *ast.TryStatement:
+		if n != nil {
+			blocks = append(blocks, n.Block, n.CatchBlock, n.FinallyBlock)
+		}
+	}
+	var stmts []ast.Statement
+	for _, b := range blocks {
+		if b != nil {
+			stmts = append(stmts, b.Statements...)
+		}
+	}
+	return stmts
+}
+
+// formatting replaces the whole document with its formatted text. A
+// document that does not parse is left alone; its diagnostics say why.
+func formatting(doc *document) []TextEdit {
+	text, err := format.Source(doc.text)
+	if err != nil || text == doc.text {
+		return []TextEdit{}
+	}
+	return []TextEdit{{Range: Range{End: doc.position(len(doc.text))}, NewText: text}}
+}
+
+func identRange(doc *document, id *ast.Identifier) Range {
+	return doc.span(id.Token.Pos, len(id.Value))
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rerr *responseError) {
+	msg := map[string]any{"jsonrpc": "2.0", "id": id}
+	if rerr != nil {
+		msg["error"] = rerr
+	} else {
+		msg["result"] = result
+	}
+	_ = wire.WriteMessage(s.out, msg)
+}
+
+func (s *Server) notify(method string, params any) {
+	_ = wire.WriteMessage(s.out, map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
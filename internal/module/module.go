@@ -0,0 +1,236 @@
+// Package module loads a program together with the modules it imports:
+//
+//	import "lib/strings.mg" as strs;
+//	print(strs.repeat(3));
+//
+// An import path is relative to the directory of the importing file; the
+// main program's imports are relative to its own file, or to the working
+// directory for a program without one. Paths naming standard library
+// modules, such as "math", are not files. A module is loaded once however
+// often it is imported, and import cycles are errors.
+package module
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mingo/internal/ast"
+	"mingo/internal/compiler"
+	"mingo/internal/lexer"
+	"mingo/internal/parser"
+	"mingo/internal/stdlib"
+	"mingo/internal/token"
+	"mingo/internal/types"
+)
+
+// Module is one parsed source file. The positions in an imported module's
+// tree name its file; those of the main program do not.
+type Module struct {
+	Path    string // "" for a main program without a file
+	Program *ast.Program
+
+	// Import is the main program's import statement through which the
+	// module was first reached; nil for the main program.
+	Import *ast.ImportStatement
+}
+
+// Error is a problem loading a module: a syntax error, or an import that
+// cannot be read or closes a cycle.
+type Error struct {
+	Pos token.Position
+	Msg string
+
+	// Import is the main program's import statement through which the
+	// failing module was reached; nil for errors in the main program.
+	Import *ast.ImportStatement
+}
+
+func (e *Error) Error() string { return e.Pos.String() + ": " + e.Msg }
+
+// ErrorList is the error Load returns.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Loader loads programs and their imports.
+type Loader struct {
+	// ReadFile reads a module's source; nil means os.ReadFile.
+	ReadFile func(path string) ([]byte, error)
+
+	loaded  map[string]bool
+	loading []string             // the import chain being loaded, for cycle errors
+	via     *ast.ImportStatement // the main program's import being loaded
+	modules []*Module
+}
+
+// Resolve returns the file an import of path names from the file importer.
+func Resolve(importer, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(filepath.Dir(importer), path)
+}
+
+// Load parses src, the main program in the file path ("" if it has none),
+// and loads it with LoadProgram.
+func (l *Loader) Load(path, src string) ([]*Module, error) {
+	program, errs := parse(lexer.New(src))
+	if errs != nil {
+		return nil, errs
+	}
+	return l.LoadProgram(path, program)
+}
+
+// LoadProgram loads every module the main program in the file path
+// imports directly or indirectly. It returns them in the order they must
+// run, each after the modules it imports, so the main program comes last.
+// The import statements of the trees record the files they resolved to.
+func (l *Loader) LoadProgram(path string, program *ast.Program) ([]*Module, error) {
+	l.loaded = map[string]bool{}
+	l.loading = nil
+	l.via = nil
+	l.modules = nil
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+	if errs := l.load(path, program, true); errs != nil {
+		return nil, errs
+	}
+	return l.modules, nil
+}
+
+func parse(lx *lexer.Lexer) (*ast.Program, ErrorList) {
+	p := parser.New(lx)
+	program := p.ParseProgram()
+	perrs := p.RichErrors()
+	if len(perrs) == 0 {
+		return program, nil
+	}
+	errs := make(ErrorList, len(perrs))
+	for i, e := range perrs {
+		errs[i] = &Error{Pos: e.Pos, Msg: e.Msg}
+	}
+	return nil, errs
+}
+
+func (l *Loader) load(path string, program *ast.Program, main bool) ErrorList {
+	l.loading = append(l.loading, path)
+	for _, s := range program.Statements {
+		imp, ok := s.(*ast.ImportStatement)
+		if !ok || stdlib.Lookup(imp.Path) != nil {
+			continue
+		}
+		imp.Resolved = Resolve(path, imp.Path)
+		if main {
+			l.via = imp
+		}
+		if errs := l.importModule(imp); errs != nil {
+			return errs
+		}
+	}
+	l.loading = l.loading[:len(l.loading)-1]
+
+	m := &Module{Path: path, Program: program}
+	if !main {
+		m.Import = l.via
+	}
+	l.loaded[path] = true
+	l.modules = append(l.modules, m)
+	return nil
+}
+
+func (l *Loader) importModule(imp *ast.ImportStatement) ErrorList {
+	target := imp.Resolved
+	for i, p := range l.loading {
+		if p == target {
+			cycle := append(append([]string{}, l.loading[i:]...), target)
+			return l.errors(ErrorList{{Pos: imp.Token.Pos, Msg: "import cycle: " + strings.Join(cycle, " -> ")}})
+		}
+	}
+	if l.loaded[target] {
+		return nil
+	}
+	read := l.ReadFile
+	if read == nil {
+		read = os.ReadFile
+	}
+	b, err := read(target)
+	if err != nil {
+		return l.errors(ErrorList{{Pos: imp.Token.Pos, Msg: fmt.Sprintf("cannot import %s: %v", token.Quote(imp.Path), err)}})
+	}
+	program, errs := parse(lexer.NewFile(target, string(b)))
+	if errs != nil {
+		return l.errors(errs)
+	}
+	return l.load(target, program, false)
+}
+
+// errors records on errors in imported modules the main program's import
+// that reached them.
+func (l *Loader) errors(errs ErrorList) ErrorList {
+	for _, e := range errs {
+		if e.Pos.File != "" {
+			e.Import = l.via
+		}
+	}
+	return errs
+}
+
+// InMain converts a diagnostic for a tool that shows only the main
+// program. A diagnostic in the main program is unchanged. An error in an
+// imported module moves to the import statement through which the main
+// program reached the module, keeping its own position in the message;
+// ok is false for a warning there, which the main program cannot act on.
+func InMain(mods []*Module, d compiler.Diagnostic) (_ compiler.Diagnostic, ok bool) {
+	if d.Pos.File == "" {
+		return d, true
+	}
+	if d.Severity != compiler.SeverityError {
+		return d, false
+	}
+	for _, m := range mods {
+		if m.Path == d.Pos.File && m.Import != nil {
+			return compiler.Diagnostic{Pos: m.Import.Token.Pos, Severity: d.Severity, Msg: d.Error()}, true
+		}
+	}
+	return d, false
+}
+
+// Check type-checks every module.
+func Check(mods []*Module) []compiler.Diagnostic {
+	var diags []compiler.Diagnostic
+	for _, m := range mods {
+		diags = append(diags, types.Check(m.Program)...)
+	}
+	return diags
+}
+
+// Compile compiles modules in the order Load returns them: the imported
+// ones with CompileModule and the main program, the last, with Compile.
+// The returned error lists the errors of all of them.
+func Compile(c *compiler.Compiler, mods []*Module) error {
+	var errs compiler.ErrorList
+	for i, m := range mods {
+		var err error
+		if i == len(mods)-1 {
+			err = c.Compile(m.Program)
+		} else {
+			err = c.CompileModule(m.Path, m.Program)
+		}
+		if list, ok := err.(compiler.ErrorList); ok {
+			errs = append(errs, list...)
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
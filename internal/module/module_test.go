@@ -0,0 +1,160 @@
+package module_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"mingo/internal/compiler"
+	"mingo/internal/module"
+	"mingo/internal/vm"
+)
+
+// files is a file system for the loader.
+type files map[string]string
+
+func (f files) read(path string) ([]byte, error) {
+	src, ok := f[path]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return []byte(src), nil
+}
+
+// run loads, compiles and runs the program main.mg and returns what it
+// printed and the first error of any stage.
+func run(t *testing.T, fsys files) (string, error) {
+	t.Helper()
+	mods, err := (&module.Loader{ReadFile: fsys.read}).Load("main.mg", fsys["main.mg"])
+	if err != nil {
+		return "", err
+	}
+	if errs := module.Check(mods); len(errs) > 0 {
+		return "", compiler.ErrorList(errs)
+	}
+	comp := compiler.New()
+	if err := module.Compile(comp, mods); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	machine := vm.NewFromBytecode(comp.Bytecode(), nil)
+	machine.SetOutput(&out)
+	err = machine.Run()
+	return out.String(), err
+}
+
+var lib = files{
+	"lib/strs.mg": `import "num.mg" as num;
+let n = 10;
+export fn twice(x) { num.add(x, x) }
+export let ten = num.add(n, 0);
+`,
+	"lib/num.mg": `print(0);
+export fn add(a, b) { a + b }
+`,
+}
+
+func with(fsys files, main string) files {
+	out := files{"main.mg": main}
+	for path, src := range fsys {
+		out[path] = src
+	}
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	fsys := with(lib, `import "lib/num.mg" as n;
+import "lib/strs.mg" as s;
+let n = 1;
+print(s.twice(n) + n.add(s.ten, 0));
+`)
+	mods, err := (&module.Loader{ReadFile: fsys.read}).Load("main.mg", fsys["main.mg"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, m := range mods {
+		paths = append(paths, m.Path)
+	}
+	if got := strings.Join(paths, " "); got != "lib/num.mg lib/strs.mg main.mg" {
+		t.Fatalf("wrong modules. got=%q", got)
+	}
+
+	// each module runs once, with its own globals
+	out, err := run(t, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "0\n12\n" {
+		t.Fatalf("wrong output. want=%q got=%q", "0\n12\n", out)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		fsys      files
+		expected  string
+		viaImport bool // whether the error names the main program's import
+	}{
+		{files{"main.mg": `print(1); import "gone.mg" as g;`}, `1:11: cannot import "gone.mg": file does not exist`, false},
+		{files{"main.mg": `import "a.mg" as a;`, "a.mg": `import "lib/b.mg" as b;`, "lib/b.mg": `import "../a.mg" as a;`},
+			"lib/b.mg:1:1: import cycle: a.mg -> lib/b.mg -> a.mg", true},
+		{files{"main.mg": `import "main.mg" as m;`}, "1:1: import cycle: main.mg -> main.mg", false},
+		{files{"main.mg": `import "a.mg" as a;`, "a.mg": "export let x = ;"}, "a.mg:1:16: no prefix parse function for SEMICOLON found", true},
+	}
+	for _, tt := range tests {
+		_, err := (&module.Loader{ReadFile: tt.fsys.read}).Load("main.mg", tt.fsys["main.mg"])
+		var errs module.ErrorList
+		if !errors.As(err, &errs) {
+			t.Fatalf("%q: want a module.ErrorList, got %v", tt.fsys["main.mg"], err)
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. want=%q got=%q", tt.fsys["main.mg"], tt.expected, err)
+		}
+		if (errs[0].Import != nil) != tt.viaImport {
+			t.Errorf("%q: wrong import for %q: %v", tt.fsys["main.mg"], err, errs[0].Import)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		main     string
+		expected string
+	}{
+		{`import "lib/strs.mg" as s; print(s.n);`, "1:36: module lib/strs.mg does not export n"},
+		{`let s = 1; print(s.ten);`, "1:18: s is not an imported module"},
+		{`if (true) { import "lib/num.mg" as n; }`, "1:36: import is only allowed at the top level"},
+		{`import "lib/num.mg" as n; import "lib/strs.mg" as n;`, "1:51: n is already the name of an import"},
+		{`fn f() { export let x = 1; }`, "1:10: export is only allowed at the top level"},
+	}
+	for _, tt := range tests {
+		_, err := run(t, with(lib, tt.main))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. want=%q got=%v", tt.main, tt.expected, err)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	fsys := with(lib, `import "lib/num.mg" as n; let t = true; n.add(t, 1);`)
+	_, err := run(t, fsys)
+	if want := "lib/num.mg:2:25: TypeError: unsupported types for binary op: BOOLEAN INTEGER"; err == nil || err.Error() != want {
+		t.Fatalf("wrong runtime error. want=%q got=%v", want, err)
+	}
+
+	fsys["lib/num.mg"] = "export fn add(a: int, b: int) -> int { a + b } export let oops: bool = add(1, 2);"
+	mods, err := (&module.Loader{ReadFile: fsys.read}).Load("main.mg", fsys["main.mg"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := module.Check(mods)
+	if len(errs) != 1 {
+		t.Fatalf("wrong type errors. got=%v", errs)
+	}
+	d, ok := module.InMain(mods, errs[0])
+	if want := "1:1: lib/num.mg:1:72: cannot use int as bool in let oops"; !ok || d.Error() != want {
+		t.Fatalf("wrong diagnostic in main. want=%q got=%q", want, d.Error())
+	}
+}
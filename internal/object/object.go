@@ -2,7 +2,11 @@ package object
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+
+	"mingo/internal/code"
+	"mingo/internal/token"
 )
 
 type Type string
@@ -12,6 +16,11 @@ const (
 	BOOLEAN_OBJ           Type = "BOOLEAN"
 	NULL_OBJ              Type = "NULL"
 	COMPILED_FUNCTION_OBJ Type = "COMPILED_FUNCTION"
+	BUILTIN_OBJ           Type = "BUILTIN"
+	STRING_OBJ            Type = "STRING"
+	ARRAY_OBJ             Type = "ARRAY"
+	MAP_OBJ               Type = "MAP"
+	ERROR_OBJ             Type = "ERROR"
 )
 
 type Object interface {
@@ -34,15 +43,136 @@ func (b *Boolean) Inspect() string {
 	return "false"
 }
 
+// String is an immutable UTF-8 string. Its Inspect form is the text
+// itself, which is what print shows.
+type String struct{ Value string }
+
+func (s *String) Type() Type      { return STRING_OBJ }
+func (s *String) Inspect() string { return s.Value }
+
+// Array is a list of values. The elements of an array literal are copied
+// into a new array; the standard library builds new arrays rather than
+// changing old ones.
+type Array struct{ Elements []Object }
+
+func (a *Array) Type() Type      { return ARRAY_OBJ }
+func (a *Array) Inspect() string { return inspect(a, nil) }
+
+// Map maps strings to values, remembering the order keys were first set
+// in. Unlike arrays, maps are changed in place.
+type Map struct {
+	Keys  []string
+	Pairs map[string]Object
+}
+
+func NewMap() *Map { return &Map{Pairs: map[string]Object{}} }
+
+func (m *Map) Set(key string, value Object) {
+	if _, ok := m.Pairs[key]; !ok {
+		m.Keys = append(m.Keys, key)
+	}
+	m.Pairs[key] = value
+}
+
+// Delete removes key and reports whether it was there.
+func (m *Map) Delete(key string) bool {
+	if _, ok := m.Pairs[key]; !ok {
+		return false
+	}
+	delete(m.Pairs, key)
+	m.Keys = slices.DeleteFunc(m.Keys, func(k string) bool { return k == key })
+	return true
+}
+
+func (m *Map) Type() Type      { return MAP_OBJ }
+func (m *Map) Inspect() string { return inspect(m, nil) }
+
+// inspect shows o as an element of the arrays and maps in outer, quoting
+// strings, and a container inside itself as [...] or {...}.
+func inspect(o Object, outer []Object) string {
+	switch o := o.(type) {
+	case *String:
+		if outer != nil {
+			return token.Quote(o.Value)
+		}
+	case *Array:
+		if slices.Contains(outer, Object(o)) {
+			return "[...]"
+		}
+		outer = append(outer, o)
+		elems := make([]string, len(o.Elements))
+		for i, e := range o.Elements {
+			elems[i] = inspect(e, outer)
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case *Map:
+		if slices.Contains(outer, Object(o)) {
+			return "{...}"
+		}
+		outer = append(outer, o)
+		pairs := make([]string, len(o.Keys))
+		for i, k := range o.Keys {
+			pairs[i] = token.Quote(k) + ": " + inspect(o.Pairs[k], outer)
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+	return o.Inspect()
+}
+
 type Null struct{}
 
 func (n *Null) Type() Type      { return NULL_OBJ }
 func (n *Null) Inspect() string { return "null" }
 
+// Shared immutable values. Booleans and null are always represented by these
+// singletons, so they can be compared by identity.
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+	NULL  = &Null{}
+)
+
+// NativeBool returns the shared Boolean for b.
+func NativeBool(b bool) *Boolean {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
+
+// Range of integers served from a preallocated cache by NewInteger.
+const (
+	SmallIntMin = -128
+	SmallIntMax = 1023
+)
+
+var smallInts = func() []Integer {
+	ints := make([]Integer, SmallIntMax-SmallIntMin+1)
+	for i := range ints {
+		ints[i].Value = int64(i + SmallIntMin)
+	}
+	return ints
+}()
+
+// NewInteger returns an Integer holding v. Values in [SmallIntMin, SmallIntMax]
+// come from a shared cache and must not be mutated.
+func NewInteger(v int64) *Integer {
+	if v >= SmallIntMin && v <= SmallIntMax {
+		return &smallInts[v-SmallIntMin]
+	}
+	return &Integer{Value: v}
+}
+
 type CompiledFunction struct {
 	Instructions  []byte
 	NumLocals     int
 	NumParameters int
+	Positions     code.PosTable  // instruction offset -> source position
+	Handlers      []code.Handler // exception table, innermost first
+
+	// Debug information
+	Name       string   // declared name; empty for function literals
+	LocalNames []string // local slot -> variable name
 }
 
 func (cf *CompiledFunction) Type() Type { return COMPILED_FUNCTION_OBJ }
@@ -53,3 +183,52 @@ func (cf *CompiledFunction) Inspect() string {
 	b.WriteString("]")
 	return b.String()
 }
+
+// BuiltinFunction implements a builtin. Its arguments have been checked
+// against the builtin's arity; a non-nil error is raised at the call, and
+// a nil result is null.
+type BuiltinFunction func(args ...Object) (Object, *Error)
+
+// Caller calls a function value for a builtin and returns its result, or
+// the error it raised and did not catch.
+type Caller func(fn Object, args ...Object) (Object, *Error)
+
+// Builtin is a function implemented in Go.
+type Builtin struct {
+	Name  string // qualified, as in "math.abs"
+	Arity int    // -1 for any number of arguments
+	Fn    BuiltinFunction
+	// Call replaces Fn in builtins that call functions they are passed.
+	Call func(call Caller, args ...Object) (Object, *Error)
+}
+
+func (b *Builtin) Type() Type      { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string { return "builtin " + b.Name }
+
+// Error kinds raised by the VM. Values thrown by user code get ErrorKind.
+const (
+	ErrorKind           = "Error"
+	TypeErrorKind       = "TypeError"
+	ZeroDivisionKind    = "ZeroDivisionError"
+	ArgumentErrorKind   = "ArgumentError"
+	StackOverflowKind   = "StackOverflowError"
+	OverflowKind        = "OverflowError"
+	ValueErrorKind      = "ValueError"
+	IndexErrorKind      = "IndexError"
+	KeyErrorKind        = "KeyError"
+	AssertionErrorKind  = "AssertionError"
+	IOErrorKind         = "IOError"
+	PermissionErrorKind = "PermissionError"
+)
+
+// Error is a runtime error value. It can be thrown, caught and inspected like
+// any other value; Value holds the original operand of a throw statement.
+type Error struct {
+	Kind    string
+	Message string
+	Pos     token.Position // zero if unknown
+	Value   Object
+}
+
+func (e *Error) Type() Type      { return ERROR_OBJ }
+func (e *Error) Inspect() string { return e.Kind + ": " + e.Message }
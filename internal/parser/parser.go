@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"unicode/utf8"
 
 	"mingo/internal/ast"
 	"mingo/internal/lexer"
@@ -35,21 +36,24 @@ const (
 	SUM         // + or -
 	PRODUCT     // * or /
 	PREFIX      // -X or !X
-	CALL        // fn(x)
+	CALL        // fn(x) or a[i]
+	SELECTOR    // lib.name
 )
 
 var precedences = map[token.Type]int{
-	token.EQ:     EQUALS,
-	token.NOT_EQ: EQUALS,
-	token.LT:     LESSGREATER,
-	token.GT:     LESSGREATER,
-	token.LTE:    LESSGREATER,
-	token.GTE:    LESSGREATER,
-	token.PLUS:   SUM,
-	token.MINUS:  SUM,
-	token.ASTER:  PRODUCT,
-	token.SLASH:  PRODUCT,
-	token.LPAREN: CALL,
+	token.EQ:       EQUALS,
+	token.NOT_EQ:   EQUALS,
+	token.LT:       LESSGREATER,
+	token.GT:       LESSGREATER,
+	token.LTE:      LESSGREATER,
+	token.GTE:      LESSGREATER,
+	token.PLUS:     SUM,
+	token.MINUS:    SUM,
+	token.ASTER:    PRODUCT,
+	token.SLASH:    PRODUCT,
+	token.LPAREN:   CALL,
+	token.LBRACKET: CALL,
+	token.DOT:      SELECTOR,
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -68,6 +72,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FN, p.parseFunctionLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 
 	p.infixParseFns = make(map[token.Type]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -81,6 +87,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LTE, p.parseInfixExpression)
 	p.registerInfix(token.GTE, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseSelectorExpression)
 
 	return p
 }
@@ -129,6 +137,14 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseFunctionStatement()
 	case token.PRINT:
 		return p.parsePrintStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.TRY:
+		return p.parseTryStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	case token.IDENT:
 		// Could be assignment or expression statement starting with ident
 		if p.peekToken.Type == token.ASSIGN {
@@ -148,6 +164,9 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.parseAnnotation(stmt.Name) {
+		return nil
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -167,6 +186,15 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
+	// a bare return returns null
+	switch p.peekToken.Type {
+	case token.SEMICOLON:
+		p.nextToken()
+		return stmt
+	case token.RBRACE, token.EOF:
+		return stmt
+	}
+
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
@@ -228,12 +256,67 @@ func (p *Parser) parseAssignmentStatement() ast.Statement {
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
+	stmt := &ast.AssignmentStatement{Token: p.curToken, Name: name}
 	p.nextToken()
-	value := p.parseExpression(LOWEST)
+	stmt.Value = p.parseExpression(LOWEST)
 	if p.peekToken.Type == token.SEMICOLON {
 		p.nextToken()
 	}
-	return &ast.AssignmentStatement{Token: token.Token{Type: token.ASSIGN, Literal: "="}, Name: name, Value: value}
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseTryStatement handles: try { } catch (e) { } finally { }
+// Either clause may be omitted, but not both; the catch parameter is optional.
+func (p *Parser) parseTryStatement() ast.Statement {
+	stmt := &ast.TryStatement{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Block = p.parseBlockStatement()
+
+	if p.peekToken.Type == token.CATCH {
+		p.nextToken()
+		if p.peekToken.Type == token.LPAREN {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.CatchBlock = p.parseBlockStatement()
+	}
+
+	if p.peekToken.Type == token.FINALLY {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.FinallyBlock = p.parseBlockStatement()
+	}
+
+	if stmt.CatchBlock == nil && stmt.FinallyBlock == nil {
+		msg := "try statement requires a catch or finally block"
+		p.errors = append(p.errors, msg)
+		p.rich = append(p.rich, ParseError{Msg: msg, Pos: stmt.Token.Pos})
+		return nil
+	}
+	return stmt
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
@@ -290,6 +373,24 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curToken.Type == token.TRUE}
 }
 
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	return &ast.ArrayLiteral{Token: p.curToken, Elements: p.parseExpressionList(token.RBRACKET)}
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return exp
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	exp := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
 	p.nextToken()
@@ -355,6 +456,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken.Pos
 
 	return block
 }
@@ -367,6 +469,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseReturnType(&lit.ReturnType) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -390,6 +495,9 @@ func (p *Parser) parseFunctionStatement() ast.Statement {
 		return nil
 	}
 	stmt.Parameters = p.parseFunctionParameters()
+	if !p.parseReturnType(&stmt.ReturnType) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -406,15 +514,19 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		return identifiers
 	}
 
-	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
-
-	for p.peekToken.Type == token.COMMA {
-		p.nextToken()
-		p.nextToken()
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.parseAnnotation(ident) {
+			return nil
+		}
 		identifiers = append(identifiers, ident)
+		if p.peekToken.Type != token.COMMA {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -424,6 +536,125 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	return identifiers
 }
 
+// parseAnnotation parses an optional ": type" after the name id.
+func (p *Parser) parseAnnotation(id *ast.Identifier) bool {
+	if p.peekToken.Type != token.COLON {
+		return true
+	}
+	p.nextToken()
+	p.nextToken()
+	id.Type = p.parseType()
+	return id.Type != nil
+}
+
+// parseReturnType parses an optional "-> type" after a parameter list.
+func (p *Parser) parseReturnType(dst *ast.TypeExpr) bool {
+	if p.peekToken.Type != token.ARROW {
+		return true
+	}
+	p.nextToken()
+	p.nextToken()
+	*dst = p.parseType()
+	return *dst != nil
+}
+
+// parseType parses a type starting at the current token: a name, or
+// fn(types) with an optional -> result.
+func (p *Parser) parseType() ast.TypeExpr {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.FN:
+		ft := &ast.FunctionType{Token: p.curToken, Params: []ast.TypeExpr{}}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if p.peekToken.Type == token.RPAREN {
+			p.nextToken()
+		} else {
+			for {
+				p.nextToken()
+				param := p.parseType()
+				if param == nil {
+					return nil
+				}
+				ft.Params = append(ft.Params, param)
+				if p.peekToken.Type != token.COMMA {
+					break
+				}
+				p.nextToken()
+			}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.parseReturnType(&ft.Result) {
+			return nil
+		}
+		return ft
+	}
+	p.errorAt(p.curToken.Pos, "expected a type, got %s", p.curToken.Type)
+	return nil
+}
+
+func (p *Parser) errorAt(pos token.Position, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	p.errors = append(p.errors, msg)
+	p.rich = append(p.rich, ParseError{Msg: msg, Pos: pos})
+}
+
+// parseImportStatement handles: import "path" as name;
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = p.curToken.Literal
+	// as is not a keyword, so it stays usable as a name
+	if p.peekToken.Type != token.IDENT || p.peekToken.Literal != "as" {
+		p.errorAt(p.peekToken.Pos, "expected as after the import path, got %s", p.peekToken.Type)
+		return nil
+	}
+	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseExportStatement handles: export let ...; and export fn name(...) { ... }
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	p.nextToken()
+	switch {
+	case p.curToken.Type == token.LET:
+		if let := p.parseLetStatement(); let != nil {
+			stmt.Statement = let
+		}
+	case p.curToken.Type == token.FN && p.peekToken.Type == token.IDENT:
+		stmt.Statement = p.parseFunctionStatement()
+	default:
+		p.errorAt(p.curToken.Pos, "expected let or fn after export, got %s", p.curToken.Type)
+	}
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseSelectorExpression(x ast.Expression) ast.Expression {
+	exp := &ast.SelectorExpression{Token: p.curToken, X: x}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Sel = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
@@ -456,6 +687,14 @@ func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 
 func (p *Parser) noPrefixParseFnError(t token.Type) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
+	if t == token.ILLEGAL {
+		// the literal is the stray character, or what is wrong with a
+		// string
+		msg = p.curToken.Literal
+		if utf8.RuneCountInString(msg) == 1 {
+			msg = fmt.Sprintf("unexpected character %q", msg)
+		}
+	}
 	p.errors = append(p.errors, msg)
 	p.rich = append(p.rich, ParseError{Msg: msg, Pos: p.curToken.Pos})
 }
@@ -1,6 +1,8 @@
 package parser_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"mingo/internal/ast"
@@ -43,6 +45,7 @@ func TestReturnStatements(t *testing.T) {
 return 5;
 return 10;
 return 993322;
+return;
 `
 
 	l := lexer.New(input)
@@ -50,11 +53,11 @@ return 993322;
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	if len(program.Statements) != 3 {
-		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
+	if len(program.Statements) != 4 {
+		t.Fatalf("program.Statements does not contain 4 statements. got=%d", len(program.Statements))
 	}
 
-	for _, stmt := range program.Statements {
+	for i, stmt := range program.Statements {
 		rs, ok := stmt.(*ast.ReturnStatement)
 		if !ok {
 			t.Fatalf("stmt not *ast.ReturnStatement. got=%T", stmt)
@@ -62,6 +65,9 @@ return 993322;
 		if rs.TokenLiteral() != "return" {
 			t.Fatalf("rs.TokenLiteral not 'return', got %q", rs.TokenLiteral())
 		}
+		if bare := rs.ReturnValue == nil; bare != (i == 3) {
+			t.Fatalf("statement %d: rs.ReturnValue=%v", i, rs.ReturnValue)
+		}
 	}
 }
 
@@ -93,6 +99,174 @@ add(1, 2);
 	if len(program.Statements) != 2 {
 		t.Fatalf("program.Statements expected 2, got %d", len(program.Statements))
 	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(1, "x") { 2 };`, "1:12: expected next token to be IDENT, got INT instead"},
+		{"fn g(a, ) {}", "1:9: expected next token to be IDENT, got RPAREN instead"},
+		{"fn h(a", "1:7: expected next token to be RPAREN, got EOF instead"},
+	}
+	for _, tt := range errors {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+		errs := p.RichErrors()
+		if len(errs) == 0 {
+			t.Fatalf("%q: no errors", tt.input)
+		}
+		if got := errs[0].Pos.String() + ": " + errs[0].Msg; got != tt.expected {
+			t.Fatalf("%q: wrong error. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTryThrow(t *testing.T) {
+	input := `
+try { throw 1; } catch (e) { print(e); } finally { print(0); }
+try { f(); } catch { }
+try { f(); } finally { }
+`
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements expected 3, got %d", len(program.Statements))
+	}
+	first, ok := program.Statements[0].(*ast.TryStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.TryStatement. got=%T", program.Statements[0])
+	}
+	if _, ok := first.Block.Statements[0].(*ast.ThrowStatement); !ok {
+		t.Fatalf("try body not *ast.ThrowStatement. got=%T", first.Block.Statements[0])
+	}
+	if first.CatchParam == nil || first.CatchParam.Value != "e" || first.FinallyBlock == nil {
+		t.Fatalf("catch/finally not parsed: %s", first.String())
+	}
+	if second := program.Statements[1].(*ast.TryStatement); second.CatchParam != nil || second.CatchBlock == nil {
+		t.Fatalf("catch without parameter not parsed: %s", second.String())
+	}
+
+	p = parser.New(lexer.New("try { f(); }"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected an error for try without catch or finally")
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"fn add(a: int, b) -> int { a + b; }", "fn add(a: int, b) -> int { (a + b); }"},
+		{"let f: fn(int, fn() -> bool) -> any = fn(n: int) -> bool { n > 0 };", "let f: fn(int, fn() -> bool) -> any = fn(n: int) -> bool { (n > 0); };"},
+		{"let g: fn() = fn() {};", "let g: fn() = fn() {};"},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if got := program.String(); got != tt.expected {
+			t.Fatalf("%q: wrong program. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	p := parser.New(lexer.New("let x: 5 = 1;"))
+	p.ParseProgram()
+	if errs := p.RichErrors(); len(errs) == 0 || errs[0].Msg != "expected a type, got INT" || errs[0].Pos.Column != 8 {
+		t.Fatalf("wrong errors. got=%v", errs)
+	}
+}
+
+func TestModules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"import \"lib/str.mg\" as str\n", `import "lib/str.mg" as str;`},
+		{`import "a \"b\".mg" as b; print(b.c);`, `import "a \"b\".mg" as b;print(b.c);`},
+		{"export let x = 1; export fn f(a) { a; }", "export let x = 1;export fn f(a) { a; }"},
+		// a selector binds tighter than a call or an operator
+		{"-m.f(1).g + m.x;", "((-m.f(1).g) + m.x);"},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if got := program.String(); got != tt.expected {
+			t.Fatalf("%q: wrong program. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"import lib as l;", "1:8: expected next token to be STRING, got IDENT instead"},
+		{`import "lib.mg" l;`, "1:17: expected as after the import path, got IDENT"},
+		{"export print(1);", "1:8: expected let or fn after export, got PRINT"},
+		{"export fn() {};", "1:8: expected let or fn after export, got FN"},
+		{"m.1;", "1:3: expected next token to be IDENT, got INT instead"},
+	}
+	for _, tt := range errors {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+		errs := p.RichErrors()
+		if len(errs) == 0 {
+			t.Fatalf("%q: no errors", tt.input)
+		}
+		if got := errs[0].Pos.String() + ": " + errs[0].Msg; got != tt.expected {
+			t.Fatalf("%q: wrong error. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStringsAndArrays(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "a\t𝒳";`, `let s = "a\t𝒳";`},
+		{"[1, -2 + 3, f(x)];", "[1, ((-2) + 3), f(x)];"},
+		{"[];", "[];"},
+		// an index binds like a call
+		{"-a[1][i + 1] * b[0];", "((-a[1][(i + 1)]) * b[0]);"},
+		{"m.xs[0](1);", "m.xs[0](1);"},
+		{`"ab" + s == t;`, `(("ab" + s) == t);`},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if got := program.String(); got != tt.expected {
+			t.Fatalf("%q: wrong program. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1;", "1:4: expected next token to be RBRACKET, got SEMICOLON instead"},
+		{"[1, 2;", "1:6: expected next token to be RBRACKET, got SEMICOLON instead"},
+		{`print("open);`, "1:7: unterminated string"},
+		{"let a = 1 @ 2;", "1:11: unexpected character \"@\""},
+	}
+	for _, tt := range errors {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+		errs := p.RichErrors()
+		if len(errs) == 0 {
+			t.Fatalf("%q: no errors", tt.input)
+		}
+		if got := errs[0].Pos.String() + ": " + errs[0].Msg; got != tt.expected {
+			t.Fatalf("%q: wrong error. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
 }
 
 func checkParserErrors(t *testing.T, p *parser.Parser) {
@@ -130,3 +304,37 @@ func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 
 	return true
 }
+
+// ParseProgram reports bad input as errors rather than panicking. Tools
+// such as the language server walk what it returns even then, and print
+// what it accepts.
+func FuzzParseProgram(f *testing.F) {
+	addExamples(f)
+	f.Add(`let f = fn(1, "x") { 2 };`)
+	f.Add("fn f(a: fn(int) -> ) {}")
+	f.Add("try { } catch ( { }")
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		ast.Inspect(program, func(ast.Node) bool { return true })
+		if len(p.Errors()) == 0 {
+			_ = program.String()
+		}
+	})
+}
+
+// addExamples seeds f with the example programs.
+func addExamples(f *testing.F) {
+	files, _ := filepath.Glob("../../examples/*.mg")
+	lib, _ := filepath.Glob("../../examples/lib/*.mg")
+	if len(files) == 0 {
+		f.Fatalf("no examples found")
+	}
+	for _, file := range append(files, lib...) {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(src))
+	}
+}
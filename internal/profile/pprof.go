@@ -0,0 +1,205 @@
+package profile
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"mingo/internal/object"
+)
+
+// WritePprof writes the profile in the gzipped protocol buffer format of
+// go tool pprof. Its samples are the call stacks of the program, down to
+// a source line, with three values: the instructions run there, the
+// stack samples taken there and the time those samples stand for, at the
+// rate they were taken.
+// Instructions are the default, as short runs may have no samples.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	e := &pprofEncoder{
+		strings:    map[string]int{"": 0},
+		stringList: []string{""},
+		funcs:      map[*object.CompiledFunction]uint64{},
+		locs:       map[location]uint64{},
+	}
+	var prof message
+	for _, st := range [][2]string{{"instructions", "count"}, {"samples", "count"}, {"cpu", "nanoseconds"}} {
+		var vt message
+		vt.int(1, e.str(st[0]))
+		vt.int(2, e.str(st[1]))
+		prof.bytes(1, vt)
+	}
+
+	type sample struct {
+		stack  []uint64
+		values [3]int64
+	}
+	samples := map[string]*sample{}
+	walk(p.root, func(n *node) {
+		for ip := range n.counts {
+			if n.counts[ip] == 0 && n.samples[ip] == 0 {
+				continue
+			}
+			stack := []uint64{e.location(p, n.fn, ip)}
+			for c := n; c.parent != nil; c = c.parent {
+				stack = append(stack, e.location(p, c.parent.fn, c.callIP))
+			}
+			key := fmt.Sprint(stack)
+			s := samples[key]
+			if s == nil {
+				s = &sample{stack: stack}
+				samples[key] = s
+			}
+			s.values[0] += int64(n.counts[ip])
+			s.values[1] += int64(n.samples[ip])
+			s.values[2] += int64(n.samples[ip]) * p.period().Nanoseconds()
+		}
+	})
+	for _, key := range slices.Sorted(maps.Keys(samples)) {
+		s := samples[key]
+		var m message
+		m.packed(1, s.stack)
+		m.packed(2, []uint64{uint64(s.values[0]), uint64(s.values[1]), uint64(s.values[2])})
+		prof.bytes(2, m)
+	}
+	// one mapping for the whole program, whose locations need no
+	// symbolizing
+	var mapping message
+	mapping.int(1, 1)
+	mapping.int(5, e.str(p.name))
+	for field := 7; field <= 9; field++ {
+		mapping.int(field, 1) // has functions, file names and line numbers
+	}
+	prof.bytes(3, mapping)
+	for _, l := range e.locList {
+		prof.bytes(4, l)
+	}
+	for _, f := range e.funcList {
+		prof.bytes(5, f)
+	}
+	prof.int(9, p.start.UnixNano())
+	prof.int(10, p.duration.Nanoseconds())
+	var period message
+	period.int(1, e.str("cpu"))
+	period.int(2, e.str("nanoseconds"))
+	prof.bytes(11, period)
+	prof.int(12, p.period().Nanoseconds())
+	prof.int(14, e.str("instructions"))
+	// fields may come in any order, and this one is complete only now
+	for _, s := range e.stringList {
+		prof.string(6, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(prof); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+type location struct {
+	fn   *object.CompiledFunction
+	line int
+}
+
+type pprofEncoder struct {
+	strings    map[string]int
+	stringList []string
+	funcs      map[*object.CompiledFunction]uint64
+	funcList   []message
+	locs       map[location]uint64
+	locList    []message
+}
+
+func (e *pprofEncoder) str(s string) int64 {
+	i, ok := e.strings[s]
+	if !ok {
+		i = len(e.stringList)
+		e.strings[s] = i
+		e.stringList = append(e.stringList, s)
+	}
+	return int64(i)
+}
+
+// location returns the id of the location of the instruction at ip in fn,
+// which is its line.
+func (e *pprofEncoder) location(p *Profiler, fn *object.CompiledFunction, ip int) uint64 {
+	pos := p.line(fn, ip)
+	loc := location{fn, pos.Line}
+	if id, ok := e.locs[loc]; ok {
+		return id
+	}
+	id := uint64(len(e.locList) + 1)
+	e.locs[loc] = id
+	var line message
+	line.int(1, int64(e.function(p, fn)))
+	line.int(2, int64(pos.Line))
+	var m message
+	m.int(1, int64(id))
+	m.int(2, 1) // the mapping
+	m.bytes(4, line)
+	e.locList = append(e.locList, m)
+	return id
+}
+
+func (e *pprofEncoder) function(p *Profiler, fn *object.CompiledFunction) uint64 {
+	if id, ok := e.funcs[fn]; ok {
+		return id
+	}
+	id := uint64(len(e.funcList) + 1)
+	e.funcs[fn] = id
+	f := p.funcs[fn]
+	// pprof drops what is in angle brackets from names, as C++ template
+	// arguments
+	name := f.Name
+	switch name {
+	case "<main>":
+		name = "main"
+	case "<fn>":
+		name = fmt.Sprintf("fn@%d", f.Pos.Line)
+	}
+	var m message
+	m.int(1, int64(id))
+	m.int(2, e.str(name))
+	m.int(3, e.str(name))
+	m.int(4, e.str(f.Pos.File))
+	m.int(5, int64(f.Pos.Line))
+	e.funcList = append(e.funcList, m)
+	return id
+}
+
+// message is an encoded protocol buffer message; its methods append
+// fields to it, leaving out those with zero values.
+type message []byte
+
+func (m *message) tag(field, wire int) {
+	*m = binary.AppendUvarint(*m, uint64(field<<3|wire))
+}
+
+func (m *message) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	m.tag(field, 0)
+	*m = binary.AppendUvarint(*m, uint64(v))
+}
+
+func (m *message) bytes(field int, b []byte) {
+	m.tag(field, 2)
+	*m = binary.AppendUvarint(*m, uint64(len(b)))
+	*m = append(*m, b...)
+}
+
+// string always writes s, since the string table counts its empty
+// strings too.
+func (m *message) string(field int, s string) { m.bytes(field, []byte(s)) }
+
+func (m *message) packed(field int, vs []uint64) {
+	var b []byte
+	for _, v := range vs {
+		b = binary.AppendUvarint(b, v)
+	}
+	m.bytes(field, b)
+}
@@ -0,0 +1,225 @@
+// Package profile measures where a program spends its time, through the
+// VM's call hook. It counts the instructions each function and source line
+// runs and the calls of each function, times the calls, and samples the
+// call stack at an interval, checking the clock every few instructions.
+// It reports the results as a table of the top functions, lines and
+// opcodes, or as a profile for go tool pprof.
+package profile
+
+import (
+	"time"
+
+	"mingo/internal/code"
+	"mingo/internal/object"
+	"mingo/internal/token"
+	"mingo/internal/vm"
+)
+
+// Profiler is a vm.CallHook that measures the program a VM runs.
+type Profiler struct {
+	name     string // of the main program, whose positions have no file
+	interval time.Duration
+
+	root    *node
+	stack   []frame   // the VM's frames, innermost last
+	next    time.Time // when the next sample is due
+	checks  int       // instructions left until the clock is read
+	samples int       // stack samples taken
+	ops     [256]int  // instructions run, by opcode
+	funcs   map[*object.CompiledFunction]*Func
+
+	start    time.Time
+	duration time.Duration
+}
+
+// node is a calling context: a function reached through a chain of calls.
+// A recursive call goes back to the node of the call it recurses from, so
+// that the tree has no more levels than the program has functions.
+type node struct {
+	fn       *object.CompiledFunction
+	parent   *node
+	callIP   int // offset of the call in the parent's function
+	children map[site]*node
+	counts   []int // instructions run, by offset
+	samples  []int // stack samples taken, by offset
+}
+
+type site struct {
+	fn *object.CompiledFunction
+	ip int
+}
+
+type frame struct {
+	node  *node
+	ip    int // of the instruction running, or the call being made
+	start time.Time
+	calls time.Duration // spent in the calls it made
+}
+
+// Func holds the measurements of one function.
+type Func struct {
+	Name         string         // "<main>" for top-level code, "<fn>" for literals
+	Pos          token.Position // the line its code starts on
+	Calls        int
+	Instructions int
+	// Inclusive time counts the calls the function makes, and the
+	// exclusive time does not. A recursive call is part of the inclusive
+	// time of the outermost one.
+	Inclusive, Exclusive time.Duration
+
+	active int // calls under way
+}
+
+// checkEvery is the number of instructions between readings of the clock,
+// which costs more than running an instruction.
+const checkEvery = 64
+
+// New returns a profiler for a program whose main program is the file
+// name. It samples the call stack every interval, or never if it is 0. As
+// it only reads the clock every checkEvery instructions, and not during
+// a call of a builtin, it can take fewer samples.
+func New(name string, interval time.Duration) *Profiler {
+	return &Profiler{name: name, interval: interval, funcs: map[*object.CompiledFunction]*Func{}}
+}
+
+// Run runs the program on machine under the profiler.
+func (p *Profiler) Run(machine *vm.VM) error {
+	machine.SetHook(p)
+	defer machine.SetHook(nil)
+
+	fn, _ := machine.Location()
+	p.root = p.newNode(fn, nil, 0)
+	p.start = time.Now()
+	p.enter(p.root, p.start)
+	p.next, p.checks = p.start.Add(p.interval), checkEvery
+
+	err := machine.Run()
+	now := time.Now()
+	// an error, or a host aborting the run, can leave frames behind
+	for len(p.stack) > 0 {
+		p.leave(now)
+	}
+	p.duration = now.Sub(p.start)
+	return err
+}
+
+func (p *Profiler) Instruction(machine *vm.VM, op code.Opcode) error {
+	f := &p.stack[len(p.stack)-1]
+	_, f.ip = machine.Location()
+	f.node.counts[f.ip]++
+	p.ops[op]++
+	if p.interval == 0 {
+		return nil
+	}
+	if p.checks--; p.checks > 0 {
+		return nil
+	}
+	p.checks = checkEvery
+	if now := time.Now(); !now.Before(p.next) {
+		f.node.samples[f.ip]++
+		p.samples++
+		p.next = now.Add(p.interval)
+	}
+	return nil
+}
+
+// period returns the time a stack sample stands for: the run's duration
+// over the samples taken, or the interval before any are.
+func (p *Profiler) period() time.Duration {
+	if p.samples == 0 {
+		return p.interval
+	}
+	return p.duration / time.Duration(p.samples)
+}
+
+func (p *Profiler) Enter(machine *vm.VM, fn *object.CompiledFunction) {
+	caller := &p.stack[len(p.stack)-1]
+	n := caller.node
+	for n != nil && n.fn != fn {
+		n = n.parent
+	}
+	if n == nil {
+		s := site{fn, caller.ip}
+		n = caller.node.children[s]
+		if n == nil {
+			n = p.newNode(fn, caller.node, caller.ip)
+			caller.node.children[s] = n
+		}
+	}
+	p.enter(n, time.Now())
+}
+
+func (p *Profiler) Leave(machine *vm.VM) { p.leave(time.Now()) }
+
+func (p *Profiler) newNode(fn *object.CompiledFunction, parent *node, callIP int) *node {
+	return &node{
+		fn:       fn,
+		parent:   parent,
+		callIP:   callIP,
+		children: map[site]*node{},
+		counts:   make([]int, len(fn.Instructions)),
+		samples:  make([]int, len(fn.Instructions)),
+	}
+}
+
+func (p *Profiler) enter(n *node, now time.Time) {
+	p.stack = append(p.stack, frame{node: n, start: now})
+	f := p.function(n)
+	f.Calls++
+	f.active++
+}
+
+func (p *Profiler) leave(now time.Time) {
+	top := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	elapsed := now.Sub(top.start)
+	f := p.function(top.node)
+	f.Exclusive += elapsed - top.calls
+	if f.active--; f.active == 0 {
+		f.Inclusive += elapsed
+	}
+	if len(p.stack) > 0 {
+		p.stack[len(p.stack)-1].calls += elapsed
+	}
+}
+
+// function returns the measurements of n's function.
+func (p *Profiler) function(n *node) *Func {
+	f := p.funcs[n.fn]
+	if f == nil {
+		f = &Func{Name: n.fn.Name}
+		switch {
+		case n == p.root:
+			f.Name = "<main>"
+		case f.Name == "":
+			f.Name = "<fn>"
+		}
+		f.Pos = p.line(n.fn, 0)
+		p.funcs[n.fn] = f
+	}
+	return f
+}
+
+// position names the main program's file in pos.
+func (p *Profiler) position(pos token.Position) token.Position {
+	if pos.File == "" && pos.Line > 0 {
+		pos.File = p.name
+	}
+	return pos
+}
+
+// line returns the source line of the instruction at ip in fn, with the
+// column and offset left out.
+func (p *Profiler) line(fn *object.CompiledFunction, ip int) token.Position {
+	pos, _ := fn.Positions.Lookup(ip)
+	pos = p.position(pos)
+	return token.Position{File: pos.File, Line: pos.Line}
+}
+
+// walk calls visit for n and the nodes below it.
+func walk(n *node, visit func(*node)) {
+	visit(n)
+	for _, c := range n.children {
+		walk(c, visit)
+	}
+}
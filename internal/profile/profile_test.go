@@ -0,0 +1,230 @@
+package profile_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"mingo/internal/compiler"
+	"mingo/internal/module"
+	"mingo/internal/profile"
+	"mingo/internal/vm"
+)
+
+const program = `import "testing" as t;
+fn fact(n) {
+  if (n < 2) {
+    return 1;
+  }
+  n * fact(n - 1);
+}
+let twice = fn(x) { x + x; };
+fact(5);
+twice(fact(3));
+t.assert_error(fn() { fact(true); });
+`
+
+func run(t *testing.T) *profile.Profiler {
+	t.Helper()
+	mods, err := (&module.Loader{}).Load("main.mg", program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp := compiler.New()
+	if err := module.Compile(comp, mods); err != nil {
+		t.Fatal(err)
+	}
+	machine := vm.NewFromBytecode(comp.Bytecode(), nil)
+	machine.SetOutput(io.Discard)
+	// no samples, so that the results do not depend on timing
+	p := profile.New("main.mg", 0)
+	if err := p.Run(machine); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestFunctions(t *testing.T) {
+	p := run(t)
+	var got []string
+	for _, f := range p.Functions() {
+		got = append(got, fmt.Sprintf("%s@%d calls=%d instructions=%d", f.Name, f.Pos.Line, f.Calls, f.Instructions))
+		if f.Exclusive > f.Inclusive || f.Inclusive > p.Duration() {
+			t.Errorf("%s: exclusive %v, inclusive %v, run %v", f.Name, f.Exclusive, f.Inclusive, p.Duration())
+		}
+	}
+	// the order is by time, which varies
+	slices.Sort(got)
+	expected := []string{
+		"<fn>@11 calls=1 instructions=3",
+		"<fn>@8 calls=1 instructions=4",
+		"<main>@2 calls=1 instructions=18",
+		"fact@3 calls=9 instructions=99",
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("wrong functions.\nwant=%q\ngot= %q", expected, got)
+	}
+
+	total := 0
+	for _, op := range p.Opcodes() {
+		total += op.Count
+	}
+	if total != 3+4+18+99 {
+		t.Errorf("opcode counts add up to %d", total)
+	}
+}
+
+func TestLines(t *testing.T) {
+	var got []string
+	for _, l := range run(t).Lines()[:4] {
+		got = append(got, fmt.Sprintf("%s:%d=%d", l.Pos.File, l.Pos.Line, l.Instructions))
+	}
+	expected := []string{"main.mg:6=48", "main.mg:3=47", "main.mg:11=7", "main.mg:8=6"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("wrong top lines.\nwant=%q\ngot= %q", expected, got)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var out strings.Builder
+	if err := run(t).WriteText(&out, 2); err != nil {
+		t.Fatal(err)
+	}
+	// the lines and opcodes have no times in them
+	text := out.String()
+	i := strings.Index(text, "\n  instructions")
+	if i < 0 {
+		t.Fatalf("no lines in %q", text)
+	}
+	expected := `
+  instructions  samples  line
+            48        0  main.mg:6
+            47        0  main.mg:3
+
+  count  opcode
+     23  OpGetLocal
+     22  OpConstant
+`
+	if text[i:] != expected {
+		t.Errorf("want=%q got=%q", expected, text[i:])
+	}
+	if !strings.HasPrefix(text, "124 instructions in ") || !strings.Contains(text, "      9            99  ") {
+		t.Errorf("wrong summary or functions: %q", text)
+	}
+}
+
+// The profiler samples on the VM's goroutine, so a busy VM does not keep
+// it from sampling, even on one thread.
+func TestSampling(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	mods, err := (&module.Loader{}).Load("main.mg", "let i = 0; while (i < 200000) { i = i + 1; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp := compiler.New()
+	if err := module.Compile(comp, mods); err != nil {
+		t.Fatal(err)
+	}
+	p := profile.New("main.mg", time.Millisecond)
+	if err := p.Run(vm.NewFromBytecode(comp.Bytecode(), nil)); err != nil {
+		t.Fatal(err)
+	}
+	samples := 0
+	for _, l := range p.Lines() {
+		samples += l.Samples
+	}
+	if want := int(p.Duration() / time.Millisecond / 2); samples == 0 || samples < want {
+		t.Errorf("%d samples in %v, want at least %d", samples, p.Duration(), want)
+	}
+	var out strings.Builder
+	if err := p.WriteText(&out, 1); err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf(", %d samples, one per ", samples); !strings.Contains(out.String(), want) {
+		t.Errorf("report lacks %q: %q", want, out.String())
+	}
+}
+
+// field is a field of a protocol buffer message, with a varint or bytes.
+type field struct {
+	num   int
+	value uint64
+	bytes []byte
+}
+
+// fields decodes the fields of a protocol buffer message.
+func fields(t *testing.T, b []byte) []field {
+	var fs []field
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		b = b[n:]
+		f := field{num: int(tag >> 3)}
+		switch tag & 7 {
+		case 0:
+			f.value, n = binary.Uvarint(b)
+			b = b[n:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			f.bytes, b = b[n:n+int(size)], b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+func TestWritePprof(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(t).WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var strs []string
+	instructions, locations := 0, 0
+	for _, f := range fields(t, b) {
+		switch f.num {
+		case 2: // sample
+			for _, v := range fields(t, f.bytes) {
+				if v.num == 2 {
+					n, _ := binary.Uvarint(v.bytes) // the first value
+					instructions += int(n)
+				}
+			}
+		case 4:
+			locations++
+		case 6:
+			strs = append(strs, string(f.bytes))
+		}
+	}
+	if instructions != 124 {
+		t.Errorf("samples count %d instructions", instructions)
+	}
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("string table does not start with an empty string: %q", strs)
+	}
+	for _, s := range []string{"instructions", "cpu", "nanoseconds", "main", "fact", "fn@8", "fn@11", "main.mg"} {
+		if !slices.Contains(strs, s) {
+			t.Errorf("string table lacks %q: %q", s, strs)
+		}
+	}
+	// a location is a line in a function, and some lines have two
+	if locations < 8 {
+		t.Errorf("only %d locations", locations)
+	}
+}
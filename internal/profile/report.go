@@ -0,0 +1,141 @@
+package profile
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"mingo/internal/code"
+	"mingo/internal/token"
+)
+
+// Line holds the measurements of one source line.
+type Line struct {
+	Pos          token.Position // with only its file and line
+	Instructions int
+	Samples      int
+}
+
+// Opcode holds the number of times an opcode ran.
+type Opcode struct {
+	Name  string
+	Count int
+}
+
+// Functions returns the measurements of the functions that ran, the one
+// with the most exclusive time first.
+func (p *Profiler) Functions() []*Func {
+	for _, f := range p.funcs {
+		f.Instructions = 0
+	}
+	walk(p.root, func(n *node) {
+		f := p.funcs[n.fn]
+		for _, c := range n.counts {
+			f.Instructions += c
+		}
+	})
+	return slices.SortedFunc(maps.Values(p.funcs), func(a, b *Func) int {
+		return cmp.Or(cmp.Compare(b.Exclusive, a.Exclusive), cmp.Compare(b.Instructions, a.Instructions), comparePos(a.Pos, b.Pos))
+	})
+}
+
+// Lines returns the measurements of the lines that ran, the one that ran
+// the most instructions first.
+func (p *Profiler) Lines() []Line {
+	lines := map[token.Position]*Line{}
+	walk(p.root, func(n *node) {
+		for ip := range n.counts {
+			if n.counts[ip] == 0 && n.samples[ip] == 0 {
+				continue
+			}
+			pos := p.line(n.fn, ip)
+			l := lines[pos]
+			if l == nil {
+				l = &Line{Pos: pos}
+				lines[pos] = l
+			}
+			l.Instructions += n.counts[ip]
+			l.Samples += n.samples[ip]
+		}
+	})
+	sorted := make([]Line, 0, len(lines))
+	for _, l := range lines {
+		sorted = append(sorted, *l)
+	}
+	slices.SortFunc(sorted, func(a, b Line) int {
+		return cmp.Or(cmp.Compare(b.Instructions, a.Instructions), comparePos(a.Pos, b.Pos))
+	})
+	return sorted
+}
+
+// Opcodes returns the opcodes that ran, the most frequent first.
+func (p *Profiler) Opcodes() []Opcode {
+	var ops []Opcode
+	for op, count := range p.ops {
+		if count == 0 {
+			continue
+		}
+		name := fmt.Sprintf("op %d", op)
+		if def, err := code.Lookup(code.Opcode(op)); err == nil {
+			name = def.Name
+		}
+		ops = append(ops, Opcode{Name: name, Count: count})
+	}
+	slices.SortStableFunc(ops, func(a, b Opcode) int { return cmp.Compare(b.Count, a.Count) })
+	return ops
+}
+
+// Duration reports how long the program ran.
+func (p *Profiler) Duration() time.Duration { return p.duration }
+
+func comparePos(a, b token.Position) int {
+	return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+}
+
+// WriteText writes the top n functions by exclusive time, lines by
+// instructions run and opcodes by count.
+func (p *Profiler) WriteText(w io.Writer, n int) error {
+	ops := p.Opcodes()
+	total := 0
+	for _, op := range ops {
+		total += op.Count
+	}
+	lines := p.Lines()
+	fmt.Fprintf(w, "%d instructions in %v", total, p.duration.Round(time.Microsecond))
+	switch {
+	case p.samples > 0:
+		fmt.Fprintf(w, ", %d samples, one per %v", p.samples, p.period().Round(time.Microsecond))
+	case p.interval > 0:
+		fmt.Fprintf(w, ", no samples")
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\ncalls\tinstructions\texclusive\tinclusive\t  function\n")
+	for _, f := range top(p.Functions(), n) {
+		fmt.Fprintf(tw, "%d\t%d\t%v\t%v\t  %s\n", f.Calls, f.Instructions,
+			f.Exclusive.Round(time.Microsecond), f.Inclusive.Round(time.Microsecond), describe(f))
+	}
+	fmt.Fprintf(tw, "\ninstructions\tsamples\t  line\n")
+	for _, l := range top(lines, n) {
+		fmt.Fprintf(tw, "%d\t%d\t  %s:%d\n", l.Instructions, l.Samples, l.Pos.File, l.Pos.Line)
+	}
+	fmt.Fprintf(tw, "\ncount\t  opcode\n")
+	for _, op := range top(ops, n) {
+		fmt.Fprintf(tw, "%d\t  %s\n", op.Count, op.Name)
+	}
+	return tw.Flush()
+}
+
+func top[T any](s []T, n int) []T { return s[:min(n, len(s))] }
+
+func describe(f *Func) string {
+	if f.Pos.Line == 0 {
+		return f.Name
+	}
+	return fmt.Sprintf("%s %s:%d", f.Name, f.Pos.File, f.Pos.Line)
+}
@@ -0,0 +1,227 @@
+// Package resolve binds every identifier of a program to the definition it
+// names, following the compiler's scoping rules: a function sees its own
+// parameters and locals and the globals defined before it, but not the
+// locals of enclosing functions. Blocks do not open scopes. Tools like the
+// language server, the linter and the reference interpreter build on the
+// result.
+package resolve
+
+import (
+	"math"
+	"sort"
+
+	"mingo/internal/ast"
+	"mingo/internal/compiler"
+)
+
+type Kind int
+
+const (
+	Global Kind = iota
+	Local
+	Parameter
+	Function // defined by a fn statement
+)
+
+// Symbol is one definition of a name. Redefining a name with let creates a
+// new symbol, as it does in the compiler.
+type Symbol struct {
+	Name  string
+	Kind  Kind
+	Def   *ast.Identifier
+	Fn    *ast.FunctionStatement // set for functions
+	Owner string                 // enclosing function, "" at top level
+	Refs  []Ref                  // uses, not counting Def
+
+	// Shadows is the definition this one hides: an earlier symbol of the
+	// same scope, or the global a local of a function hides.
+	Shadows *Symbol
+}
+
+// Reads counts the references that read the symbol.
+func (s *Symbol) Reads() int {
+	n := 0
+	for _, r := range s.Refs {
+		if !r.Assign {
+			n++
+		}
+	}
+	return n
+}
+
+// Ref ties an identifier in the source to the symbol it names.
+type Ref struct {
+	Ident  *ast.Identifier
+	Sym    *Symbol
+	Assign bool // the target of an assignment
+}
+
+// Scope mirrors one compiler symbol table: the globals, or the locals of a
+// function. Start and End are the byte offsets bounding the text where its
+// names are visible.
+type Scope struct {
+	Owner      string
+	Func       ast.Node // the function literal or statement; nil for the globals
+	Start, End int
+	Symbols    []*Symbol // in definition order
+
+	table *compiler.SymbolTable
+	own   map[string]*Symbol
+}
+
+// Info is the result of resolving a program.
+type Info struct {
+	Symbols []*Symbol
+	Refs    []Ref    // definitions and uses, sorted by offset
+	Scopes  []*Scope // the globals first
+
+	uses map[*ast.Identifier]*Symbol
+}
+
+// SymbolOf returns the symbol id defines or refers to, or nil if the name
+// is undefined.
+func (info *Info) SymbolOf(id *ast.Identifier) *Symbol { return info.uses[id] }
+
+// Program resolves a program. It works on the partial trees the parser
+// returns for code with syntax errors too.
+func Program(program *ast.Program) *Info {
+	info := &Info{uses: make(map[*ast.Identifier]*Symbol)}
+	global := &Scope{table: compiler.NewSymbolTable(), own: map[string]*Symbol{}, End: math.MaxInt}
+	info.Scopes = append(info.Scopes, global)
+	ast.Walk(&resolver{info: info, global: global, scope: global}, program)
+	sort.SliceStable(info.Refs, func(i, j int) bool {
+		return info.Refs[i].Ident.Token.Pos.Offset < info.Refs[j].Ident.Token.Pos.Offset
+	})
+	return info
+}
+
+// resolver is the ast.Visitor that resolves the names of one scope. A
+// function's body is walked by a resolver of its own, which leaves the
+// scope when the walk of the body ends.
+type resolver struct {
+	info   *Info
+	global *Scope
+	scope  *Scope
+}
+
+// Visit resolves the names node defines and uses. Names are defined in the
+// order the compiler defines them, which is not always the order of the
+// source: the value of a let statement comes before its name, and the
+// finally block of a try statement before its catch block. Such nodes are
+// walked here and not by Walk.
+func (r *resolver) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.LetStatement:
+		ast.Walk(r, n.Value)
+		r.define(n.Name, Global)
+		return nil
+	case *ast.AssignmentStatement:
+		ast.Walk(r, n.Value)
+		r.use(n.Name, true)
+		return nil
+	case *ast.Identifier:
+		// its type annotation names no variables
+		r.use(n, false)
+		return nil
+	case *ast.FunctionStatement:
+		if n.Name == nil {
+			return nil
+		}
+		// defined first, so the body can call itself
+		if sym := r.define(n.Name, Function); sym != nil {
+			sym.Fn = n
+		}
+		r.function(n, n.Name.Value, n.Parameters, n.Body)
+		return nil
+	case *ast.FunctionLiteral:
+		r.function(n, "<fn>", n.Parameters, n.Body)
+		return nil
+	case *ast.TryStatement:
+		ast.Walk(r, n.Block)
+		ast.Walk(r, n.FinallyBlock)
+		r.define(n.CatchParam, Global)
+		ast.Walk(r, n.CatchBlock)
+		return nil
+	case *ast.ImportStatement, *ast.SelectorExpression:
+		// the import name and the member are not variables of this
+		// module
+		return nil
+	}
+	return r
+}
+
+// function resolves the body of a function in a scope of its own.
+func (r *resolver) function(fn ast.Node, owner string, params []*ast.Identifier, body *ast.BlockStatement) {
+	if body == nil {
+		return
+	}
+	inner := &resolver{info: r.info, global: r.global, scope: &Scope{
+		table: r.scope.table.NewEnclosed(),
+		own:   map[string]*Symbol{},
+		Owner: owner,
+		Func:  fn,
+		Start: body.Token.Pos.Offset + 1,
+		End:   body.Rbrace.Offset,
+	}}
+	r.info.Scopes = append(r.info.Scopes, inner.scope)
+	for _, p := range params {
+		inner.define(p, Parameter)
+	}
+	ast.Walk(inner, body)
+}
+
+func (r *resolver) define(id *ast.Identifier, kind Kind) *Symbol {
+	if id == nil {
+		return nil
+	}
+	if kind == Global && r.scope != r.global {
+		kind = Local
+	}
+	sym := &Symbol{Name: id.Value, Kind: kind, Def: id, Owner: r.scope.Owner}
+	if prev := r.scope.own[id.Value]; prev != nil {
+		sym.Shadows = prev
+	} else if r.scope != r.global {
+		sym.Shadows = r.global.own[id.Value]
+	}
+	r.scope.table.Define(id.Value)
+	r.scope.own[id.Value] = sym
+	r.scope.Symbols = append(r.scope.Symbols, sym)
+	r.info.Symbols = append(r.info.Symbols, sym)
+	r.info.Refs = append(r.info.Refs, Ref{Ident: id, Sym: sym})
+	r.info.uses[id] = sym
+	return sym
+}
+
+func (r *resolver) use(id *ast.Identifier, assign bool) {
+	if id == nil {
+		return
+	}
+	found, ok := r.scope.table.Resolve(id.Value)
+	if !ok {
+		return
+	}
+	sym := r.scope.own[id.Value]
+	if found.Scope == compiler.GlobalScope {
+		sym = r.global.own[id.Value]
+	} else if sym == nil {
+		// the compiler rejects this, but the name still leads somewhere
+		sym = r.enclosing(id.Value)
+	}
+	if sym != nil {
+		ref := Ref{Ident: id, Sym: sym, Assign: assign}
+		sym.Refs = append(sym.Refs, ref)
+		r.info.Refs = append(r.info.Refs, ref)
+		r.info.uses[id] = sym
+	}
+}
+
+// enclosing finds the innermost enclosing function's local called name.
+func (r *resolver) enclosing(name string) *Symbol {
+	for i := len(r.info.Scopes) - 1; i > 0; i-- {
+		s := r.info.Scopes[i]
+		if s != r.scope && s.Start <= r.scope.Start && r.scope.End <= s.End && s.own[name] != nil {
+			return s.own[name]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package stdlib
+
+import "mingo/internal/object"
+
+// Arrays are values: push returns a new array rather than growing its
+// argument.
+var arraysModule = &Module{Name: "arrays", Members: []Member{
+	builtin("arrays", "len", 1, func(args ...object.Object) (object.Object, *object.Error) {
+		arr, err := array("arrays.len", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return object.NewInteger(int64(len(arr.Elements))), nil
+	}),
+	builtin("arrays", "push", 2, func(args ...object.Object) (object.Object, *object.Error) {
+		arr, err := array("arrays.push", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		elems := make([]object.Object, len(arr.Elements), len(arr.Elements)+1)
+		copy(elems, arr.Elements)
+		return &object.Array{Elements: append(elems, args[1])}, nil
+	}),
+}}
+
+// array is str for array arguments.
+func array(name string, args []object.Object, i int) (*object.Array, *object.Error) {
+	arr, ok := args[i].(*object.Array)
+	if !ok {
+		return nil, errorf(object.TypeErrorKind, "%s: argument %d must be ARRAY, got %s", name, i+1, args[i].Type())
+	}
+	return arr, nil
+}
@@ -0,0 +1,171 @@
+package stdlib
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"mingo/internal/object"
+)
+
+// Capabilities are what the host running a script lets its io functions
+// do. The zero value grants nothing.
+type Capabilities struct {
+	// Root is the directory scripts see; their paths are relative to it
+	// and cannot leave it, even through symbolic links. Empty denies all
+	// file access.
+	Root string
+	// ReadOnly denies writing.
+	ReadOnly bool
+}
+
+// ioModule is registered without capabilities; a VM whose host grants
+// some runs the functions Bind returns instead.
+var ioModule = newIOModule(Capabilities{})
+
+// Bind returns a copy of Builtins whose io functions have caps.
+func Bind(caps Capabilities) []object.Object {
+	builtins := slices.Clone(Builtins)
+	for i, m := range newIOModule(caps).Members {
+		builtins[ioModule.Members[i].Index] = m.Value
+	}
+	return builtins
+}
+
+func newIOModule(caps Capabilities) *Module {
+	return &Module{Name: "io", Members: []Member{
+		builtin("io", "read_file", 1, func(args ...object.Object) (object.Object, *object.Error) {
+			b, err := caps.read("io.read_file", args)
+			if err != nil {
+				return nil, err
+			}
+			return &object.String{Value: string(b)}, nil
+		}),
+		// a line iterator would need a loop construct to drive it; an
+		// array of the lines works with while and arrays.len
+		builtin("io", "read_lines", 1, func(args ...object.Object) (object.Object, *object.Error) {
+			b, err := caps.read("io.read_lines", args)
+			if err != nil {
+				return nil, err
+			}
+			var lines []object.Object
+			for line := range strings.Lines(string(b)) {
+				line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+				lines = append(lines, &object.String{Value: line})
+			}
+			return &object.Array{Elements: lines}, nil
+		}),
+		builtin("io", "write_file", 2, func(args ...object.Object) (object.Object, *object.Error) {
+			path, err := str("io.write_file", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			content, err := str("io.write_file", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			root, err := caps.open("io.write_file", path, true)
+			if err != nil {
+				return nil, err
+			}
+			defer root.Close()
+			if dir := filepath.Dir(path); dir != "." {
+				if err := root.MkdirAll(dir, 0o755); err != nil {
+					return nil, ioError("io.write_file", path, err)
+				}
+			}
+			if err := root.WriteFile(path, []byte(content), 0o644); err != nil {
+				return nil, ioError("io.write_file", path, err)
+			}
+			return object.NULL, nil
+		}),
+		builtin("io", "list_dir", 1, func(args ...object.Object) (object.Object, *object.Error) {
+			path, err := str("io.list_dir", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			root, err := caps.open("io.list_dir", path, false)
+			if err != nil {
+				return nil, err
+			}
+			defer root.Close()
+			entries, rerr := fs.ReadDir(root.FS(), filepath.ToSlash(path))
+			if rerr != nil {
+				return nil, ioError("io.list_dir", path, rerr)
+			}
+			// sorted by name; directories end in a slash
+			names := make([]object.Object, len(entries))
+			for i, e := range entries {
+				name := e.Name()
+				if e.IsDir() {
+					name += "/"
+				}
+				names[i] = &object.String{Value: name}
+			}
+			return &object.Array{Elements: names}, nil
+		}),
+		builtin("io", "exists", 1, func(args ...object.Object) (object.Object, *object.Error) {
+			path, err := str("io.exists", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			root, err := caps.open("io.exists", path, false)
+			if err != nil {
+				return nil, err
+			}
+			defer root.Close()
+			_, serr := root.Stat(path)
+			if serr != nil && !errors.Is(serr, fs.ErrNotExist) {
+				return nil, ioError("io.exists", path, serr)
+			}
+			return object.NativeBool(serr == nil), nil
+		}),
+	}}
+}
+
+// open checks that caps allow the builtin name to use path, writing to it
+// if write is set, and opens the root path is relative to.
+func (caps Capabilities) open(name, path string, write bool) (*os.Root, *object.Error) {
+	switch {
+	case caps.Root == "":
+		return nil, errorf(object.PermissionErrorKind, "%s: no file access was granted", name)
+	case write && caps.ReadOnly:
+		return nil, errorf(object.PermissionErrorKind, "%s: file access is read-only", name)
+	case path != "." && !filepath.IsLocal(path):
+		return nil, errorf(object.PermissionErrorKind, "%s: path %q is outside the root", name, path)
+	}
+	root, err := os.OpenRoot(caps.Root)
+	if err != nil {
+		return nil, errorf(object.IOErrorKind, "%s: %v", name, err)
+	}
+	return root, nil
+}
+
+func (caps Capabilities) read(name string, args []object.Object) ([]byte, *object.Error) {
+	path, err := str(name, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	root, err := caps.open(name, path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	b, rerr := root.ReadFile(path)
+	if rerr != nil {
+		return nil, ioError(name, path, rerr)
+	}
+	return b, nil
+}
+
+// ioError reports err without the host's path to the root.
+func ioError(name, path string, err error) *object.Error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return errorf(object.IOErrorKind, "%s: %s: %v", name, path, err)
+}
@@ -0,0 +1,93 @@
+package stdlib_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mingo/internal/object"
+	"mingo/internal/stdlib"
+)
+
+// bound finds an io function as Bind(caps) has it.
+func bound(t *testing.T, caps stdlib.Capabilities, name string) *object.Builtin {
+	t.Helper()
+	for _, m := range stdlib.Lookup("io").Members {
+		if m.Name == name {
+			return stdlib.Bind(caps)[m.Index].(*object.Builtin)
+		}
+	}
+	t.Fatalf("io.%s not found", name)
+	return nil
+}
+
+func TestIO(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("𝒳\r\ntwo\n\nlast"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("s"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	rw := stdlib.Capabilities{Root: root}
+	ro := stdlib.Capabilities{Root: root, ReadOnly: true}
+	tests := []struct {
+		caps     stdlib.Capabilities
+		name     string
+		args     []object.Object
+		expected string
+	}{
+		{rw, "read_file", []object.Object{str("a.txt")}, "𝒳\r\ntwo\n\nlast"},
+		{rw, "read_lines", []object.Object{str("a.txt")}, `["𝒳", "two", "", "last"]`},
+		{rw, "exists", []object.Object{str("a.txt")}, "true"},
+		{rw, "exists", []object.Object{str("b.txt")}, "false"},
+		{rw, "write_file", []object.Object{str("d/e/b.txt"), str("𝒳")}, "null"},
+		{rw, "read_file", []object.Object{str("d/e/b.txt")}, "𝒳"},
+		{rw, "list_dir", []object.Object{str(".")}, `["a.txt", "d/", "link"]`},
+		{rw, "read_file", []object.Object{str("gone")}, "IOError: io.read_file: gone: no such file or directory"},
+		{rw, "read_file", []object.Object{num(1)}, "TypeError: io.read_file: argument 1 must be STRING, got INTEGER"},
+		{ro, "read_file", []object.Object{str("a.txt")}, "𝒳\r\ntwo\n\nlast"},
+
+		// the sandbox
+		{stdlib.Capabilities{}, "read_file", []object.Object{str("a.txt")}, "PermissionError: io.read_file: no file access was granted"},
+		{stdlib.Capabilities{}, "exists", []object.Object{str("a.txt")}, "PermissionError: io.exists: no file access was granted"},
+		{ro, "write_file", []object.Object{str("c.txt"), str("")}, "PermissionError: io.write_file: file access is read-only"},
+		{rw, "read_file", []object.Object{str("../a.txt")}, `PermissionError: io.read_file: path "../a.txt" is outside the root`},
+		{rw, "list_dir", []object.Object{str(root)}, `PermissionError: io.list_dir: path "` + root + `" is outside the root`},
+		{rw, "read_file", []object.Object{str("link/secret")}, "IOError: io.read_file: link/secret: path escapes from parent"},
+	}
+	for _, tt := range tests {
+		result, err := bound(t, tt.caps, tt.name).Fn(tt.args...)
+		got := ""
+		if err != nil {
+			got = err.Inspect()
+		} else {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("io.%s%s with %+v: want=%q got=%q", tt.name, arr(tt.args...).Inspect(), tt.caps, tt.expected, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "c.txt")); err == nil {
+		t.Errorf("read-only io.write_file wrote a file")
+	}
+}
+
+// Binding capabilities leaves the shared builtins without them.
+func TestBindCopies(t *testing.T) {
+	stdlib.Bind(stdlib.Capabilities{Root: t.TempDir()})
+	for _, m := range stdlib.Lookup("io").Members {
+		if m.Name != "exists" {
+			continue
+		}
+		_, err := stdlib.Builtins[m.Index].(*object.Builtin).Fn(str("."))
+		if err == nil || err.Kind != object.PermissionErrorKind {
+			t.Fatalf("shared io.exists has file access: %v", err)
+		}
+	}
+}
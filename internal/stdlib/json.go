@@ -0,0 +1,430 @@
+package stdlib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"mingo/internal/object"
+)
+
+// maxDepth bounds how deeply arrays and objects nest in the JSON
+// json.parse reads.
+const maxDepth = 512
+
+// JSON objects become maps, keeping the order of their keys, and numbers
+// become integers: a number with a fraction or an exponent is an error
+// until the language has floats.
+var jsonModule = &Module{Name: "json", Members: []Member{
+	builtin("json", "parse", 1, func(args ...object.Object) (object.Object, *object.Error) {
+		src, err := str("json.parse", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return parseJSON(src)
+	}),
+	// indent is optional: without it, or with 0, the JSON is on one line
+	builtin("json", "stringify", -1, func(args ...object.Object) (object.Object, *object.Error) {
+		if len(args) != 1 && len(args) != 2 {
+			return nil, errorf(object.ArgumentErrorKind, "wrong number of arguments to json.stringify: want=1 or 2, got=%d", len(args))
+		}
+		e := &encoder{}
+		if len(args) == 2 {
+			n, err := integer("json.stringify", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 || n > 16 {
+				return nil, errorf(object.ValueErrorKind, "json.stringify: indent %d is not between 0 and 16", n)
+			}
+			e.indent = int(n)
+		}
+		if err := e.encode(args[0], 0); err != nil {
+			return nil, err
+		}
+		return &object.String{Value: e.out.String()}, nil
+	}),
+}}
+
+type decoder struct {
+	src   string
+	pos   int // offset of the next byte
+	depth int
+}
+
+func parseJSON(src string) (object.Object, *object.Error) {
+	d := &decoder{src: src}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	d.space()
+	if d.pos < len(d.src) {
+		return nil, d.unexpected("after the value")
+	}
+	return v, nil
+}
+
+// errorf reports an error at offset.
+func (d *decoder) errorf(offset int, format string, args ...any) *object.Error {
+	line, col := 1, 1
+	for _, r := range d.src[:offset] {
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return errorf(object.ValueErrorKind, "json.parse: %d:%d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+// unexpected reports the character at the current offset.
+func (d *decoder) unexpected(context string) *object.Error {
+	if d.pos >= len(d.src) {
+		return d.errorf(d.pos, "unexpected end of input %s", context)
+	}
+	r, w := utf8.DecodeRuneInString(d.src[d.pos:])
+	if r == utf8.RuneError && w == 1 {
+		return d.errorf(d.pos, "invalid UTF-8")
+	}
+	return d.errorf(d.pos, "unexpected %q %s", r, context)
+}
+
+func (d *decoder) space() {
+	for d.pos < len(d.src) {
+		switch d.src[d.pos] {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (d *decoder) peek() byte {
+	if d.pos < len(d.src) {
+		return d.src[d.pos]
+	}
+	return 0
+}
+
+func (d *decoder) value() (object.Object, *object.Error) {
+	d.space()
+	switch c := d.peek(); {
+	case c == '{':
+		return d.object()
+	case c == '[':
+		return d.array()
+	case c == '"':
+		s, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		return &object.String{Value: s}, nil
+	case c == '-' || '0' <= c && c <= '9':
+		return d.number()
+	case 'a' <= c && c <= 'z':
+		start := d.pos
+		for 'a' <= d.peek() && d.peek() <= 'z' {
+			d.pos++
+		}
+		switch word := d.src[start:d.pos]; word {
+		case "true":
+			return object.TRUE, nil
+		case "false":
+			return object.FALSE, nil
+		case "null":
+			return object.NULL, nil
+		default:
+			return nil, d.errorf(start, "invalid value %s", word)
+		}
+	}
+	return nil, d.unexpected("looking for a value")
+}
+
+// nest enters an array or object.
+func (d *decoder) nest() *object.Error {
+	d.depth++
+	if d.depth > maxDepth {
+		return d.errorf(d.pos, "nested deeper than %d", maxDepth)
+	}
+	d.pos++
+	d.space()
+	return nil
+}
+
+func (d *decoder) array() (object.Object, *object.Error) {
+	if err := d.nest(); err != nil {
+		return nil, err
+	}
+	arr := &object.Array{Elements: []object.Object{}}
+	if d.peek() == ']' {
+		d.pos++
+		d.depth--
+		return arr, nil
+	}
+	for {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		arr.Elements = append(arr.Elements, v)
+		d.space()
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case ']':
+			d.pos++
+			d.depth--
+			return arr, nil
+		default:
+			return nil, d.unexpected("after an array element; want ',' or ']'")
+		}
+	}
+}
+
+func (d *decoder) object() (object.Object, *object.Error) {
+	if err := d.nest(); err != nil {
+		return nil, err
+	}
+	m := object.NewMap()
+	if d.peek() == '}' {
+		d.pos++
+		d.depth--
+		return m, nil
+	}
+	for {
+		d.space()
+		if d.peek() != '"' {
+			return nil, d.unexpected("looking for an object key")
+		}
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		d.space()
+		if d.peek() != ':' {
+			return nil, d.unexpected("after an object key; want ':'")
+		}
+		d.pos++
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		m.Set(key, v)
+		d.space()
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case '}':
+			d.pos++
+			d.depth--
+			return m, nil
+		default:
+			return nil, d.unexpected("after an object value; want ',' or '}'")
+		}
+	}
+}
+
+func (d *decoder) string() (string, *object.Error) {
+	start := d.pos
+	d.pos++ // the opening quote
+	var out strings.Builder
+	for {
+		if d.pos >= len(d.src) {
+			return "", d.errorf(start, "unterminated string")
+		}
+		r, w := utf8.DecodeRuneInString(d.src[d.pos:])
+		switch {
+		case r == '"':
+			d.pos++
+			return out.String(), nil
+		case r == utf8.RuneError && w == 1:
+			return "", d.errorf(d.pos, "invalid UTF-8")
+		case r < 0x20:
+			return "", d.errorf(d.pos, "control character %U in string", r)
+		case r == '\\':
+			if err := d.escape(&out); err != nil {
+				return "", err
+			}
+			continue
+		}
+		out.WriteRune(r)
+		d.pos += w
+	}
+}
+
+var escapes = map[byte]rune{'"': '"', '\\': '\\', '/': '/', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t'}
+
+// escape decodes the escape sequence at the current offset.
+func (d *decoder) escape(out *strings.Builder) *object.Error {
+	start := d.pos
+	d.pos++
+	if d.pos >= len(d.src) {
+		return d.errorf(start, "unterminated string")
+	}
+	c := d.src[d.pos]
+	d.pos++
+	if r, ok := escapes[c]; ok {
+		out.WriteRune(r)
+		return nil
+	}
+	if c != 'u' {
+		r, _ := utf8.DecodeRuneInString(d.src[d.pos-1:])
+		return d.errorf(start, `invalid escape \%c`, r)
+	}
+	r, ok := d.hex4()
+	if !ok {
+		return d.errorf(start, `invalid \u escape`)
+	}
+	if utf16.IsSurrogate(r) {
+		// the second half of a pair must follow
+		save := d.pos
+		if strings.HasPrefix(d.src[d.pos:], `\u`) {
+			d.pos += 2
+			if r2, ok := d.hex4(); ok {
+				if pair := utf16.DecodeRune(r, r2); pair != utf8.RuneError {
+					out.WriteRune(pair)
+					return nil
+				}
+			}
+		}
+		d.pos = save
+		r = utf8.RuneError
+	}
+	out.WriteRune(r)
+	return nil
+}
+
+func (d *decoder) hex4() (rune, bool) {
+	if d.pos+4 > len(d.src) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(d.src[d.pos:d.pos+4], 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	d.pos += 4
+	return rune(n), true
+}
+
+func (d *decoder) number() (object.Object, *object.Error) {
+	start := d.pos
+	if d.peek() == '-' {
+		d.pos++
+	}
+	digits := d.pos
+	for '0' <= d.peek() && d.peek() <= '9' {
+		d.pos++
+	}
+	switch {
+	case d.pos == digits:
+		return nil, d.unexpected("in a number; want a digit")
+	case d.src[digits] == '0' && d.pos-digits > 1:
+		return nil, d.errorf(start, "number %s has a leading zero", d.src[start:d.pos])
+	}
+	if c := d.peek(); c == '.' || c == 'e' || c == 'E' {
+		for strings.IndexByte("0123456789.eE+-", d.peek()) >= 0 {
+			d.pos++
+		}
+		return nil, d.errorf(start, "number %s is not an integer", d.src[start:d.pos])
+	}
+	n, err := strconv.ParseInt(d.src[start:d.pos], 10, 64)
+	if err != nil {
+		return nil, d.errorf(start, "number %s is out of range", d.src[start:d.pos])
+	}
+	return object.NewInteger(n), nil
+}
+
+type encoder struct {
+	out    strings.Builder
+	indent int
+	active []object.Object // the arrays and maps being encoded
+}
+
+func (e *encoder) encode(o object.Object, depth int) *object.Error {
+	switch o := o.(type) {
+	case *object.Integer, *object.Boolean, *object.Null:
+		e.out.WriteString(o.Inspect())
+	case *object.String:
+		e.quote(o.Value)
+	case *object.Array:
+		return e.container(o, "[", "]", len(o.Elements), depth, func(i int) *object.Error {
+			return e.encode(o.Elements[i], depth+1)
+		})
+	case *object.Map:
+		return e.container(o, "{", "}", len(o.Keys), depth, func(i int) *object.Error {
+			e.quote(o.Keys[i])
+			e.out.WriteByte(':')
+			if e.indent > 0 {
+				e.out.WriteByte(' ')
+			}
+			return e.encode(o.Pairs[o.Keys[i]], depth+1)
+		})
+	default:
+		return errorf(object.TypeErrorKind, "json.stringify: cannot encode %s", o.Type())
+	}
+	return nil
+}
+
+// container writes the n elements of an array or map between open and
+// close, one to a line when indenting.
+func (e *encoder) container(o object.Object, open, close string, n, depth int, elem func(i int) *object.Error) *object.Error {
+	for _, a := range e.active {
+		if a == o {
+			return errorf(object.ValueErrorKind, "json.stringify: %s contains itself", o.Type())
+		}
+	}
+	e.active = append(e.active, o)
+	defer func() { e.active = e.active[:len(e.active)-1] }()
+
+	e.out.WriteString(open)
+	for i := range n {
+		if i > 0 {
+			e.out.WriteByte(',')
+		}
+		e.newline(depth + 1)
+		if err := elem(i); err != nil {
+			return err
+		}
+	}
+	if n > 0 {
+		e.newline(depth)
+	}
+	e.out.WriteString(close)
+	return nil
+}
+
+func (e *encoder) newline(depth int) {
+	if e.indent > 0 {
+		e.out.WriteByte('\n')
+		e.out.WriteString(strings.Repeat(" ", depth*e.indent))
+	}
+}
+
+func (e *encoder) quote(s string) {
+	e.out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			e.out.WriteString(`\"`)
+		case '\\':
+			e.out.WriteString(`\\`)
+		case '\n':
+			e.out.WriteString(`\n`)
+		case '\r':
+			e.out.WriteString(`\r`)
+		case '\t':
+			e.out.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&e.out, `\u%04x`, r)
+			} else {
+				e.out.WriteRune(r)
+			}
+		}
+	}
+	e.out.WriteByte('"')
+}
@@ -0,0 +1,145 @@
+package stdlib_test
+
+import (
+	"strings"
+	"testing"
+
+	"mingo/internal/object"
+)
+
+func TestJSONParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": [1, -2, {}], "a": null, "t": true, "f": false, "e": []}`, `{"b": [1, -2, {}], "a": null, "t": true, "f": false, "e": []}`},
+		{` "𝒳\n\t\"\\\/é𝒳" `, "𝒳\n\t\"\\/é𝒳"},
+		{`"\ud835"`, "�"},
+		{`{"a": 1, "a": 2}`, `{"a": 2}`},
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"0", "0"},
+
+		// errors count lines and runes from 1, like the lexer
+		{"", "ValueError: json.parse: 1:1: unexpected end of input looking for a value"},
+		{"{\"𝒳\": 1,\n  \"b\" 2}", "ValueError: json.parse: 2:7: unexpected '2' after an object key; want ':'"},
+		{`["𝒳" 1]`, "ValueError: json.parse: 1:6: unexpected '1' after an array element; want ',' or ']'"},
+		{`{"a": 1,}`, "ValueError: json.parse: 1:9: unexpected '}' looking for an object key"},
+		{`{"a": 1 "b": 2}`, `ValueError: json.parse: 1:9: unexpected '"' after an object value; want ',' or '}'`},
+		{"[1,", "ValueError: json.parse: 1:4: unexpected end of input looking for a value"},
+		{"[1, tru]", "ValueError: json.parse: 1:5: invalid value tru"},
+		{"'a'", `ValueError: json.parse: 1:1: unexpected '\'' looking for a value`},
+		{"1 2", "ValueError: json.parse: 1:3: unexpected '2' after the value"},
+		{"[1.5]", "ValueError: json.parse: 1:2: number 1.5 is not an integer"},
+		{"2e3", "ValueError: json.parse: 1:1: number 2e3 is not an integer"},
+		{"01", "ValueError: json.parse: 1:1: number 01 has a leading zero"},
+		{"-", "ValueError: json.parse: 1:2: unexpected end of input in a number; want a digit"},
+		{"9223372036854775808", "ValueError: json.parse: 1:1: number 9223372036854775808 is out of range"},
+		{`"ab`, "ValueError: json.parse: 1:1: unterminated string"},
+		{`"a\x"`, `ValueError: json.parse: 1:3: invalid escape \x`},
+		{`"\u12g4"`, `ValueError: json.parse: 1:2: invalid \u escape`},
+		{"\"a\tb\"", "ValueError: json.parse: 1:3: control character U+0009 in string"},
+		{"\"\xff\"", "ValueError: json.parse: 1:2: invalid UTF-8"},
+		{strings.Repeat("[", 513), "ValueError: json.parse: 1:513: nested deeper than 512"},
+	}
+	parse := lookup(t, "json.parse")
+	for _, tt := range tests {
+		result, err := parse.Fn(str(tt.input))
+		got := ""
+		if err != nil {
+			got = err.Inspect()
+		} else {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("json.parse(%q): want=%q got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestJSONStringify(t *testing.T) {
+	m := object.NewMap()
+	m.Set("s", str("𝒳\"\n\x01"))
+	m.Set("a", arr(num(1), object.TRUE, object.NULL, arr(), object.NewMap()))
+	shared := arr(num(1))
+
+	tests := []struct {
+		args     []object.Object
+		expected string
+	}{
+		{[]object.Object{m}, `{"s":"𝒳\"\n\u0001","a":[1,true,null,[],{}]}`},
+		{[]object.Object{m, num(2)}, "{\n  \"s\": \"𝒳\\\"\\n\\u0001\",\n  \"a\": [\n    1,\n    true,\n    null,\n    [],\n    {}\n  ]\n}"},
+		{[]object.Object{str("𝒳"), num(0)}, `"𝒳"`},
+		// a value may appear twice without being a cycle
+		{[]object.Object{arr(shared, shared)}, "[[1],[1]]"},
+		{[]object.Object{arr(num(1), object.NewInteger(2)), num(-1)}, "ValueError: json.stringify: indent -1 is not between 0 and 16"},
+		{[]object.Object{arr(lookup(t, "json.parse"))}, "TypeError: json.stringify: cannot encode BUILTIN"},
+		{[]object.Object{}, "ArgumentError: wrong number of arguments to json.stringify: want=1 or 2, got=0"},
+	}
+	stringify := lookup(t, "json.stringify")
+	for _, tt := range tests {
+		result, err := stringify.Fn(tt.args...)
+		got := ""
+		if err != nil {
+			got = err.Inspect()
+		} else {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("json.stringify%s: want=%q got=%q", arr(tt.args...).Inspect(), tt.expected, got)
+		}
+	}
+
+	// a map holding an array holding the map
+	cyclic := object.NewMap()
+	cyclic.Set("xs", arr(num(1), cyclic))
+	if _, err := stringify.Fn(cyclic); err == nil || err.Inspect() != "ValueError: json.stringify: MAP contains itself" {
+		t.Errorf("cycle not detected: %v", err)
+	}
+	if got := cyclic.Inspect(); got != `{"xs": [1, {...}]}` {
+		t.Errorf("wrong Inspect of a cyclic map: %q", got)
+	}
+
+	// stringify then parse gives the same value back
+	encoded, err := stringify.Fn(m, num(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := lookup(t, "json.parse").Fn(encoded)
+	if err != nil || back.Inspect() != m.Inspect() {
+		t.Errorf("round trip: want=%q got=%v %v", m.Inspect(), back, err)
+	}
+}
+
+func TestMaps(t *testing.T) {
+	m := object.NewMap()
+	tests := []struct {
+		name     string
+		args     []object.Object
+		expected string
+	}{
+		{"maps.set", []object.Object{m, str("𝒳"), num(1)}, "null"},
+		{"maps.set", []object.Object{m, str("b"), num(2)}, "null"},
+		{"maps.set", []object.Object{m, str("𝒳"), num(3)}, "null"},
+		{"maps.keys", []object.Object{m}, `["𝒳", "b"]`},
+		{"maps.get", []object.Object{m, str("𝒳"), num(0)}, "3"},
+		{"maps.get", []object.Object{m, str("c"), num(0)}, "0"},
+		{"maps.has", []object.Object{m, str("b")}, "true"},
+		{"maps.remove", []object.Object{m, str("𝒳")}, "true"},
+		{"maps.remove", []object.Object{m, str("𝒳")}, "false"},
+		{"maps.len", []object.Object{m}, "1"},
+		{"maps.set", []object.Object{m, num(1), num(1)}, "TypeError: maps.set: argument 2 must be STRING, got INTEGER"},
+		{"maps.len", []object.Object{arr()}, "TypeError: maps.len: argument 1 must be MAP, got ARRAY"},
+	}
+	for _, tt := range tests {
+		result, err := lookup(t, tt.name).Fn(tt.args...)
+		got := ""
+		if err != nil {
+			got = err.Inspect()
+		} else {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("%s: want=%q got=%q", tt.name, tt.expected, got)
+		}
+	}
+}
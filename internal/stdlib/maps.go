@@ -0,0 +1,81 @@
+package stdlib
+
+import "mingo/internal/object"
+
+// Maps, unlike arrays, change in place: set and remove update the map
+// they are given. m["key"] reads a key, raising a KeyError when it is
+// missing; get returns a default instead.
+var mapsModule = &Module{Name: "maps", Members: []Member{
+	builtin("maps", "new", 0, func(args ...object.Object) (object.Object, *object.Error) {
+		return object.NewMap(), nil
+	}),
+	builtin("maps", "len", 1, func(args ...object.Object) (object.Object, *object.Error) {
+		m, err := mapArg("maps.len", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return object.NewInteger(int64(len(m.Keys))), nil
+	}),
+	builtin("maps", "keys", 1, func(args ...object.Object) (object.Object, *object.Error) {
+		m, err := mapArg("maps.keys", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]object.Object, len(m.Keys))
+		for i, k := range m.Keys {
+			keys[i] = &object.String{Value: k}
+		}
+		return &object.Array{Elements: keys}, nil
+	}),
+	builtin("maps", "has", 2, func(args ...object.Object) (object.Object, *object.Error) {
+		m, key, err := mapKey("maps.has", args)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := m.Pairs[key]
+		return object.NativeBool(ok), nil
+	}),
+	builtin("maps", "get", 3, func(args ...object.Object) (object.Object, *object.Error) {
+		m, key, err := mapKey("maps.get", args)
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := m.Pairs[key]; ok {
+			return v, nil
+		}
+		return args[2], nil
+	}),
+	builtin("maps", "set", 3, func(args ...object.Object) (object.Object, *object.Error) {
+		m, key, err := mapKey("maps.set", args)
+		if err != nil {
+			return nil, err
+		}
+		m.Set(key, args[2])
+		return object.NULL, nil
+	}),
+	builtin("maps", "remove", 2, func(args ...object.Object) (object.Object, *object.Error) {
+		m, key, err := mapKey("maps.remove", args)
+		if err != nil {
+			return nil, err
+		}
+		return object.NativeBool(m.Delete(key)), nil
+	}),
+}}
+
+// mapArg is str for map arguments.
+func mapArg(name string, args []object.Object, i int) (*object.Map, *object.Error) {
+	m, ok := args[i].(*object.Map)
+	if !ok {
+		return nil, errorf(object.TypeErrorKind, "%s: argument %d must be MAP, got %s", name, i+1, args[i].Type())
+	}
+	return m, nil
+}
+
+func mapKey(name string, args []object.Object) (*object.Map, string, *object.Error) {
+	m, err := mapArg(name, args, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := str(name, args, 1)
+	return m, key, err
+}
@@ -0,0 +1,171 @@
+package stdlib
+
+import (
+	"math"
+
+	"mingo/internal/object"
+)
+
+// The math module works on integers. Like the arithmetic operators, abs and
+// pow wrap around on overflow; their checked_ variants, and checked_ forms
+// of + - * and negation, raise an OverflowError instead. Float functions
+// will join them once the language has floats.
+var mathModule = &Module{Name: "math", Members: []Member{
+	{Name: "MAX_INT", Value: object.NewInteger(math.MaxInt64)},
+	{Name: "MIN_INT", Value: object.NewInteger(math.MinInt64)},
+
+	intFunc("abs", 1, func(n []int64) (int64, *object.Error) { return abs(n[0]), nil }),
+	intFunc("sign", 1, func(n []int64) (int64, *object.Error) {
+		switch {
+		case n[0] > 0:
+			return 1, nil
+		case n[0] < 0:
+			return -1, nil
+		}
+		return 0, nil
+	}),
+	intFunc("min", 2, func(n []int64) (int64, *object.Error) { return min(n[0], n[1]), nil }),
+	intFunc("max", 2, func(n []int64) (int64, *object.Error) { return max(n[0], n[1]), nil }),
+	intFunc("clamp", 3, func(n []int64) (int64, *object.Error) {
+		if n[1] > n[2] {
+			return 0, errorf(object.ValueErrorKind, "math.clamp: lower bound %d is above upper bound %d", n[1], n[2])
+		}
+		return min(max(n[0], n[1]), n[2]), nil
+	}),
+	intFunc("pow", 2, func(n []int64) (int64, *object.Error) {
+		r, _, err := pow("math.pow", n[0], n[1])
+		return r, err
+	}),
+	intFunc("gcd", 2, func(n []int64) (int64, *object.Error) {
+		a, b := uabs(n[0]), uabs(n[1])
+		for b != 0 {
+			a, b = b, a%b
+		}
+		if a > math.MaxInt64 {
+			return 0, overflow("math.gcd")
+		}
+		return int64(a), nil
+	}),
+	intFunc("sqrt", 1, func(n []int64) (int64, *object.Error) {
+		if n[0] < 0 {
+			return 0, errorf(object.ValueErrorKind, "math.sqrt: negative argument %d", n[0])
+		}
+		// the float estimate can be off by one either way for large n
+		r := int64(math.Sqrt(float64(n[0])))
+		for r > 0 && r > n[0]/r {
+			r--
+		}
+		for r+1 <= n[0]/(r+1) {
+			r++
+		}
+		return r, nil
+	}),
+
+	intFunc("checked_add", 2, func(n []int64) (int64, *object.Error) {
+		r := n[0] + n[1]
+		if (r > n[0]) != (n[1] > 0) {
+			return 0, overflow("math.checked_add")
+		}
+		return r, nil
+	}),
+	intFunc("checked_sub", 2, func(n []int64) (int64, *object.Error) {
+		r := n[0] - n[1]
+		if (r < n[0]) != (n[1] > 0) {
+			return 0, overflow("math.checked_sub")
+		}
+		return r, nil
+	}),
+	intFunc("checked_mul", 2, func(n []int64) (int64, *object.Error) {
+		r, ok := mul(n[0], n[1])
+		if !ok {
+			return 0, overflow("math.checked_mul")
+		}
+		return r, nil
+	}),
+	intFunc("checked_neg", 1, func(n []int64) (int64, *object.Error) {
+		if n[0] == math.MinInt64 {
+			return 0, overflow("math.checked_neg")
+		}
+		return -n[0], nil
+	}),
+	intFunc("checked_abs", 1, func(n []int64) (int64, *object.Error) {
+		if n[0] == math.MinInt64 {
+			return 0, overflow("math.checked_abs")
+		}
+		return abs(n[0]), nil
+	}),
+	intFunc("checked_pow", 2, func(n []int64) (int64, *object.Error) {
+		r, ok, err := pow("math.checked_pow", n[0], n[1])
+		if err == nil && !ok {
+			err = overflow("math.checked_pow")
+		}
+		return r, err
+	}),
+}}
+
+// intFunc defines a math function of integers returning an integer.
+func intFunc(name string, arity int, fn func(n []int64) (int64, *object.Error)) Member {
+	return builtin("math", name, arity, func(args ...object.Object) (object.Object, *object.Error) {
+		n, err := ints("math."+name, args)
+		if err != nil {
+			return nil, err
+		}
+		r, err := fn(n)
+		if err != nil {
+			return nil, err
+		}
+		return object.NewInteger(r), nil
+	})
+}
+
+func overflow(name string) *object.Error {
+	return errorf(object.OverflowKind, "%s: integer overflow", name)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func uabs(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// mul multiplies with wraparound and reports whether the result is exact.
+func mul(a, b int64) (int64, bool) {
+	r := a * b
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	if r/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return r, false
+	}
+	return r, true
+}
+
+// pow raises base to exp by squaring, with wraparound, and reports
+// whether the result is exact.
+func pow(name string, base, exp int64) (int64, bool, *object.Error) {
+	if exp < 0 {
+		return 0, false, errorf(object.ValueErrorKind, "%s: negative exponent %d", name, exp)
+	}
+	r, exact := int64(1), true
+	for exp > 0 {
+		var ok bool
+		if exp&1 == 1 {
+			r, ok = mul(r, base)
+			exact = exact && ok
+		}
+		exp >>= 1
+		if exp > 0 {
+			base, ok = mul(base, base)
+			exact = exact && ok
+		}
+	}
+	return r, exact, nil
+}
@@ -0,0 +1,107 @@
+package stdlib_test
+
+import (
+	"strings"
+	"testing"
+
+	"mingo/internal/object"
+	"mingo/internal/stdlib"
+)
+
+// call calls a member of the math module with integer arguments.
+func call(t *testing.T, name string, args ...int64) (object.Object, *object.Error) {
+	t.Helper()
+	for _, m := range stdlib.Lookup("math").Members {
+		if m.Name != name {
+			continue
+		}
+		objs := make([]object.Object, len(args))
+		for i, a := range args {
+			objs[i] = object.NewInteger(a)
+		}
+		return m.Value.(*object.Builtin).Fn(objs...)
+	}
+	t.Fatalf("math.%s not found", name)
+	return nil, nil
+}
+
+const (
+	maxInt = 1<<63 - 1
+	minInt = -1 << 63
+)
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []int64
+		expected string
+	}{
+		{"abs", []int64{-5}, "5"},
+		{"abs", []int64{minInt}, "-9223372036854775808"},
+		{"sign", []int64{-7}, "-1"},
+		{"min", []int64{3, -2}, "-2"},
+		{"max", []int64{3, -2}, "3"},
+		{"clamp", []int64{12, 0, 10}, "10"},
+		{"clamp", []int64{-1, 0, 10}, "0"},
+		{"clamp", []int64{1, 10, 0}, "ValueError: math.clamp: lower bound 10 is above upper bound 0"},
+		{"pow", []int64{3, 4}, "81"},
+		{"pow", []int64{-2, 63}, "-9223372036854775808"},
+		{"pow", []int64{2, 64}, "0"},
+		{"pow", []int64{2, -1}, "ValueError: math.pow: negative exponent -1"},
+		{"gcd", []int64{-12, 18}, "6"},
+		{"gcd", []int64{0, 0}, "0"},
+		{"gcd", []int64{minInt, 0}, "OverflowError: math.gcd: integer overflow"},
+		{"sqrt", []int64{99}, "9"},
+		{"sqrt", []int64{maxInt}, "3037000499"},
+		{"sqrt", []int64{-4}, "ValueError: math.sqrt: negative argument -4"},
+		{"checked_add", []int64{maxInt - 1, 1}, "9223372036854775807"},
+		{"checked_add", []int64{maxInt, 1}, "OverflowError: math.checked_add: integer overflow"},
+		{"checked_add", []int64{minInt, -1}, "OverflowError: math.checked_add: integer overflow"},
+		{"checked_sub", []int64{minInt, 1}, "OverflowError: math.checked_sub: integer overflow"},
+		{"checked_sub", []int64{-1, maxInt}, "-9223372036854775808"},
+		{"checked_mul", []int64{1 << 31, 1 << 31}, "4611686018427387904"},
+		{"checked_mul", []int64{1 << 32, 1 << 31}, "OverflowError: math.checked_mul: integer overflow"},
+		{"checked_mul", []int64{-1, minInt}, "OverflowError: math.checked_mul: integer overflow"},
+		{"checked_neg", []int64{minInt}, "OverflowError: math.checked_neg: integer overflow"},
+		{"checked_abs", []int64{-3}, "3"},
+		{"checked_pow", []int64{-2, 63}, "-9223372036854775808"},
+		{"checked_pow", []int64{2, 63}, "OverflowError: math.checked_pow: integer overflow"},
+	}
+
+	for _, tt := range tests {
+		result, err := call(t, tt.name, tt.args...)
+		got := ""
+		if err != nil {
+			got = err.Inspect()
+		} else {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("math.%s%v: want=%q got=%q", tt.name, tt.args, tt.expected, got)
+		}
+	}
+}
+
+func TestMathTypes(t *testing.T) {
+	for _, m := range stdlib.Lookup("math").Members {
+		b, ok := m.Value.(*object.Builtin)
+		if !ok {
+			continue
+		}
+		if !strings.HasPrefix(b.Name, "math.") || b.Name[len("math."):] != m.Name {
+			t.Errorf("builtin %s is registered as %s", b.Name, m.Name)
+		}
+		args := make([]object.Object, b.Arity)
+		for i := range args {
+			args[i] = object.NewInteger(1)
+		}
+		args[b.Arity-1] = object.TRUE
+		_, err := b.Fn(args...)
+		if err == nil || err.Kind != object.TypeErrorKind {
+			t.Errorf("%s accepted a boolean: %v", b.Name, err)
+		}
+	}
+	if stdlib.Builtins[stdlib.Lookup("math").Members[0].Index].Inspect() != "9223372036854775807" {
+		t.Errorf("math.MAX_INT is not where its index points")
+	}
+}
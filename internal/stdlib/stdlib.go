@@ -0,0 +1,79 @@
+// Package stdlib is the standard library: modules of builtins, native
+// functions and constants, that a program imports by name rather than by
+// file:
+//
+//	import "math" as math;
+//	print(math.max(math.abs(-3), 2));
+//
+// The compiler turns a member of a standard module into an OpGetBuiltin
+// instruction whose operand indexes Builtins, where the VM finds its value.
+package stdlib
+
+import (
+	"fmt"
+
+	"mingo/internal/object"
+)
+
+// Module is a standard library module.
+type Module struct {
+	Name    string
+	Members []Member
+}
+
+// Member is a named value a module exports.
+type Member struct {
+	Name  string
+	Value object.Object
+	Index int // in Builtins
+}
+
+// Builtins holds the value of every member of every module.
+var Builtins []object.Object
+
+var modules = map[string]*Module{}
+
+func register(m *Module) {
+	for i := range m.Members {
+		m.Members[i].Index = len(Builtins)
+		Builtins = append(Builtins, m.Members[i].Value)
+	}
+	modules[m.Name] = m
+}
+
+func init() {
+	register(mathModule)
+	register(stringsModule)
+	register(arraysModule)
+	register(ioModule)
+	register(mapsModule)
+	register(jsonModule)
+	register(testingModule)
+}
+
+// Lookup returns the standard module an import path names, or nil.
+func Lookup(path string) *Module { return modules[path] }
+
+// builtin defines a member function of the module named module.
+func builtin(module, name string, arity int, fn object.BuiltinFunction) Member {
+	qualified := module + "." + name
+	return Member{Name: name, Value: &object.Builtin{Name: qualified, Arity: arity, Fn: fn}}
+}
+
+func errorf(kind, format string, args ...any) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// ints checks that every argument of the builtin name is an integer and
+// returns their values.
+func ints(name string, args []object.Object) ([]int64, *object.Error) {
+	ns := make([]int64, len(args))
+	for i, a := range args {
+		n, ok := a.(*object.Integer)
+		if !ok {
+			return nil, errorf(object.TypeErrorKind, "%s: argument %d must be INTEGER, got %s", name, i+1, a.Type())
+		}
+		ns[i] = n.Value
+	}
+	return ns, nil
+}
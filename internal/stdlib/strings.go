@@ -0,0 +1,206 @@
+package stdlib
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"mingo/internal/object"
+)
+
+// maxString bounds the strings strings.repeat builds.
+const maxString = 1 << 28
+
+// The strings module works on runes, the way the lexer reads source: len
+// counts them and slice and index take and return rune offsets, so "𝒳" has
+// length 1 though it is four bytes of UTF-8.
+var stringsModule = &Module{Name: "strings", Members: []Member{
+	builtin("strings", "len", 1, func(args ...object.Object) (object.Object, *object.Error) {
+		s, err := str("strings.len", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return object.NewInteger(int64(utf8.RuneCountInString(s))), nil
+	}),
+	builtin("strings", "slice", 3, func(args ...object.Object) (object.Object, *object.Error) {
+		s, err := str("strings.slice", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		lo, err := integer("strings.slice", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := integer("strings.slice", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		runes := []rune(s)
+		if lo < 0 || hi < lo || hi > int64(len(runes)) {
+			return nil, errorf(object.IndexErrorKind, "strings.slice: range [%d:%d] out of range for string of length %d", lo, hi, len(runes))
+		}
+		return &object.String{Value: string(runes[lo:hi])}, nil
+	}),
+	builtin("strings", "split", 2, func(args ...object.Object) (object.Object, *object.Error) {
+		s, sep, err := str2("strings.split", args)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(s, sep)
+		elems := make([]object.Object, len(parts))
+		for i, p := range parts {
+			elems[i] = &object.String{Value: p}
+		}
+		return &object.Array{Elements: elems}, nil
+	}),
+	builtin("strings", "join", 2, func(args ...object.Object) (object.Object, *object.Error) {
+		arr, err := array("strings.join", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := str("strings.join", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		parts := make([]string, len(arr.Elements))
+		for i, el := range arr.Elements {
+			s, ok := el.(*object.String)
+			if !ok {
+				return nil, errorf(object.TypeErrorKind, "strings.join: element %d must be STRING, got %s", i, el.Type())
+			}
+			parts[i] = s.Value
+		}
+		return &object.String{Value: strings.Join(parts, sep)}, nil
+	}),
+	stringFunc("trim", strings.TrimSpace),
+	stringFunc("upper", strings.ToUpper),
+	stringFunc("lower", strings.ToLower),
+	predicate("contains", strings.Contains),
+	predicate("starts_with", strings.HasPrefix),
+	predicate("ends_with", strings.HasSuffix),
+	builtin("strings", "index", 2, func(args ...object.Object) (object.Object, *object.Error) {
+		s, sub, err := str2("strings.index", args)
+		if err != nil {
+			return nil, err
+		}
+		i := strings.Index(s, sub)
+		if i > 0 {
+			i = utf8.RuneCountInString(s[:i])
+		}
+		return object.NewInteger(int64(i)), nil
+	}),
+	builtin("strings", "replace", 3, func(args ...object.Object) (object.Object, *object.Error) {
+		s, old, err := str2("strings.replace", args)
+		if err != nil {
+			return nil, err
+		}
+		repl, err := str("strings.replace", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		return &object.String{Value: strings.ReplaceAll(s, old, repl)}, nil
+	}),
+	builtin("strings", "repeat", 2, func(args ...object.Object) (object.Object, *object.Error) {
+		s, err := str("strings.repeat", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		n, err := integer("strings.repeat", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, errorf(object.ValueErrorKind, "strings.repeat: negative count %d", n)
+		}
+		if len(s) > 0 && n > maxString/int64(len(s)) {
+			return nil, errorf(object.ValueErrorKind, "strings.repeat: result longer than %d bytes", maxString)
+		}
+		return &object.String{Value: strings.Repeat(s, int(n))}, nil
+	}),
+	builtin("strings", "format", -1, format),
+}}
+
+// format replaces each {} in its first argument with the next of the
+// others, as print would show it; {{ and }} stand for literal braces.
+func format(args ...object.Object) (object.Object, *object.Error) {
+	if len(args) == 0 {
+		return nil, errorf(object.ArgumentErrorKind, "strings.format: missing format string")
+	}
+	f, err := str("strings.format", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out strings.Builder
+	used := 0
+	for i := 0; i < len(f); i++ {
+		switch {
+		case strings.HasPrefix(f[i:], "{{"), strings.HasPrefix(f[i:], "}}"):
+			out.WriteByte(f[i])
+			i++
+		case strings.HasPrefix(f[i:], "{}"):
+			if used == len(args)-1 {
+				return nil, errorf(object.ArgumentErrorKind, "strings.format: too few arguments for %q", f)
+			}
+			used++
+			out.WriteString(args[used].Inspect())
+			i++
+		case f[i] == '{' || f[i] == '}':
+			return nil, errorf(object.ValueErrorKind, "strings.format: unmatched %c at offset %d", f[i], utf8.RuneCountInString(f[:i]))
+		default:
+			out.WriteByte(f[i])
+		}
+	}
+	if used < len(args)-1 {
+		return nil, errorf(object.ArgumentErrorKind, "strings.format: %d arguments for %d placeholders in %q", len(args)-1, used, f)
+	}
+	return &object.String{Value: out.String()}, nil
+}
+
+// stringFunc defines a strings function from a string to a string.
+func stringFunc(name string, fn func(string) string) Member {
+	return builtin("strings", name, 1, func(args ...object.Object) (object.Object, *object.Error) {
+		s, err := str("strings."+name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &object.String{Value: fn(s)}, nil
+	})
+}
+
+// predicate defines a strings function from two strings to a boolean.
+func predicate(name string, fn func(s, t string) bool) Member {
+	return builtin("strings", name, 2, func(args ...object.Object) (object.Object, *object.Error) {
+		s, t, err := str2("strings."+name, args)
+		if err != nil {
+			return nil, err
+		}
+		return object.NativeBool(fn(s, t)), nil
+	})
+}
+
+// str checks that argument i of the builtin name is a string and returns
+// its value.
+func str(name string, args []object.Object, i int) (string, *object.Error) {
+	s, ok := args[i].(*object.String)
+	if !ok {
+		return "", errorf(object.TypeErrorKind, "%s: argument %d must be STRING, got %s", name, i+1, args[i].Type())
+	}
+	return s.Value, nil
+}
+
+// integer is str for integer arguments.
+func integer(name string, args []object.Object, i int) (int64, *object.Error) {
+	n, ok := args[i].(*object.Integer)
+	if !ok {
+		return 0, errorf(object.TypeErrorKind, "%s: argument %d must be INTEGER, got %s", name, i+1, args[i].Type())
+	}
+	return n.Value, nil
+}
+
+func str2(name string, args []object.Object) (string, string, *object.Error) {
+	s, err := str(name, args, 0)
+	if err != nil {
+		return "", "", err
+	}
+	t, err := str(name, args, 1)
+	return s, t, err
+}
@@ -0,0 +1,108 @@
+package stdlib_test
+
+import (
+	"strings"
+	"testing"
+
+	"mingo/internal/object"
+	"mingo/internal/stdlib"
+)
+
+// lookup finds a builtin by its qualified name, such as strings.len.
+func lookup(t *testing.T, qualified string) *object.Builtin {
+	t.Helper()
+	module, name, _ := strings.Cut(qualified, ".")
+	if m := stdlib.Lookup(module); m != nil {
+		for _, member := range m.Members {
+			if member.Name == name {
+				return member.Value.(*object.Builtin)
+			}
+		}
+	}
+	t.Fatalf("%s not found", qualified)
+	return nil
+}
+
+func str(s string) object.Object { return &object.String{Value: s} }
+
+func num(n int64) object.Object { return object.NewInteger(n) }
+
+func arr(elems ...object.Object) object.Object { return &object.Array{Elements: elems} }
+
+func TestStrings(t *testing.T) {
+	// "𝒳" is one rune of four bytes, as in the lexer's tests
+	tests := []struct {
+		name     string
+		args     []object.Object
+		expected string
+	}{
+		{"strings.len", []object.Object{str("𝒳 trailing")}, "10"},
+		{"strings.len", []object.Object{str("")}, "0"},
+		{"strings.slice", []object.Object{str("a𝒳b"), num(1), num(2)}, "𝒳"},
+		{"strings.slice", []object.Object{str("a𝒳b"), num(2), num(3)}, "b"},
+		{"strings.slice", []object.Object{str("a𝒳b"), num(3), num(3)}, ""},
+		{"strings.slice", []object.Object{str("a𝒳b"), num(1), num(4)}, "IndexError: strings.slice: range [1:4] out of range for string of length 3"},
+		{"strings.slice", []object.Object{str("a𝒳b"), num(2), num(1)}, "IndexError: strings.slice: range [2:1] out of range for string of length 3"},
+		{"strings.slice", []object.Object{str("a"), num(0), object.TRUE}, "TypeError: strings.slice: argument 3 must be INTEGER, got BOOLEAN"},
+		{"strings.index", []object.Object{str("𝒳𝒳b"), str("b")}, "2"},
+		{"strings.index", []object.Object{str("𝒳𝒳b"), str("𝒳")}, "0"},
+		{"strings.index", []object.Object{str("𝒳𝒳b"), str("c")}, "-1"},
+		{"strings.split", []object.Object{str("a,𝒳,,b"), str(",")}, `["a", "𝒳", "", "b"]`},
+		{"strings.split", []object.Object{str("a𝒳"), str("")}, `["a", "𝒳"]`},
+		{"strings.join", []object.Object{arr(str("a"), str("𝒳")), str("-")}, "a-𝒳"},
+		{"strings.join", []object.Object{arr(), str("-")}, ""},
+		{"strings.join", []object.Object{arr(str("a"), num(1)), str("-")}, "TypeError: strings.join: element 1 must be STRING, got INTEGER"},
+		{"strings.join", []object.Object{str("a"), str("-")}, "TypeError: strings.join: argument 1 must be ARRAY, got STRING"},
+		{"strings.trim", []object.Object{str(" \t𝒳 x\n")}, "𝒳 x"},
+		{"strings.contains", []object.Object{str("a𝒳b"), str("𝒳")}, "true"},
+		{"strings.contains", []object.Object{str("ab"), str("ba")}, "false"},
+		{"strings.replace", []object.Object{str("𝒳-𝒳"), str("𝒳"), str("x")}, "x-x"},
+		{"strings.upper", []object.Object{str("éa 𝒳")}, "ÉA 𝒳"},
+		{"strings.lower", []object.Object{str("ÀB")}, "àb"},
+		{"strings.repeat", []object.Object{str("𝒳"), num(3)}, "𝒳𝒳𝒳"},
+		{"strings.repeat", []object.Object{str("ab"), num(0)}, ""},
+		{"strings.repeat", []object.Object{str("ab"), num(-1)}, "ValueError: strings.repeat: negative count -1"},
+		{"strings.repeat", []object.Object{str("ab"), num(1 << 62)}, "ValueError: strings.repeat: result longer than 268435456 bytes"},
+		{"strings.starts_with", []object.Object{str("𝒳a"), str("𝒳")}, "true"},
+		{"strings.ends_with", []object.Object{str("𝒳a"), str("𝒳")}, "false"},
+		{"strings.upper", []object.Object{num(1)}, "TypeError: strings.upper: argument 1 must be STRING, got INTEGER"},
+		{"strings.contains", []object.Object{str("a"), object.NULL}, "TypeError: strings.contains: argument 2 must be STRING, got NULL"},
+
+		{"strings.format", []object.Object{str("{} + {} = {}"), num(1), num(2), num(3)}, "1 + 2 = 3"},
+		{"strings.format", []object.Object{str("𝒳{}𝒳"), str("a")}, "𝒳a𝒳"},
+		{"strings.format", []object.Object{str("{{{}}}"), arr(str("x"), object.TRUE)}, `{["x", true]}`},
+		{"strings.format", []object.Object{str("no placeholders")}, "no placeholders"},
+		{"strings.format", []object.Object{str("{}")}, `ArgumentError: strings.format: too few arguments for "{}"`},
+		{"strings.format", []object.Object{str("{}"), num(1), num(2)}, `ArgumentError: strings.format: 2 arguments for 1 placeholders in "{}"`},
+		{"strings.format", []object.Object{str("𝒳 {")}, "ValueError: strings.format: unmatched { at offset 2"},
+		{"strings.format", []object.Object{str("}")}, "ValueError: strings.format: unmatched } at offset 0"},
+		{"strings.format", nil, "ArgumentError: strings.format: missing format string"},
+
+		{"arrays.len", []object.Object{arr(str("𝒳"), num(1))}, "2"},
+		{"arrays.push", []object.Object{arr(num(1)), str("𝒳")}, `[1, "𝒳"]`},
+		{"arrays.len", []object.Object{str("ab")}, "TypeError: arrays.len: argument 1 must be ARRAY, got STRING"},
+	}
+
+	for _, tt := range tests {
+		result, err := lookup(t, tt.name).Fn(tt.args...)
+		got := ""
+		if err != nil {
+			got = err.Inspect()
+		} else {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("%s%s: want=%q got=%q", tt.name, arr(tt.args...).Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestPushCopies(t *testing.T) {
+	a := &object.Array{Elements: []object.Object{num(1)}}
+	if _, err := lookup(t, "arrays.push").Fn(a, num(2)); err != nil {
+		t.Fatal(err)
+	}
+	if a.Inspect() != "[1]" {
+		t.Fatalf("arrays.push changed its argument: %s", a.Inspect())
+	}
+}
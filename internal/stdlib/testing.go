@@ -0,0 +1,160 @@
+package stdlib
+
+import (
+	"strings"
+
+	"mingo/internal/object"
+	"mingo/internal/token"
+)
+
+// The testing module holds the assertions test files use. A failed
+// assertion raises an AssertionError at its call, which the test runner
+// reports with the call's position.
+var testingModule = &Module{Name: "testing", Members: []Member{
+	// assert(cond) or assert(cond, message)
+	builtin("testing", "assert", -1, func(args ...object.Object) (object.Object, *object.Error) {
+		if len(args) != 1 && len(args) != 2 {
+			return nil, errorf(object.ArgumentErrorKind, "wrong number of arguments to testing.assert: want=1 or 2, got=%d", len(args))
+		}
+		cond, ok := args[0].(*object.Boolean)
+		if !ok {
+			return nil, errorf(object.TypeErrorKind, "testing.assert: argument 1 must be BOOLEAN, got %s", args[0].Type())
+		}
+		if cond.Value {
+			return object.NULL, nil
+		}
+		if len(args) == 2 {
+			return nil, errorf(object.AssertionErrorKind, "%s", args[1].Inspect())
+		}
+		return nil, errorf(object.AssertionErrorKind, "assertion failed")
+	}),
+	builtin("testing", "assert_eq", 2, func(args ...object.Object) (object.Object, *object.Error) {
+		got, want := args[0], args[1]
+		if equal(got, want, 0) {
+			return object.NULL, nil
+		}
+		g, gok := got.(*object.String)
+		w, wok := want.(*object.String)
+		if gok && wok && (strings.Contains(g.Value, "\n") || strings.Contains(w.Value, "\n")) {
+			return nil, errorf(object.AssertionErrorKind, "strings differ (-want +got):\n%s", diff(w.Value, g.Value))
+		}
+		return nil, errorf(object.AssertionErrorKind, "got %s, want %s", show(got), show(want))
+	}),
+	// assert_error(fn) or assert_error(fn, kind) calls fn and returns the
+	// error it raises
+	{Name: "assert_error", Value: &object.Builtin{Name: "testing.assert_error", Arity: -1,
+		Call: func(call object.Caller, args ...object.Object) (object.Object, *object.Error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, errorf(object.ArgumentErrorKind, "wrong number of arguments to testing.assert_error: want=1 or 2, got=%d", len(args))
+			}
+			kind := ""
+			if len(args) == 2 {
+				k, err := str("testing.assert_error", args, 1)
+				if err != nil {
+					return nil, err
+				}
+				kind = k
+			}
+			_, raised := call(args[0])
+			switch {
+			case raised == nil && kind == "":
+				return nil, errorf(object.AssertionErrorKind, "no error was raised")
+			case raised == nil:
+				return nil, errorf(object.AssertionErrorKind, "want a %s, but no error was raised", kind)
+			case kind != "" && raised.Kind != kind:
+				return nil, errorf(object.AssertionErrorKind, "want a %s, got %s", kind, raised.Inspect())
+			}
+			return raised, nil
+		}}},
+}}
+
+// equal compares arrays and maps by their contents, to a depth that stops
+// it following cycles.
+func equal(a, b object.Object, depth int) bool {
+	if a == b {
+		return true
+	}
+	if depth > 100 {
+		return false
+	}
+	switch a := a.(type) {
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.Boolean:
+		b, ok := b.(*object.Boolean)
+		return ok && a.Value == b.Value
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	case *object.Array:
+		b, ok := b.(*object.Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i], depth+1) {
+				return false
+			}
+		}
+		return true
+	case *object.Map:
+		b, ok := b.(*object.Map)
+		if !ok || len(a.Keys) != len(b.Keys) {
+			return false
+		}
+		for k, v := range a.Pairs {
+			w, ok := b.Pairs[k]
+			if !ok || !equal(v, w, depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// show quotes strings, so that "1" and 1 read differently.
+func show(o object.Object) string {
+	if s, ok := o.(*object.String); ok {
+		return token.Quote(s.Value)
+	}
+	return o.Inspect()
+}
+
+// diff lists the lines of want and got, marking those only in want with
+// - and those only in got with +.
+func diff(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of w[i:]
+	// and g[j:]
+	lcs := make([][]int, len(w)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(g)+1)
+	}
+	for i := len(w) - 1; i >= 0; i-- {
+		for j := len(g) - 1; j >= 0; j-- {
+			if w[i] == g[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out []string
+	i, j := 0, 0
+	for i < len(w) || j < len(g) {
+		switch {
+		case i < len(w) && j < len(g) && w[i] == g[j]:
+			out = append(out, "  "+w[i])
+			i, j = i+1, j+1
+		case j == len(g) || i < len(w) && lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+w[i])
+			i++
+		default:
+			out = append(out, "+ "+g[j])
+			j++
+		}
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,58 @@
+package stdlib_test
+
+import (
+	"testing"
+
+	"mingo/internal/object"
+)
+
+func TestAssertions(t *testing.T) {
+	m := object.NewMap()
+	m.Set("a", arr(num(1)))
+	same := object.NewMap()
+	same.Set("a", arr(num(1)))
+
+	tests := []struct {
+		name     string
+		args     []object.Object
+		expected string
+	}{
+		{"testing.assert", []object.Object{object.TRUE}, "null"},
+		{"testing.assert", []object.Object{object.FALSE}, "AssertionError: assertion failed"},
+		{"testing.assert", []object.Object{object.FALSE, str("too big")}, "AssertionError: too big"},
+		{"testing.assert", []object.Object{num(1)}, "TypeError: testing.assert: argument 1 must be BOOLEAN, got INTEGER"},
+		{"testing.assert", nil, "ArgumentError: wrong number of arguments to testing.assert: want=1 or 2, got=0"},
+		{"testing.assert_eq", []object.Object{arr(num(1), str("𝒳")), arr(num(1), str("𝒳"))}, "null"},
+		{"testing.assert_eq", []object.Object{m, same}, "null"},
+		{"testing.assert_eq", []object.Object{num(1), str("1")}, `AssertionError: got 1, want "1"`},
+		{"testing.assert_eq", []object.Object{arr(num(1)), arr(num(2))}, "AssertionError: got [1], want [2]"},
+		{"testing.assert_eq", []object.Object{m, object.NewMap()}, `AssertionError: got {"a": [1]}, want {}`},
+		{"testing.assert_eq", []object.Object{str("a\nx\nc"), str("a\nb\nc")},
+			"AssertionError: strings differ (-want +got):\n  a\n- b\n+ x\n  c"},
+		{"testing.assert_eq", []object.Object{str("a\n"), str("a")},
+			"AssertionError: strings differ (-want +got):\n  a\n+ "},
+	}
+
+	for _, tt := range tests {
+		result, err := lookup(t, tt.name).Fn(tt.args...)
+		got := ""
+		if err != nil {
+			got = err.Inspect()
+		} else {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("%s%s: want=%q got=%q", tt.name, arr(tt.args...).Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestAssertEqualCycles(t *testing.T) {
+	a, b := object.NewMap(), object.NewMap()
+	a.Set("self", a)
+	b.Set("self", b)
+	_, err := lookup(t, "testing.assert_eq").Fn(a, b)
+	if err == nil || err.Kind != object.AssertionErrorKind {
+		t.Fatalf("want an AssertionError, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+// Package tester runs the tests in Mingo test files. A test is a top-level
+// function without parameters whose name starts with test_:
+//
+//	import "testing" as t;
+//	fn test_sum() { t.assert_eq(1 + 2, 3); }
+//
+// Each test runs in a VM of its own: the file's top-level code runs first,
+// then the test function. A test fails if it raises an error, such as the
+// AssertionError of a failed assertion.
+package tester
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"mingo/internal/ast"
+	"mingo/internal/compiler"
+	"mingo/internal/cover"
+	"mingo/internal/module"
+	"mingo/internal/token"
+	"mingo/internal/vm"
+)
+
+// Result is the outcome of one test.
+type Result struct {
+	Name string
+	Pos  token.Position // of the test function's name
+	// Err is nil if the test passed. Its positions in the test file name
+	// the file, like those in the modules it imports.
+	Err    error
+	Output string // what the test printed
+}
+
+// Runner runs the tests of files.
+type Runner struct {
+	// Run, if set, selects the tests to run by name.
+	Run *regexp.Regexp
+	// NewVM, if set, prepares each test's VM, as a host would.
+	NewVM func(*compiler.Bytecode) *vm.VM
+	// Coverage, if not nil, gathers the coverage of the modules the tests
+	// import; that of the test files themselves is left out.
+	Coverage cover.Profile
+}
+
+// Find returns the test files the paths name, in order: a file is used as
+// it is, and a directory stands for the *_test.mg files in it and below,
+// skipping node_modules and directories whose names start with . or _.
+func Find(paths []string) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && path != root && (strings.HasPrefix(d.Name(), ".") || strings.HasPrefix(d.Name(), "_") || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), "_test.mg") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// RunFile runs the tests in the file path that r selects. It fails for a
+// file that does not load, type-check or compile, with a
+// module.ErrorList or a compiler.ErrorList whose positions in the test
+// file name it.
+func (r *Runner) RunFile(path string) ([]Result, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mods, err := (&module.Loader{}).Load(path, string(src))
+	if err != nil {
+		if errs, ok := err.(module.ErrorList); ok {
+			for _, e := range errs {
+				e.Pos = inFile(path, e.Pos)
+			}
+		}
+		return nil, err
+	}
+	if diags := module.Check(mods); len(diags) > 0 {
+		return nil, located(path, diags)
+	}
+	// compiled once without a test, so that a file without tests is
+	// still checked
+	if err := module.Compile(compiler.New(), mods); err != nil {
+		if errs, ok := err.(compiler.ErrorList); ok {
+			err = located(path, errs)
+		}
+		return nil, err
+	}
+
+	main := mods[len(mods)-1]
+	var results []Result
+	for _, fn := range Tests(main.Program) {
+		if r.Run != nil && !r.Run.MatchString(fn.Name.Value) {
+			continue
+		}
+		results = append(results, r.run(path, mods, fn))
+	}
+	return results, nil
+}
+
+// Tests returns the test functions of program.
+func Tests(program *ast.Program) []*ast.FunctionStatement {
+	var tests []*ast.FunctionStatement
+	for _, s := range program.Statements {
+		if e, ok := s.(*ast.ExportStatement); ok {
+			s = e.Statement
+		}
+		if fn, ok := s.(*ast.FunctionStatement); ok && fn.Name != nil && strings.HasPrefix(fn.Name.Value, "test_") {
+			tests = append(tests, fn)
+		}
+	}
+	return tests
+}
+
+// run runs the test fn in a program that ends with a call of it.
+func (r *Runner) run(path string, mods []*module.Module, fn *ast.FunctionStatement) Result {
+	res := Result{Name: fn.Name.Value, Pos: fn.Name.Token.Pos}
+	res.Pos.File = path
+	if len(fn.Parameters) > 0 {
+		res.Err = compiler.Diagnostic{Pos: res.Pos, Msg: fn.Name.Value + " must take no parameters"}
+		return res
+	}
+
+	// the call is placed at the test's name, where its errors belong
+	call := &ast.ExpressionStatement{Token: fn.Name.Token, Expression: &ast.CallExpression{
+		Token:    token.Token{Type: token.LPAREN, Literal: "(", Pos: fn.Name.Token.Pos},
+		Function: &ast.Identifier{Token: fn.Name.Token, Value: fn.Name.Value},
+	}}
+	main := *mods[len(mods)-1]
+	main.Program = &ast.Program{Statements: append(slices.Clip(main.Program.Statements), call)}
+	mods = append(slices.Clip(mods[:len(mods)-1]), &main)
+
+	comp := compiler.New()
+	if err := module.Compile(comp, mods); err != nil {
+		res.Err = err
+		if errs, ok := err.(compiler.ErrorList); ok {
+			res.Err = located(path, errs)
+		}
+		return res
+	}
+	bc := comp.Bytecode()
+	var machine *vm.VM
+	if r.NewVM != nil {
+		machine = r.NewVM(bc)
+	} else {
+		machine = vm.NewFromBytecode(bc, nil)
+	}
+	if r.Coverage != nil {
+		rec := cover.New(bc, path)
+		machine.SetHook(rec)
+		defer func() {
+			p := rec.Profile()
+			delete(p, path)
+			r.Coverage.Merge(p)
+		}()
+	}
+	var out strings.Builder
+	machine.SetOutput(&out)
+	if err := machine.Run(); err != nil {
+		var rt *vm.RuntimeError
+		if errors.As(err, &rt) {
+			rt.Err.Pos = inFile(path, rt.Err.Pos)
+		}
+		res.Err = err
+	}
+	res.Output = out.String()
+	return res
+}
+
+// inFile names the test file in a position in its main program.
+func inFile(path string, pos token.Position) token.Position {
+	if pos.File == "" && pos.Line > 0 {
+		pos.File = path
+	}
+	return pos
+}
+
+func located(path string, diags []compiler.Diagnostic) compiler.ErrorList {
+	errs := make(compiler.ErrorList, len(diags))
+	for i, d := range diags {
+		d.Pos, d.End = inFile(path, d.Pos), inFile(path, d.End)
+		errs[i] = d
+	}
+	return errs
+}
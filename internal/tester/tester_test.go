@@ -0,0 +1,167 @@
+package tester_test
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"mingo/internal/cover"
+	"mingo/internal/tester"
+)
+
+// write creates the files under a new directory and returns it.
+func write(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const suite = `import "testing" as t;
+import "lib/num.mg" as num;
+let runs = 0;
+fn test_pass() { runs = runs + 1; t.assert_eq(runs, 1); }
+fn test_isolated() { runs = runs + 1; t.assert_eq(runs, 1); }
+fn test_fail() { print("𝒳"); t.assert_eq(num.add(1, 2), 4); }
+fn test_lines() { t.assert_eq("a\nb\nc", "a\nc\nd"); }
+fn test_error() { t.assert_error(fn() { num.add(true, 1); }, "ZeroDivisionError"); }
+fn test_no_error() { t.assert_error(fn() { 1; }); }
+fn test_kind() { let e = t.assert_error(fn() { try { throw 1; } catch { } 1 / 0; }); print(e); }
+fn test_raises() { num.add(true, 1); }
+fn test_args(x) {}
+fn helper() { t.assert(false); }
+`
+
+func TestRunFile(t *testing.T) {
+	dir := write(t, map[string]string{
+		"a_test.mg":  suite,
+		"lib/num.mg": "export fn add(a, b) { a + b }\n",
+	})
+	path := filepath.Join(dir, "a_test.mg")
+	lib := filepath.Join(dir, "lib", "num.mg")
+	results, err := (&tester.Runner{}).RunFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		name   string
+		err    string
+		output string
+	}{
+		{"test_pass", "", ""},
+		{"test_isolated", "", ""},
+		{"test_fail", path + ":6:41: AssertionError: got 3, want 4", "𝒳\n"},
+		{"test_lines", path + ":7:30: AssertionError: strings differ (-want +got):\n  a\n+ b\n  c\n- d", ""},
+		{"test_error", path + ":8:33: AssertionError: want a ZeroDivisionError, got TypeError: unsupported types for binary op: BOOLEAN INTEGER", ""},
+		{"test_no_error", path + ":9:36: AssertionError: no error was raised", ""},
+		{"test_kind", "", "ZeroDivisionError: division by zero\n"},
+		{"test_raises", lib + ":1:25: TypeError: unsupported types for binary op: BOOLEAN INTEGER", ""},
+		{"test_args", path + ":12:4: test_args must take no parameters", ""},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("wrong number of results. want=%d got=%d: %+v", len(expected), len(results), results)
+	}
+	for i, want := range expected {
+		res := results[i]
+		got := ""
+		if res.Err != nil {
+			got = res.Err.Error()
+		}
+		if res.Name != want.name || got != want.err || res.Output != want.output {
+			t.Errorf("results[%d]: want=%s %q %q got=%s %q %q", i, want.name, want.err, want.output, res.Name, got, res.Output)
+		}
+	}
+
+	results, err = (&tester.Runner{Run: regexp.MustCompile("^test_(pass|raises)$")}).RunFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, res := range results {
+		names = append(names, res.Name)
+	}
+	if got := strings.Join(names, " "); got != "test_pass test_raises" {
+		t.Fatalf("wrong tests run with -run. got=%q", got)
+	}
+}
+
+func TestRunFileErrors(t *testing.T) {
+	dir := write(t, map[string]string{
+		"parse_test.mg":   "fn test_a() { let = 1; }",
+		"types_test.mg":   "let x: int = true; fn test_a() {}",
+		"compile_test.mg": "fn test_a() { y; }",
+	})
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"parse_test.mg", ":1:19: expected next token to be IDENT, got ASSIGN instead"},
+		{"types_test.mg", ":1:14: cannot use bool as int in let x"},
+		{"compile_test.mg", ":1:15: undefined variable y"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		_, err := (&tester.Runner{}).RunFile(path)
+		if err == nil || strings.Split(err.Error(), "\n")[0] != path+tt.expected {
+			t.Errorf("%s: wrong error. want=%q got=%v", tt.file, path+tt.expected, err)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := write(t, map[string]string{
+		"a_test.mg":              "",
+		"a.mg":                   "",
+		"sub/b_test.mg":          "",
+		".hidden/c_test.mg":      "",
+		"_skip/d_test.mg":        "",
+		"node_modules/e_test.mg": "",
+	})
+	files, err := tester.Find([]string{dir, filepath.Join(dir, "a.mg")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range files {
+		files[i], _ = filepath.Rel(dir, f)
+	}
+	if got := strings.Join(files, " "); got != "a_test.mg sub/b_test.mg a.mg" {
+		t.Fatalf("wrong files. got=%q", got)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	dir := write(t, map[string]string{
+		"a_test.mg":    "import \"lib/sign.mg\" as s;\nfn test_pos() { s.sign(2); }\nfn test_zero() { s.sign(0); }\n",
+		"lib/sign.mg":  "export fn sign(n) {\n  if (n > 0) {\n    1;\n  } else {\n    0;\n  }\n}\n",
+		"lib/other.mg": "export fn f() {}\n",
+	})
+	r := &tester.Runner{Coverage: cover.Profile{}}
+	if _, err := r.RunFile(filepath.Join(dir, "a_test.mg")); err != nil {
+		t.Fatal(err)
+	}
+	lib := filepath.Join(dir, "lib", "sign.mg")
+	if len(r.Coverage) != 1 || r.Coverage[lib] == nil {
+		t.Fatalf("want the coverage of %s alone, got %v", lib, slices.Collect(maps.Keys(r.Coverage)))
+	}
+	f := r.Coverage[lib]
+	// each test runs the top-level code that defines sign
+	if f.Lines[1] != 2 || f.Lines[3] != 1 || f.Lines[5] != 1 {
+		t.Errorf("wrong line counts: %v", f.Lines)
+	}
+	if len(f.Branches) != 1 || f.Branches[0].Arms != [2]int{1, 1} {
+		t.Errorf("wrong branches: %+v", f.Branches)
+	}
+}
@@ -1,5 +1,10 @@
 package token
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Type represents the type of a token.
 type Type string
 
@@ -12,9 +17,25 @@ type Token struct {
 
 // Position indicates the position of a token in the source code.
 type Position struct {
-	Line   int // 1-based
-	Column int // 1-based (rune index)
-	Offset int // 0-based byte offset
+	File   string // set for imported modules; empty for the main program
+	Line   int    // 1-based
+	Column int    // 1-based (rune index)
+	Offset int    // 0-based byte offset
+}
+
+// String formats p as file:line:column, or line:column without a file.
+func (p Position) String() string {
+	if p.File != "" {
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Comment is a // line comment. The lexer skips comments but keeps them,
+// so tools like the formatter can put them back.
+type Comment struct {
+	Text string // from "//" up to, not including, the newline
+	Pos  Position
 }
 
 const (
@@ -23,19 +44,26 @@ const (
 	EOF     Type = "EOF"
 
 	// Identifiers + literals
-	IDENT Type = "IDENT" // add, foobar, x, y, ...
-	INT   Type = "INT"   // 123
+	IDENT  Type = "IDENT"  // add, foobar, x, y, ...
+	INT    Type = "INT"    // 123
+	STRING Type = "STRING" // "a\tb"; the literal is the unescaped text
 
 	// Keywords
-	LET    Type = "LET"
-	TRUE   Type = "TRUE"
-	FALSE  Type = "FALSE"
-	IF     Type = "IF"
-	ELSE   Type = "ELSE"
-	WHILE  Type = "WHILE"
-	FN     Type = "FN"
-	RETURN Type = "RETURN"
-	PRINT  Type = "PRINT"
+	LET     Type = "LET"
+	TRUE    Type = "TRUE"
+	FALSE   Type = "FALSE"
+	IF      Type = "IF"
+	ELSE    Type = "ELSE"
+	WHILE   Type = "WHILE"
+	FN      Type = "FN"
+	RETURN  Type = "RETURN"
+	PRINT   Type = "PRINT"
+	THROW   Type = "THROW"
+	TRY     Type = "TRY"
+	CATCH   Type = "CATCH"
+	FINALLY Type = "FINALLY"
+	IMPORT  Type = "IMPORT"
+	EXPORT  Type = "EXPORT"
 
 	// Operators
 	ASSIGN Type = "ASSIGN" // =
@@ -55,22 +83,33 @@ const (
 	// Delimiters
 	COMMA     Type = "COMMA"
 	SEMICOLON Type = "SEMICOLON"
+	COLON     Type = "COLON" // :
+	ARROW     Type = "ARROW" // ->
+	DOT       Type = "DOT"   // .
 	LPAREN    Type = "LPAREN"
 	RPAREN    Type = "RPAREN"
 	LBRACE    Type = "LBRACE"
 	RBRACE    Type = "RBRACE"
+	LBRACKET  Type = "LBRACKET"
+	RBRACKET  Type = "RBRACKET"
 )
 
 var keywords = map[string]Type{
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"while":  WHILE,
-	"fn":     FN,
-	"return": RETURN,
-	"print":  PRINT,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"while":   WHILE,
+	"fn":      FN,
+	"return":  RETURN,
+	"print":   PRINT,
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"import":  IMPORT,
+	"export":  EXPORT,
 }
 
 // LookupIdent returns the token type of the identifier; if it's a keyword, returns the keyword type.
@@ -80,3 +119,8 @@ func LookupIdent(ident string) Type {
 	}
 	return IDENT
 }
+
+var quoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+
+// Quote returns s as a string literal, escaping what the lexer unescapes.
+func Quote(s string) string { return `"` + quoter.Replace(s) + `"` }
@@ -0,0 +1,148 @@
+// Package trace logs the instructions a VM runs, one JSON object a line,
+// with their operands and the operand stack before and after each one:
+//
+//	{"fn":"<main>","depth":1,"ip":0,"op":"OpConstant","operands":[0],"line":1,"column":9,"before":[],"after":[{"type":"INTEGER","value":"8"}]}
+//
+// Stack values have their type, so that the string "8" and the integer 8
+// differ, and are shown as print shows them.
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+
+	"mingo/internal/code"
+	"mingo/internal/object"
+	"mingo/internal/vm"
+)
+
+// Step is the record of one instruction.
+type Step struct {
+	Function string  `json:"fn"` // "<main>" for top-level code, "<fn>" for literals
+	Depth    int     `json:"depth"`
+	IP       int     `json:"ip"`
+	Op       string  `json:"op"`
+	Operands []int   `json:"operands"`
+	File     string  `json:"file,omitempty"`
+	Line     int     `json:"line"`
+	Column   int     `json:"column"`
+	Before   []Value `json:"before"`
+	After    []Value `json:"after"`
+	// Error is the error the instruction raised, if it ended the run.
+	Error string `json:"error,omitempty"`
+}
+
+// Value is a value on the operand stack.
+type Value struct {
+	Type  object.Type `json:"type"`
+	Value string      `json:"value"` // as print shows it
+}
+
+// Filter selects the instructions to log. The zero Filter selects all.
+type Filter struct {
+	// Functions, if not empty, names the functions whose instructions are
+	// logged, as Step names them.
+	Functions []string
+	// FirstLine and LastLine bound the lines whose instructions are logged,
+	// in any file; 0 leaves a side open.
+	FirstLine, LastLine int
+}
+
+func (f Filter) selects(s *Step) bool {
+	switch {
+	case len(f.Functions) > 0 && !slices.Contains(f.Functions, s.Function):
+		return false
+	case f.FirstLine > 0 && s.Line < f.FirstLine:
+		return false
+	case f.LastLine > 0 && s.Line > f.LastLine:
+		return false
+	}
+	return true
+}
+
+// Tracer is a vm.Hook that logs the instructions a program runs.
+type Tracer struct {
+	enc    *json.Encoder
+	name   string // of the main program, whose positions have no file
+	filter Filter
+	// the step of the instruction running, which is written once the
+	// stack after it is known
+	pending *Step
+}
+
+// New returns a tracer writing to w for a program whose main program is
+// the file name.
+func New(w io.Writer, name string, filter Filter) *Tracer {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &Tracer{enc: enc, name: name, filter: filter}
+}
+
+// Run runs the program on machine, logging its instructions. An error
+// writing the log stops the run and is returned.
+func (t *Tracer) Run(machine *vm.VM) error {
+	machine.SetHook(t)
+	defer machine.SetHook(nil)
+	err := machine.Run()
+	if t.pending != nil {
+		if rt, ok := err.(*vm.RuntimeError); ok {
+			t.pending.Error = rt.Err.Inspect()
+		}
+		if werr := t.flush(machine); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+func (t *Tracer) Instruction(machine *vm.VM, op code.Opcode) error {
+	if err := t.flush(machine); err != nil {
+		return err
+	}
+	fn, ip := machine.Location()
+	s := &Step{Function: fn.Name, Depth: machine.Depth(), IP: ip, Operands: []int{}, Before: stack(machine)}
+	switch {
+	case s.Depth == 1:
+		s.Function = "<main>"
+	case s.Function == "":
+		s.Function = "<fn>"
+	}
+	s.Op = "OpUnknown"
+	if def, err := code.Lookup(op); err == nil {
+		s.Op = def.Name
+		if operands, _ := code.ReadOperands(def, fn.Instructions[ip+1:]); operands != nil {
+			s.Operands = operands
+		}
+	}
+	if pos, ok := fn.Positions.Lookup(ip); ok {
+		s.File, s.Line, s.Column = pos.File, pos.Line, pos.Column
+		if s.File == "" {
+			s.File = t.name
+		}
+	}
+	if t.filter.selects(s) {
+		t.pending = s
+	}
+	return nil
+}
+
+// flush writes the pending step, with the stack as it is now.
+func (t *Tracer) flush(machine *vm.VM) error {
+	if t.pending == nil {
+		return nil
+	}
+	s := t.pending
+	t.pending = nil
+	s.After = stack(machine)
+	return t.enc.Encode(s)
+}
+
+func stack(machine *vm.VM) []Value {
+	values := machine.Stack()
+	shown := make([]Value, len(values))
+	for i, v := range values {
+		shown[i] = Value{Type: v.Type(), Value: v.Inspect()}
+	}
+	return shown
+}
@@ -0,0 +1,118 @@
+package trace_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"mingo/internal/compiler"
+	"mingo/internal/module"
+	"mingo/internal/object"
+	"mingo/internal/token"
+	"mingo/internal/trace"
+	"mingo/internal/vm"
+)
+
+const program = `fn twice(s) { s + s; }
+let x = twice("a");
+1 / 0;
+`
+
+// run traces program and returns its steps as
+// "fn@depth ip op operands line before -> after", with an error after
+// the step that raised it.
+func run(t *testing.T, filter trace.Filter) []string {
+	t.Helper()
+	mods, err := (&module.Loader{}).Load("main.mg", program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp := compiler.New()
+	if err := module.Compile(comp, mods); err != nil {
+		t.Fatal(err)
+	}
+	machine := vm.NewFromBytecode(comp.Bytecode(), nil)
+	machine.SetOutput(io.Discard)
+	var out strings.Builder
+	if err := trace.New(&out, "main.mg", filter).Run(machine); err == nil {
+		t.Fatal("the run did not fail")
+	}
+
+	var steps []string
+	for line := range strings.Lines(out.String()) {
+		var s trace.Step
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			t.Fatalf("%v: %s", err, line)
+		}
+		if s.File != "main.mg" {
+			t.Errorf("step in file %q", s.File)
+		}
+		step := fmt.Sprintf("%s@%d %d %s %v %d %v -> %v", s.Function, s.Depth, s.IP, s.Op, s.Operands, s.Line, show(s.Before), show(s.After))
+		if s.Error != "" {
+			step += " " + s.Error
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// show shows values as print does, but with strings quoted.
+func show(values []trace.Value) []string {
+	shown := make([]string, len(values))
+	for i, v := range values {
+		shown[i] = v.Value
+		if v.Type == object.STRING_OBJ {
+			shown[i] = token.Quote(v.Value)
+		}
+	}
+	return shown
+}
+
+func TestTrace(t *testing.T) {
+	fn := "compiled fn[params=1 locals=1]"
+	tests := []struct {
+		filter   trace.Filter
+		expected []string
+	}{
+		{trace.Filter{}, []string{
+			"<main>@1 0 OpConstant [0] 1 [] -> [" + fn + "]",
+			"<main>@1 3 OpSetGlobal [0] 1 [" + fn + "] -> []",
+			"<main>@1 6 OpGetGlobal [0] 2 [] -> [" + fn + "]",
+			`<main>@1 9 OpConstant [1] 2 [` + fn + `] -> [` + fn + ` "a"]`,
+			`<main>@1 12 OpCall [1] 2 [` + fn + ` "a"] -> [` + fn + ` "a"]`,
+			`twice@2 0 OpGetLocal [0] 1 [` + fn + ` "a"] -> [` + fn + ` "a" "a"]`,
+			`twice@2 2 OpGetLocal [0] 1 [` + fn + ` "a" "a"] -> [` + fn + ` "a" "a" "a"]`,
+			`twice@2 4 OpAdd [] 1 [` + fn + ` "a" "a" "a"] -> [` + fn + ` "a" "aa"]`,
+			`twice@2 5 OpReturnValue [] 1 [` + fn + ` "a" "aa"] -> ["aa"]`,
+			`<main>@1 14 OpSetGlobal [1] 2 ["aa"] -> []`,
+			"<main>@1 17 OpConstant [2] 3 [] -> [1]",
+			"<main>@1 20 OpConstant [3] 3 [1] -> [1 0]",
+			"<main>@1 23 OpDiv [] 3 [1 0] -> [] ZeroDivisionError: division by zero",
+		}},
+		{trace.Filter{Functions: []string{"twice"}}, []string{
+			`twice@2 0 OpGetLocal [0] 1 [` + fn + ` "a"] -> [` + fn + ` "a" "a"]`,
+			`twice@2 2 OpGetLocal [0] 1 [` + fn + ` "a" "a"] -> [` + fn + ` "a" "a" "a"]`,
+			`twice@2 4 OpAdd [] 1 [` + fn + ` "a" "a" "a"] -> [` + fn + ` "a" "aa"]`,
+			`twice@2 5 OpReturnValue [] 1 [` + fn + ` "a" "aa"] -> ["aa"]`,
+		}},
+		{trace.Filter{Functions: []string{"<main>"}, FirstLine: 2, LastLine: 2}, []string{
+			"<main>@1 6 OpGetGlobal [0] 2 [] -> [" + fn + "]",
+			`<main>@1 9 OpConstant [1] 2 [` + fn + `] -> [` + fn + ` "a"]`,
+			`<main>@1 12 OpCall [1] 2 [` + fn + ` "a"] -> [` + fn + ` "a"]`,
+			`<main>@1 14 OpSetGlobal [1] 2 ["aa"] -> []`,
+		}},
+		{trace.Filter{FirstLine: 3}, []string{
+			"<main>@1 17 OpConstant [2] 3 [] -> [1]",
+			"<main>@1 20 OpConstant [3] 3 [1] -> [1 0]",
+			"<main>@1 23 OpDiv [] 3 [1 0] -> [] ZeroDivisionError: division by zero",
+		}},
+	}
+	for _, tt := range tests {
+		got := run(t, tt.filter)
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%+v: wrong steps.\nwant=%q\ngot= %q", tt.filter, tt.expected, got)
+		}
+	}
+}
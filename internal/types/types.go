@@ -0,0 +1,510 @@
+// Package types checks a program against its optional type annotations:
+//
+//	let x: int = 5;
+//	fn add(a: int, b: int) -> int { a + b }
+//
+// Typing is gradual. Unannotated parameters, results and reassigned
+// variables have type any, which is compatible with every type, so the
+// checker only reports operations that would fail or misbehave whatever
+// the values. A let binding that is never reassigned takes the type of its
+// value. Members of imported modules are any too. The checker runs before
+// compiler.Compile and reports its findings as compiler diagnostics.
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"mingo/internal/ast"
+	"mingo/internal/compiler"
+	"mingo/internal/resolve"
+	"mingo/internal/token"
+)
+
+// Type is the static type of a value.
+type Type interface {
+	String() string
+}
+
+type basic string
+
+func (b basic) String() string { return string(b) }
+
+var (
+	Any    Type = basic("any")
+	Int    Type = basic("int")
+	Bool   Type = basic("bool")
+	String Type = basic("string")
+	Array  Type = basic("array") // of any elements
+	Map    Type = basic("map")   // from strings to any values
+)
+
+// Func is the type of a function.
+type Func struct {
+	Params []Type
+	Result Type
+}
+
+func (f *Func) String() string {
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = p.String()
+	}
+	s := "fn(" + strings.Join(params, ", ") + ")"
+	if f.Result != Any {
+		s += " -> " + f.Result.String()
+	}
+	return s
+}
+
+// Consistent reports whether a value of one type may stand where the other
+// is expected: any is consistent with everything, and function types are
+// consistent when their parameters and results are.
+func Consistent(a, b Type) bool {
+	if a == Any || b == Any {
+		return true
+	}
+	fa, ok := a.(*Func)
+	if !ok {
+		return a == b
+	}
+	fb, ok := b.(*Func)
+	if !ok || len(fa.Params) != len(fb.Params) {
+		return false
+	}
+	for i := range fa.Params {
+		if !Consistent(fa.Params[i], fb.Params[i]) {
+			return false
+		}
+	}
+	return Consistent(fa.Result, fb.Result)
+}
+
+// Check checks a program that parsed without errors and returns the type
+// errors in source order.
+func Check(program *ast.Program) []compiler.Diagnostic {
+	c := &checker{info: resolve.Program(program), types: map[*resolve.Symbol]Type{}}
+	for _, s := range program.Statements {
+		c.stmt(s)
+	}
+	return c.diags
+}
+
+type checker struct {
+	info  *resolve.Info
+	types map[*resolve.Symbol]Type
+	diags []compiler.Diagnostic
+
+	// results holds the declared result types of the enclosing functions,
+	// innermost last.
+	results []Type
+}
+
+func (c *checker) errorf(pos, end token.Position, format string, args ...any) {
+	c.diags = append(c.diags, compiler.Diagnostic{Pos: pos, End: end, Severity: compiler.SeverityError, Msg: fmt.Sprintf(format, args...)})
+}
+
+// annotation converts a type annotation; nil means any.
+func (c *checker) annotation(t ast.TypeExpr) Type {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		switch t.Name {
+		case "any":
+			return Any
+		case "int":
+			return Int
+		case "bool":
+			return Bool
+		case "string":
+			return String
+		case "array":
+			return Array
+		case "map":
+			return Map
+		}
+		c.errorf(t.Token.Pos, ast.End(t.Token), "unknown type %s", t.Name)
+	case *ast.FunctionType:
+		f := &Func{Result: c.annotation(t.Result)}
+		for _, p := range t.Params {
+			f.Params = append(f.Params, c.annotation(p))
+		}
+		return f
+	}
+	return Any
+}
+
+// define records the type of the symbol id defines.
+func (c *checker) define(id *ast.Identifier, t Type) {
+	if sym := c.info.SymbolOf(id); sym != nil {
+		c.types[sym] = t
+	}
+}
+
+// typeOf returns the type of the symbol id names; unknown names are any.
+func (c *checker) typeOf(id *ast.Identifier) Type {
+	if t, ok := c.types[c.info.SymbolOf(id)]; ok {
+		return t
+	}
+	return Any
+}
+
+func reassigned(sym *resolve.Symbol) bool {
+	return sym != nil && sym.Reads() < len(sym.Refs)
+}
+
+func (c *checker) stmt(s ast.Statement) {
+	switch n := s.(type) {
+	case *ast.LetStatement:
+		if n == nil {
+			return
+		}
+		t := c.expr(n.Value)
+		switch {
+		case n.Name.Type != nil:
+			want := c.annotation(n.Name.Type)
+			if !Consistent(t, want) {
+				c.errorf(ast.Start(n.Value), token.Position{}, "cannot use %s as %s in let %s", t, want, n.Name.Value)
+			}
+			t = want
+		case reassigned(c.info.SymbolOf(n.Name)):
+			t = Any
+		}
+		c.define(n.Name, t)
+	case *ast.AssignmentStatement:
+		if n == nil {
+			return
+		}
+		t := c.expr(n.Value)
+		// only bindings that are never reassigned have inferred types, so
+		// this is the declared type
+		if want := c.typeOf(n.Name); !Consistent(t, want) {
+			c.errorf(ast.Start(n.Value), token.Position{}, "cannot assign %s to %s of type %s", t, n.Name.Value, want)
+		}
+	case *ast.ReturnStatement:
+		if n == nil {
+			return
+		}
+		if n.ReturnValue == nil {
+			// a bare return returns null
+			if len(c.results) > 0 && c.results[len(c.results)-1] != Any {
+				c.errorf(n.Token.Pos, ast.End(n.Token), "missing return value in a function returning %s", c.results[len(c.results)-1])
+			}
+			return
+		}
+		c.checkResult(c.expr(n.ReturnValue), n.Token.Pos)
+	case *ast.ExpressionStatement:
+		if n != nil {
+			c.expr(n.Expression)
+		}
+	case *ast.PrintStatement:
+		if n != nil {
+			c.expr(n.Value)
+		}
+	case *ast.ThrowStatement:
+		if n != nil {
+			c.expr(n.Value)
+		}
+	case *ast.ExportStatement:
+		if n != nil {
+			c.stmt(n.Statement)
+		}
+	case *ast.BlockStatement:
+		c.block(n)
+	case *ast.WhileStatement:
+		if n == nil {
+			return
+		}
+		c.expr(n.Condition)
+		c.block(n.Body)
+	case *ast.FunctionStatement:
+		if n == nil || n.Name == nil {
+			return
+		}
+		// defined first, so the body can call itself
+		f := c.signature(n.Parameters, n.ReturnType)
+		c.define(n.Name, f)
+		c.function(f, n.Parameters, n.Body)
+	case *ast.TryStatement:
+		if n == nil {
+			return
+		}
+		c.block(n.Block)
+		if n.CatchParam != nil {
+			c.define(n.CatchParam, Any)
+		}
+		c.block(n.CatchBlock)
+		c.block(n.FinallyBlock)
+	}
+}
+
+// checkResult checks a value the innermost function returns.
+func (c *checker) checkResult(t Type, pos token.Position) {
+	if len(c.results) == 0 {
+		return
+	}
+	if want := c.results[len(c.results)-1]; !Consistent(t, want) {
+		c.errorf(pos, token.Position{}, "cannot return %s from a function returning %s", t, want)
+	}
+}
+
+// block checks a block and returns the type of its value: that of a
+// trailing expression statement, or any.
+func (c *checker) block(b *ast.BlockStatement) Type {
+	if b == nil {
+		return Any
+	}
+	t := Any
+	for i, s := range b.Statements {
+		if es, ok := s.(*ast.ExpressionStatement); ok && es != nil && i == len(b.Statements)-1 {
+			t = c.expr(es.Expression)
+		} else {
+			c.stmt(s)
+		}
+	}
+	return t
+}
+
+func (c *checker) signature(params []*ast.Identifier, result ast.TypeExpr) *Func {
+	f := &Func{Result: c.annotation(result)}
+	for _, p := range params {
+		t := Any
+		if p != nil && p.Type != nil {
+			t = c.annotation(p.Type)
+		}
+		f.Params = append(f.Params, t)
+	}
+	return f
+}
+
+func (c *checker) function(f *Func, params []*ast.Identifier, body *ast.BlockStatement) {
+	for i, p := range params {
+		if p != nil {
+			c.define(p, f.Params[i])
+		}
+	}
+	if body == nil {
+		return
+	}
+	c.results = append(c.results, f.Result)
+	for i, s := range body.Statements {
+		// a trailing expression statement is the function's result
+		if es, ok := s.(*ast.ExpressionStatement); ok && es != nil && i == len(body.Statements)-1 {
+			c.checkResult(c.expr(es.Expression), ast.Start(es.Expression))
+		} else {
+			c.stmt(s)
+		}
+	}
+	c.results = c.results[:len(c.results)-1]
+	if f.Result != Any && fallsOff(body) {
+		c.errorf(body.Rbrace, token.Position{}, "missing return at the end of a function returning %s", f.Result)
+	}
+}
+
+// fallsOff reports whether running b can reach its end without returning,
+// throwing or ending with the value of an expression, so that a function
+// with b as its body would return null.
+func fallsOff(b *ast.BlockStatement) bool {
+	if b == nil || len(b.Statements) == 0 {
+		return true
+	}
+	for _, s := range b.Statements {
+		if terminates(s) {
+			return false
+		}
+	}
+	es, ok := b.Statements[len(b.Statements)-1].(*ast.ExpressionStatement)
+	if !ok || es == nil {
+		return true
+	}
+	if n, ok := es.Expression.(*ast.IfExpression); ok && n != nil {
+		return n.Alternative == nil || fallsOff(n.Consequence) || fallsOff(n.Alternative)
+	}
+	return false
+}
+
+// terminates reports whether every way through s returns or throws.
+func terminates(s ast.Statement) bool {
+	switch n := s.(type) {
+	case *ast.ReturnStatement:
+		return n != nil
+	case *ast.ThrowStatement:
+		return n != nil
+	case *ast.BlockStatement:
+		return blockTerminates(n)
+	case *ast.ExpressionStatement:
+		if n == nil {
+			return false
+		}
+		if e, ok := n.Expression.(*ast.IfExpression); ok && e != nil {
+			return blockTerminates(e.Consequence) && blockTerminates(e.Alternative)
+		}
+	case *ast.WhileStatement:
+		// there is no break, so only a return or a throw leaves while (true)
+		if n == nil {
+			return false
+		}
+		b, ok := n.Condition.(*ast.Boolean)
+		return ok && b != nil && b.Value
+	case *ast.TryStatement:
+		if n == nil {
+			return false
+		}
+		// an error in the try block goes on past a missing catch block
+		return blockTerminates(n.FinallyBlock) ||
+			blockTerminates(n.Block) && (n.CatchBlock == nil || blockTerminates(n.CatchBlock))
+	}
+	return false
+}
+
+func blockTerminates(b *ast.BlockStatement) bool {
+	if b == nil {
+		return false
+	}
+	for _, s := range b.Statements {
+		if terminates(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) expr(e ast.Expression) Type {
+	switch n := e.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.Boolean:
+		return Bool
+	case *ast.StringLiteral:
+		return String
+	case *ast.ArrayLiteral:
+		if n == nil {
+			return Any
+		}
+		for _, e := range n.Elements {
+			c.expr(e)
+		}
+		return Array
+	case *ast.IndexExpression:
+		if n == nil {
+			return Any
+		}
+		left, index := c.expr(n.Left), c.expr(n.Index)
+		switch {
+		case left == Map:
+			if !Consistent(index, String) {
+				c.errorf(ast.Start(n.Index), token.Position{}, "map key must be string, got %s", index)
+			}
+		case !Consistent(left, Array):
+			c.errorf(n.Token.Pos, ast.End(n.Token), "cannot index %s", left)
+		case left == Array && !Consistent(index, Int):
+			c.errorf(ast.Start(n.Index), token.Position{}, "array index must be int, got %s", index)
+		}
+		return Any
+	case *ast.Identifier:
+		if n == nil {
+			return Any
+		}
+		return c.typeOf(n)
+	case *ast.PrefixExpression:
+		if n == nil {
+			return Any
+		}
+		t := c.expr(n.Right)
+		if n.Operator == "!" {
+			return Bool
+		}
+		if !Consistent(t, Int) {
+			c.errorf(n.Token.Pos, ast.End(n.Token), "operator %s not defined on %s", n.Operator, t)
+		}
+		return Int
+	case *ast.InfixExpression:
+		if n == nil {
+			return Any
+		}
+		return c.infix(n)
+	case *ast.IfExpression:
+		if n == nil {
+			return Any
+		}
+		c.expr(n.Condition)
+		then := c.block(n.Consequence)
+		if n.Alternative == nil {
+			return Any
+		}
+		if els := c.block(n.Alternative); then != els {
+			return Any
+		}
+		return then
+	case *ast.FunctionLiteral:
+		if n == nil {
+			return Any
+		}
+		f := c.signature(n.Parameters, n.ReturnType)
+		c.function(f, n.Parameters, n.Body)
+		return f
+	case *ast.CallExpression:
+		if n == nil {
+			return Any
+		}
+		return c.call(n)
+	}
+	return Any
+}
+
+func (c *checker) infix(n *ast.InfixExpression) Type {
+	l, r := c.expr(n.Left), c.expr(n.Right)
+	switch n.Operator {
+	case "==", "!=":
+		if !Consistent(l, r) {
+			c.errorf(n.Token.Pos, ast.End(n.Token), "mismatched types %s and %s for %s", l, r, n.Operator)
+		}
+		return Bool
+	case "<", ">", "<=", ">=":
+		c.intOperands(n, l, r)
+		return Bool
+	case "+":
+		// + also joins strings
+		if l == String || r == String {
+			if !Consistent(l, String) || !Consistent(r, String) {
+				c.errorf(n.Token.Pos, ast.End(n.Token), "mismatched types %s and %s for +", l, r)
+			}
+			return String
+		}
+		if l == Any && r == Any {
+			return Any
+		}
+	}
+	c.intOperands(n, l, r)
+	return Int
+}
+
+func (c *checker) intOperands(n *ast.InfixExpression, l, r Type) {
+	if !Consistent(l, Int) || !Consistent(r, Int) {
+		c.errorf(n.Token.Pos, ast.End(n.Token), "operator %s requires int operands, got %s and %s", n.Operator, l, r)
+	}
+}
+
+func (c *checker) call(n *ast.CallExpression) Type {
+	callee := c.expr(n.Function)
+	args := make([]Type, len(n.Arguments))
+	for i, a := range n.Arguments {
+		args[i] = c.expr(a)
+	}
+	f, ok := callee.(*Func)
+	if !ok {
+		if callee != Any {
+			c.errorf(ast.Start(n.Function), token.Position{}, "cannot call non-function of type %s", callee)
+		}
+		return Any
+	}
+	if len(args) != len(f.Params) {
+		c.errorf(n.Token.Pos, ast.End(n.Token), "wrong number of arguments: want=%d, got=%d", len(f.Params), len(args))
+		return f.Result
+	}
+	for i, t := range args {
+		if !Consistent(t, f.Params[i]) {
+			c.errorf(ast.Start(n.Arguments[i]), token.Position{}, "cannot use %s as %s in argument %d", t, f.Params[i], i+1)
+		}
+	}
+	return f.Result
+}
@@ -0,0 +1,100 @@
+package types_test
+
+import (
+	"strings"
+	"testing"
+
+	"mingo/internal/lexer"
+	"mingo/internal/parser"
+	"mingo/internal/types"
+)
+
+func check(t *testing.T, input string) []string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("%q: parse errors: %v", input, errs)
+	}
+	var got []string
+	for _, d := range types.Check(program) {
+		got = append(got, d.Error())
+	}
+	return got
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// unannotated code is checked only where types are certain
+		{"fn f(a, b) { a + b; } f(true, 1); let x = 1; x = true; print(x + 1);", nil},
+		{"let a = 1; let b = true; print(a + b); print(-b); print(!a);", []string{
+			"1:34: operator + requires int operands, got int and bool",
+			"1:46: operator - not defined on bool",
+		}},
+		{"let x: int = true; let y: bool = 1 < 2; let z: any = 1; z = false;", []string{
+			"1:14: cannot use bool as int in let x",
+		}},
+		{"let n: int = 1; n = n == 1; n = 2;", []string{"1:21: cannot assign bool to n of type int"}},
+		{"let x: number = 1;", []string{"1:8: unknown type number"}},
+		{"1 == true; let f = fn() {}; f == 1; f == f;", []string{
+			"1:3: mismatched types int and bool for ==",
+			"1:31: mismatched types fn() and int for ==",
+		}},
+		{"fn add(a: int, b: int) -> int { a + b } add(1, true); add(1); let s: bool = add(1, 2);", []string{
+			"1:48: cannot use bool as int in argument 2",
+			"1:58: wrong number of arguments: want=2, got=1",
+			"1:77: cannot use int as bool in let s",
+		}},
+		{"fn f(x: int) -> bool { if (x > 0) { return x; } x < 0 } fn g() -> int { true }", []string{
+			"1:37: cannot return int from a function returning bool",
+			"1:73: cannot return bool from a function returning int",
+		}},
+		// a function with a result type cannot return null
+		{"fn f() -> int { } fn g() -> int { return; } fn h(c) -> int { if (c) { return 1; } } fn k() { }", []string{
+			"1:17: missing return at the end of a function returning int",
+			"1:35: missing return value in a function returning int",
+			"1:83: missing return at the end of a function returning int",
+		}},
+		{"fn f(c) -> int { if (c) { return 1; } else { throw 2; } } fn g(c) -> int { while (c) { return 1; } }", []string{
+			"1:100: missing return at the end of a function returning int",
+		}},
+		{"fn f(a: int) -> int { while (true) { return 1; } } fn g() -> int { while (true) { } } print(f(1));", nil},
+		// the value of an if is known when both branches agree
+		{"fn f(c) -> int { if (c) { 1 } else { 2 } } fn g(c) -> int { if (c) { 1 } else { false } }", nil},
+		{"fn f(c) -> bool { if (c) { 1 } else { 2 } }", []string{"1:19: cannot return int from a function returning bool"}},
+		{"let one = 1; one(2); let g = fn(h: fn(int) -> int) { h(true) }; g(fn(x: bool) { x });", []string{
+			"1:14: cannot call non-function of type int",
+			"1:56: cannot use bool as int in argument 1",
+			"1:67: cannot use fn(bool) as fn(int) -> int in argument 1",
+		}},
+		{"fn fact(n: int) -> int { if (n < 2) { return 1; } n * fact(n - 1) } fact(true);", []string{
+			"1:74: cannot use bool as int in argument 1",
+		}},
+		{"try { throw 1; } catch (e) { e + 1; } let v: fn(int) = fn(a, b) {};", []string{
+			"1:56: cannot use fn(any, any) as fn(int) in let v",
+		}},
+		// + joins strings, and an unannotated operand may be either kind
+		{`let s: string = "a" + "𝒳"; fn f(a, b) { a + b } let t: string = f("a", "b"); print(s + 1);`, []string{
+			"1:86: mismatched types string and int for +",
+		}},
+		{`let xs: array = [1, "a"]; let x: int = xs[0]; let s = "ab"; s[0]; xs[true]; let b: bool = [];`, []string{
+			"1:62: cannot index string",
+			"1:70: array index must be int, got bool",
+			"1:92: cannot use array as bool in let b",
+		}},
+		{`fn f(m: map) -> int { m["a"] } fn g(m: map) { m[0] } f([1]);`, []string{
+			"1:49: map key must be string, got int",
+			"1:58: cannot use array as map in argument 1",
+		}},
+	}
+
+	for _, tt := range tests {
+		got := check(t, tt.input)
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: wrong diagnostics.\nwant=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
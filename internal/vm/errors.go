@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"fmt"
+
+	"mingo/internal/object"
+)
+
+// RuntimeError is returned by Run for an error no handler caught.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Err.Pos.Line == 0 {
+		return e.Err.Inspect()
+	}
+	return fmt.Sprintf("%s: %s", e.Err.Pos, e.Err.Inspect())
+}
+
+// errorf builds an error raised by the instruction currently executing.
+func (vm *VM) errorf(kind, format string, args ...any) *RuntimeError {
+	return vm.raise(&object.Error{Kind: kind, Message: fmt.Sprintf(format, args...)})
+}
+
+// raise attaches the position of the current instruction to e unless it
+// already carries one, as a rethrown error does.
+func (vm *VM) raise(e *object.Error) *RuntimeError {
+	if e.Pos.Line == 0 {
+		if pos, ok := vm.currentFrame().position(); ok {
+			e.Pos = pos
+		}
+	}
+	return &RuntimeError{Err: e}
+}
+
+// thrown converts the operand of a throw statement into an error value.
+func thrown(v Value) *object.Error {
+	if e, ok := v.obj.(*object.Error); ok {
+		return e
+	}
+	return &object.Error{Kind: object.ErrorKind, Message: v.Inspect(), Value: v.Object()}
+}
+
+// unwind looks for a handler covering the failing instruction, first in the
+// current frame and then in each caller at its call site. On success the
+// stack is reset to the handler's depth with the error pushed on top.
+// Frames at or below floor are not searched; the search fails with them on
+// top, or with the main program's frame, which is never popped.
+func (vm *VM) unwind(e *object.Error, floor int) bool {
+	for vm.framesIndex > floor {
+		frame := vm.currentFrame()
+		offset := frame.ip - 1
+		for _, h := range frame.fn.Handlers {
+			if offset < h.Start || offset >= h.End {
+				continue
+			}
+			vm.truncateStack(frame.basePointer + frame.fn.NumLocals + h.Depth)
+			frame.ip = h.Target
+			vm.stack[vm.sp] = FromObject(e)
+			vm.sp++
+			return true
+		}
+		if vm.framesIndex == 1 {
+			return false
+		}
+		vm.popFrame()
+		vm.truncateStack(frame.basePointer - 1)
+	}
+	return false
+}
+
+func (vm *VM) truncateStack(sp int) {
+	for i := sp; i < vm.sp; i++ {
+		vm.stack[i] = Null
+	}
+	vm.sp = sp
+}
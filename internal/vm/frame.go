@@ -0,0 +1,25 @@
+package vm
+
+import (
+	"mingo/internal/code"
+	"mingo/internal/object"
+	"mingo/internal/token"
+)
+
+// Frame is the activation record of a function call.
+type Frame struct {
+	fn          *object.CompiledFunction
+	ip          int
+	basePointer int // stack index of the first local
+}
+
+func NewFrame(fn *object.CompiledFunction, basePointer int) Frame {
+	return Frame{fn: fn, ip: 0, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions { return f.fn.Instructions }
+
+// position reports the source position of the instruction last executed.
+func (f *Frame) position() (token.Position, bool) {
+	return f.fn.Positions.Lookup(f.ip - 1)
+}
@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"mingo/internal/code"
+	"mingo/internal/object"
+	"mingo/internal/token"
+)
+
+// Hook observes execution. While attached with SetHook it is called before
+// every instruction; a non-nil error stops Run and is returned unchanged,
+// bypassing try handlers.
+type Hook interface {
+	Instruction(vm *VM, op code.Opcode) error
+}
+
+// CallHook is a Hook that is also told when calls begin and end: Enter
+// runs once the frame of a call of fn is pushed, and Leave once the
+// innermost frame is popped, by a return or by an error unwinding past it.
+type CallHook interface {
+	Hook
+	Enter(vm *VM, fn *object.CompiledFunction)
+	Leave(vm *VM)
+}
+
+// SetHook attaches h, or detaches the current hook when h is nil.
+func (vm *VM) SetHook(h Hook) {
+	vm.hook = h
+	vm.calls, _ = h.(CallHook)
+}
+
+// Variable is a named slot, as shown by a debugger.
+type Variable struct {
+	Name  string
+	Value Value
+}
+
+// StackFrame describes one active call.
+type StackFrame struct {
+	Function string // "<main>" for top-level code, "<fn>" for literals
+	Pos      token.Position
+	Locals   []Variable
+}
+
+// Depth reports the number of active frames, 1 while in top-level code.
+func (vm *VM) Depth() int { return vm.framesIndex }
+
+// Position reports the source position of the next instruction to execute.
+func (vm *VM) Position() (token.Position, bool) {
+	f := vm.currentFrame()
+	return f.fn.Positions.Lookup(f.ip)
+}
+
+// Location reports the function whose instruction runs next, top-level
+// code being a function of its own, and the instruction's offset in it.
+func (vm *VM) Location() (*object.CompiledFunction, int) {
+	f := vm.currentFrame()
+	return f.fn, f.ip
+}
+
+// Stack returns the operand stack, bottom first: the locals of each frame
+// followed by the values it has pushed. It is only valid until the VM runs
+// on.
+func (vm *VM) Stack() []Value { return vm.stack[:vm.sp] }
+
+// CallStack returns the active frames, innermost first.
+func (vm *VM) CallStack() []StackFrame {
+	frames := make([]StackFrame, 0, vm.framesIndex)
+	for i := vm.framesIndex - 1; i >= 0; i-- {
+		f := &vm.frames[i]
+		sf := StackFrame{Function: functionName(f, i)}
+		// the innermost frame sits on its next instruction, callers just
+		// past their call instruction
+		if i == vm.framesIndex-1 {
+			sf.Pos, _ = f.fn.Positions.Lookup(f.ip)
+		} else {
+			sf.Pos, _ = f.position()
+		}
+		for slot, name := range f.fn.LocalNames {
+			if name != "" {
+				sf.Locals = append(sf.Locals, Variable{Name: name, Value: vm.stack[f.basePointer+slot]})
+			}
+		}
+		frames = append(frames, sf)
+	}
+	return frames
+}
+
+// Global returns the value in global slot index.
+func (vm *VM) Global(index int) Value { return vm.globals[index] }
+
+func functionName(f *Frame, index int) string {
+	switch {
+	case index == 0:
+		return "<main>"
+	case f.fn.Name == "":
+		return "<fn>"
+	default:
+		return f.fn.Name
+	}
+}
@@ -0,0 +1,141 @@
+package vm
+
+import (
+	"strconv"
+
+	"mingo/internal/object"
+)
+
+// Kind identifies what a Value holds.
+type Kind uint8
+
+const (
+	NullKind Kind = iota
+	BoolKind
+	IntKind
+	ObjectKind
+)
+
+// Value is one slot of the VM stack or globals. Integers, booleans and null
+// are stored inline so arithmetic never allocates; every other object is
+// carried as a pointer. The zero Value is null.
+type Value struct {
+	kind Kind
+	n    int64 // integer value, or 0/1 for booleans
+	obj  object.Object
+}
+
+var (
+	Null  = Value{}
+	True  = Value{kind: BoolKind, n: 1}
+	False = Value{kind: BoolKind, n: 0}
+)
+
+func IntValue(i int64) Value { return Value{kind: IntKind, n: i} }
+
+func BoolValue(b bool) Value {
+	if b {
+		return True
+	}
+	return False
+}
+
+// FromObject converts an object into a Value, unboxing integers, booleans
+// and null. A nil object becomes null.
+func FromObject(o object.Object) Value {
+	switch o := o.(type) {
+	case nil:
+		return Null
+	case *object.Integer:
+		return IntValue(o.Value)
+	case *object.Boolean:
+		return BoolValue(o.Value)
+	case *object.Null:
+		return Null
+	default:
+		return Value{kind: ObjectKind, obj: o}
+	}
+}
+
+// Object boxes the value back into an object.Object.
+func (v Value) Object() object.Object {
+	switch v.kind {
+	case IntKind:
+		return object.NewInteger(v.n)
+	case BoolKind:
+		return object.NativeBool(v.n != 0)
+	case ObjectKind:
+		return v.obj
+	default:
+		return object.NULL
+	}
+}
+
+func (v Value) Kind() Kind { return v.kind }
+
+// Int returns the integer held by v; only meaningful when Kind is IntKind.
+func (v Value) Int() int64 { return v.n }
+
+// Bool returns the boolean held by v; only meaningful when Kind is BoolKind.
+func (v Value) Bool() bool { return v.n != 0 }
+
+// Type reports the object type the value would have once boxed.
+func (v Value) Type() object.Type {
+	switch v.kind {
+	case IntKind:
+		return object.INTEGER_OBJ
+	case BoolKind:
+		return object.BOOLEAN_OBJ
+	case ObjectKind:
+		return v.obj.Type()
+	default:
+		return object.NULL_OBJ
+	}
+}
+
+func (v Value) Inspect() string {
+	switch v.kind {
+	case IntKind:
+		return strconv.FormatInt(v.n, 10)
+	case BoolKind:
+		if v.n != 0 {
+			return "true"
+		}
+		return "false"
+	case ObjectKind:
+		return v.obj.Inspect()
+	default:
+		return "null"
+	}
+}
+
+func (v Value) IsTruthy() bool {
+	switch v.kind {
+	case BoolKind:
+		return v.n != 0
+	case NullKind:
+		return false
+	default:
+		return true
+	}
+}
+
+// Equal reports whether two values are equal: inline values by kind and
+// payload, strings by content and other heap objects by identity.
+func (v Value) Equal(o Value) bool {
+	if v.kind != o.kind {
+		return false
+	}
+	switch v.kind {
+	case NullKind:
+		return true
+	case ObjectKind:
+		if s, ok := v.obj.(*object.String); ok {
+			t, ok := o.obj.(*object.String)
+			return ok && s.Value == t.Value
+		}
+		return v.obj == o.obj
+	default:
+		return v.n == o.n
+	}
+}
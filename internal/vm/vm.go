@@ -1,76 +1,179 @@
 package vm
 
 import (
-	"errors"
 	"fmt"
+	"io"
+	"os"
 
 	"mingo/internal/code"
+	"mingo/internal/compiler"
 	"mingo/internal/object"
+	"mingo/internal/stdlib"
+	"mingo/internal/token"
 )
 
 type VM struct {
-	constants []object.Object
+	constants []Value
 
-	globals []object.Object
+	globals []Value
 
-	stack []object.Object
+	stack []Value
 	sp    int // Always points to the next free slot on the stack
 
-	ip           int
-	instructions code.Instructions
+	frames      []Frame
+	framesIndex int
+
+	out      io.Writer
+	hook     Hook
+	calls    CallHook // hook, if it is one
+	builtins []object.Object
+
+	// a function a builtin calls runs until a return brings framesIndex
+	// down to stop
+	stop  int
+	abort error // a host error met in such a call, ending the run
 }
 
 const (
 	StackSize   = 2048
 	GlobalsSize = 65536
+	MaxFrames   = 1024
 )
 
+// NewGlobals allocates a globals store that can be shared between VMs, as
+// the VM REPL does to keep state across inputs.
+func NewGlobals() []Value { return make([]Value, GlobalsSize) }
+
 func New(instructions code.Instructions, constants []object.Object) *VM {
 	return NewWithGlobals(instructions, constants, nil)
 }
 
-func NewWithGlobals(instructions code.Instructions, constants []object.Object, globals []object.Object) *VM {
+func NewWithGlobals(instructions code.Instructions, constants []object.Object, globals []Value) *VM {
+	return NewFromBytecode(&compiler.Bytecode{Instructions: instructions, Constants: constants}, globals)
+}
+
+// NewFromBytecode creates a VM for a compiled program, keeping its position
+// and exception tables. A nil globals store allocates a fresh one.
+func NewFromBytecode(bc *compiler.Bytecode, globals []Value) *VM {
 	if globals == nil {
-		globals = make([]object.Object, GlobalsSize)
+		globals = NewGlobals()
 	}
+	consts := make([]Value, len(bc.Constants))
+	for i, c := range bc.Constants {
+		consts[i] = FromObject(c)
+	}
+	mainFn := &object.CompiledFunction{
+		Instructions: bc.Instructions,
+		Positions:    bc.Positions,
+		Handlers:     bc.Handlers,
+	}
+	frames := make([]Frame, MaxFrames)
+	frames[0] = NewFrame(mainFn, 0)
 	return &VM{
-		constants:    constants,
-		globals:      globals,
-		stack:        make([]object.Object, StackSize),
-		sp:           0,
-		ip:           0,
-		instructions: instructions,
+		constants:   consts,
+		globals:     globals,
+		stack:       make([]Value, StackSize),
+		sp:          0,
+		frames:      frames,
+		framesIndex: 1,
+		out:         os.Stdout,
+		builtins:    stdlib.Builtins,
 	}
 }
 
-func (vm *VM) push(o object.Object) error {
+// SetOutput redirects the output of print statements (stdout by default).
+func (vm *VM) SetOutput(w io.Writer) { vm.out = w }
+
+// SetCapabilities grants the program's io functions access to files, which
+// they are denied by default.
+func (vm *VM) SetCapabilities(caps stdlib.Capabilities) { vm.builtins = stdlib.Bind(caps) }
+
+func (vm *VM) currentFrame() *Frame { return &vm.frames[vm.framesIndex-1] }
+
+func (vm *VM) pushFrame(f Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return vm.errorf(object.StackOverflowKind, "call stack overflow")
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	if vm.calls != nil {
+		vm.calls.Enter(vm, f.fn)
+	}
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	if vm.calls != nil {
+		vm.calls.Leave(vm)
+	}
+	return &vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(v Value) error {
 	if vm.sp >= StackSize {
-		return errors.New("stack overflow")
+		return vm.errorf(object.StackOverflowKind, "stack overflow")
 	}
-	vm.stack[vm.sp] = o
+	vm.stack[vm.sp] = v
 	vm.sp++
 	return nil
 }
 
-func (vm *VM) pop() object.Object {
+// pop only clears the pointer of the slot it frees, for the garbage
+// collector; the rest of the slot is overwritten by the next push.
+func (vm *VM) pop() Value {
 	if vm.sp == 0 {
-		return &object.Null{}
+		return Null
 	}
 	vm.sp--
-	o := vm.stack[vm.sp]
-	vm.stack[vm.sp] = nil
-	return o
+	v := vm.stack[vm.sp]
+	if v.obj != nil {
+		vm.stack[vm.sp].obj = nil
+	}
+	return v
 }
 
+// Run executes the program. Errors raised along the way unwind to the
+// nearest try handler; one that escapes every frame is returned as a
+// *RuntimeError.
 func (vm *VM) Run() error {
-	for vm.ip < len(vm.instructions) {
-		op := code.Opcode(vm.instructions[vm.ip])
-		vm.ip++
+	for {
+		err := vm.run()
+		if err == nil {
+			return nil
+		}
+		rt, ok := err.(*RuntimeError)
+		if !ok {
+			// host errors, such as a hook aborting the run
+			return err
+		}
+		if !vm.unwind(rt.Err, 0) {
+			return rt
+		}
+	}
+}
+
+// run is the dispatch loop; it stops at the end of the program or at the
+// first error.
+func (vm *VM) run() error {
+	for {
+		frame := vm.currentFrame()
+		ins := frame.Instructions()
+		if frame.ip >= len(ins) {
+			return nil
+		}
+		op := code.Opcode(ins[frame.ip])
+		if vm.hook != nil {
+			if err := vm.hook.Instruction(vm, op); err != nil {
+				return err
+			}
+		}
+		frame.ip++
 
 		switch op {
 		case code.OpConstant:
-			idx := int(vm.instructions[vm.ip])<<8 | int(vm.instructions[vm.ip+1])
-			vm.ip += 2
+			idx := int(ins[frame.ip])<<8 | int(ins[frame.ip+1])
+			frame.ip += 2
 			if err := vm.push(vm.constants[idx]); err != nil {
 				return err
 			}
@@ -79,15 +182,15 @@ func (vm *VM) Run() error {
 				return err
 			}
 		case code.OpTrue:
-			if err := vm.push(&object.Boolean{Value: true}); err != nil {
+			if err := vm.push(True); err != nil {
 				return err
 			}
 		case code.OpFalse:
-			if err := vm.push(&object.Boolean{Value: false}); err != nil {
+			if err := vm.push(False); err != nil {
 				return err
 			}
 		case code.OpNull:
-			if err := vm.push(&object.Null{}); err != nil {
+			if err := vm.push(Null); err != nil {
 				return err
 			}
 		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterEqual, code.OpLessThan, code.OpLessEqual:
@@ -95,187 +198,352 @@ func (vm *VM) Run() error {
 				return err
 			}
 		case code.OpBang:
-			if err := vm.executeBang(); err != nil {
+			if err := vm.push(BoolValue(!vm.pop().IsTruthy())); err != nil {
 				return err
 			}
 		case code.OpMinus:
 			right := vm.pop()
-			i, ok := right.(*object.Integer)
-			if !ok {
-				return fmt.Errorf("unsupported negation operand %T", right)
+			if right.kind != IntKind {
+				return vm.errorf(object.TypeErrorKind, "unsupported negation operand %s", right.Type())
 			}
-			if err := vm.push(&object.Integer{Value: -i.Value}); err != nil {
+			if err := vm.push(IntValue(-right.n)); err != nil {
 				return err
 			}
 		case code.OpPop:
 			vm.pop()
 		case code.OpJump:
-			pos := int(vm.instructions[vm.ip])<<8 | int(vm.instructions[vm.ip+1])
-			vm.ip = pos
+			pos := int(ins[frame.ip])<<8 | int(ins[frame.ip+1])
+			frame.ip = pos
 		case code.OpJumpNotTruthy:
-			pos := int(vm.instructions[vm.ip])<<8 | int(vm.instructions[vm.ip+1])
-			vm.ip += 2
+			pos := int(ins[frame.ip])<<8 | int(ins[frame.ip+1])
+			frame.ip += 2
 			condition := vm.pop()
-			if !isTruthy(condition) {
-				vm.ip = pos
+			if !condition.IsTruthy() {
+				frame.ip = pos
 			}
 		case code.OpSetGlobal:
-			idx := int(vm.instructions[vm.ip])<<8 | int(vm.instructions[vm.ip+1])
-			vm.ip += 2
+			idx := int(ins[frame.ip])<<8 | int(ins[frame.ip+1])
+			frame.ip += 2
 			vm.globals[idx] = vm.pop()
 		case code.OpGetGlobal:
-			idx := int(vm.instructions[vm.ip])<<8 | int(vm.instructions[vm.ip+1])
-			vm.ip += 2
+			idx := int(ins[frame.ip])<<8 | int(ins[frame.ip+1])
+			frame.ip += 2
 			if err := vm.push(vm.globals[idx]); err != nil {
 				return err
 			}
-		case code.OpCall:
-			argc := int(vm.instructions[vm.ip])
-			vm.ip++
-			fnObj := vm.stack[vm.sp-argc-1]
-			fn, ok := fnObj.(*object.CompiledFunction)
-			if !ok {
-				return fmt.Errorf("calling non-function: %T", fnObj)
+		case code.OpSetLocal:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			vm.stack[frame.basePointer+idx] = vm.pop()
+		case code.OpGetBuiltin:
+			idx := int(ins[frame.ip])<<8 | int(ins[frame.ip+1])
+			frame.ip += 2
+			if err := vm.push(FromObject(vm.builtins[idx])); err != nil {
+				return err
 			}
-			// naive call: save current state on a small manual frame
-			retIp := vm.ip
-			retBase := vm.sp - argc - 1
-
-			// new frame: execute function instructions
-			savedIns := vm.instructions
-
-			vm.instructions = fn.Instructions
-			vm.ip = 0
-
-			// locals are not fully supported; we just keep stack as is
-			if err := vm.Run(); err != nil {
+		case code.OpArray:
+			n := int(ins[frame.ip])<<8 | int(ins[frame.ip+1])
+			frame.ip += 2
+			elems := make([]object.Object, n)
+			for i := range elems {
+				elems[i] = vm.stack[vm.sp-n+i].Object()
+			}
+			vm.truncateStack(vm.sp - n)
+			if err := vm.push(FromObject(&object.Array{Elements: elems})); err != nil {
 				return err
 			}
-
-			// function completed: top of stack has return value (or null)
+		case code.OpIndex:
+			if err := vm.executeIndex(); err != nil {
+				return err
+			}
+		case code.OpGetLocal:
+			idx := int(ins[frame.ip])
+			frame.ip++
+			if err := vm.push(vm.stack[frame.basePointer+idx]); err != nil {
+				return err
+			}
+		case code.OpCall:
+			argc := int(ins[frame.ip])
+			frame.ip++
+			if err := vm.callFunction(argc); err != nil {
+				return err
+			}
+		case code.OpReturnValue:
 			retVal := vm.pop()
-
-			// restore
-			vm.instructions = savedIns
-			vm.ip = retIp
-
-			// remove function and args, then push return value
-			vm.sp = retBase
+			if vm.framesIndex == 1 {
+				// return at top level ends the program
+				return nil
+			}
+			f := vm.popFrame()
+			vm.sp = f.basePointer - 1
 			if err := vm.push(retVal); err != nil {
 				return err
 			}
-		case code.OpReturnValue:
-			return nil
+			if vm.framesIndex == vm.stop {
+				return nil
+			}
 		case code.OpReturn:
-			if err := vm.push(&object.Null{}); err != nil {
+			if vm.framesIndex == 1 {
+				return nil
+			}
+			f := vm.popFrame()
+			vm.sp = f.basePointer - 1
+			if err := vm.push(Null); err != nil {
 				return err
 			}
-			return nil
+			if vm.framesIndex == vm.stop {
+				return nil
+			}
 		case code.OpPrint:
 			v := vm.pop()
-			fmt.Println(v.Inspect())
+			fmt.Fprintln(vm.out, v.Inspect())
+		case code.OpThrow:
+			return vm.raise(thrown(vm.pop()))
 		default:
-			return fmt.Errorf("unsupported opcode: %d", op)
+			return vm.errorf(object.ErrorKind, "unsupported opcode: %d", op)
 		}
 	}
+}
+
+func (vm *VM) callFunction(argc int) error {
+	callee := vm.stack[vm.sp-argc-1]
+	if b, ok := callee.obj.(*object.Builtin); ok {
+		return vm.callBuiltin(b, argc)
+	}
+	fn, ok := callee.obj.(*object.CompiledFunction)
+	if !ok {
+		return vm.errorf(object.TypeErrorKind, "calling non-function: %s", callee.Type())
+	}
+	if argc != fn.NumParameters {
+		return vm.errorf(object.ArgumentErrorKind, "wrong number of arguments: want=%d, got=%d", fn.NumParameters, argc)
+	}
+	frame := NewFrame(fn, vm.sp-argc)
+	// reserve the local slots beyond the arguments
+	top := frame.basePointer + fn.NumLocals
+	if top >= StackSize {
+		return vm.errorf(object.StackOverflowKind, "stack overflow")
+	}
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+	for i := vm.sp; i < top; i++ {
+		vm.stack[i] = Null
+	}
+	vm.sp = top
 	return nil
 }
 
+// callBuiltin runs a builtin in place of the call, leaving its result
+// where the callee was. Its errors are raised at the call.
+func (vm *VM) callBuiltin(b *object.Builtin, argc int) error {
+	if b.Arity >= 0 && argc != b.Arity {
+		return vm.errorf(object.ArgumentErrorKind, "wrong number of arguments to %s: want=%d, got=%d", b.Name, b.Arity, argc)
+	}
+	args := make([]object.Object, argc)
+	for i := range args {
+		args[i] = vm.stack[vm.sp-argc+i].Object()
+	}
+	var result object.Object
+	var e *object.Error
+	if b.Call != nil {
+		result, e = b.Call(vm.call, args...)
+	} else {
+		result, e = b.Fn(args...)
+	}
+	if vm.abort != nil {
+		return vm.abort
+	}
+	if e != nil {
+		return vm.raise(e)
+	}
+	vm.truncateStack(vm.sp - argc - 1)
+	return vm.push(FromObject(result))
+}
+
+// call is the Caller builtins get: it runs fn to its return above the
+// builtin's frame, and an error fn does not catch unwinds no further.
+func (vm *VM) call(fn object.Object, args ...object.Object) (object.Object, *object.Error) {
+	if vm.abort != nil {
+		return nil, &object.Error{Kind: object.ErrorKind, Message: "run aborted"}
+	}
+	base, floor, stop := vm.sp, vm.framesIndex, vm.stop
+	vm.stop = floor
+	defer func() { vm.stop = stop }()
+
+	var err error
+	for _, v := range append([]object.Object{fn}, args...) {
+		if err = vm.push(FromObject(v)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = vm.callFunction(len(args))
+	}
+	for {
+		if err != nil {
+			rt, ok := err.(*RuntimeError)
+			if !ok {
+				vm.abort = err
+				return nil, &object.Error{Kind: object.ErrorKind, Message: "run aborted"}
+			}
+			if !vm.unwind(rt.Err, floor) {
+				vm.truncateStack(base)
+				return nil, rt.Err
+			}
+		} else if vm.framesIndex == floor {
+			break
+		}
+		err = vm.run()
+	}
+	result := vm.pop()
+	vm.truncateStack(base)
+	return result.Object(), nil
+}
+
 func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	// integers are combined in the left operand's slot
+	if vm.sp >= 2 {
+		l, r := &vm.stack[vm.sp-2], &vm.stack[vm.sp-1]
+		if l.kind == IntKind && r.kind == IntKind && (op != code.OpDiv || r.n != 0) {
+			switch op {
+			case code.OpAdd:
+				l.n += r.n
+			case code.OpSub:
+				l.n -= r.n
+			case code.OpMul:
+				l.n *= r.n
+			case code.OpDiv:
+				l.n /= r.n
+			}
+			vm.sp--
+			return nil
+		}
+	}
+
 	right := vm.pop()
 	left := vm.pop()
 
-	li, lok := left.(*object.Integer)
-	ri, rok := right.(*object.Integer)
-
-	if !lok || !rok {
-		return fmt.Errorf("unsupported types for binary op: %T %T", left, right)
+	if op == code.OpAdd {
+		l, lok := left.obj.(*object.String)
+		r, rok := right.obj.(*object.String)
+		if lok && rok {
+			return vm.push(FromObject(&object.String{Value: l.Value + r.Value}))
+		}
+	}
+	if left.kind != IntKind || right.kind != IntKind {
+		return vm.errorf(object.TypeErrorKind, "unsupported types for binary op: %s %s", left.Type(), right.Type())
 	}
 
 	var result int64
 	switch op {
 	case code.OpAdd:
-		result = li.Value + ri.Value
+		result = left.n + right.n
 	case code.OpSub:
-		result = li.Value - ri.Value
+		result = left.n - right.n
 	case code.OpMul:
-		result = li.Value * ri.Value
+		result = left.n * right.n
 	case code.OpDiv:
-		if ri.Value == 0 {
-			return errors.New("division by zero")
+		if right.n == 0 {
+			return vm.errorf(object.ZeroDivisionKind, "division by zero")
 		}
-		result = li.Value / ri.Value
+		result = left.n / right.n
 	}
 
-	return vm.push(&object.Integer{Value: result})
+	return vm.push(IntValue(result))
+}
+
+func (vm *VM) executeIndex() error {
+	index := vm.pop()
+	left := vm.pop()
+
+	if m, ok := left.obj.(*object.Map); ok {
+		key, ok := index.obj.(*object.String)
+		if !ok {
+			return vm.errorf(object.TypeErrorKind, "map key must be STRING, got %s", index.Type())
+		}
+		v, ok := m.Pairs[key.Value]
+		if !ok {
+			return vm.errorf(object.KeyErrorKind, "key %s not in map", token.Quote(key.Value))
+		}
+		return vm.push(FromObject(v))
+	}
+	arr, ok := left.obj.(*object.Array)
+	if !ok {
+		return vm.errorf(object.TypeErrorKind, "index operator not supported: %s", left.Type())
+	}
+	if index.kind != IntKind {
+		return vm.errorf(object.TypeErrorKind, "array index must be INTEGER, got %s", index.Type())
+	}
+	if index.n < 0 || index.n >= int64(len(arr.Elements)) {
+		return vm.errorf(object.IndexErrorKind, "index %d out of range for array of length %d", index.n, len(arr.Elements))
+	}
+	return vm.push(FromObject(arr.Elements[index.n]))
 }
 
 func (vm *VM) executeComparison(op code.Opcode) error {
+	// integers are compared in the left operand's slot
+	if vm.sp >= 2 {
+		l, r := &vm.stack[vm.sp-2], &vm.stack[vm.sp-1]
+		if l.kind == IntKind && r.kind == IntKind {
+			var result bool
+			switch op {
+			case code.OpEqual:
+				result = l.n == r.n
+			case code.OpNotEqual:
+				result = l.n != r.n
+			case code.OpGreaterThan:
+				result = l.n > r.n
+			case code.OpGreaterEqual:
+				result = l.n >= r.n
+			case code.OpLessThan:
+				result = l.n < r.n
+			case code.OpLessEqual:
+				result = l.n <= r.n
+			}
+			*l = BoolValue(result)
+			vm.sp--
+			return nil
+		}
+	}
+
 	right := vm.pop()
 	left := vm.pop()
 
-	li, lok := left.(*object.Integer)
-	ri, rok := right.(*object.Integer)
+	if op == code.OpEqual {
+		return vm.push(BoolValue(left.Equal(right)))
+	}
+	if op == code.OpNotEqual {
+		return vm.push(BoolValue(!left.Equal(right)))
+	}
+
+	if left.kind != IntKind || right.kind != IntKind {
+		return vm.errorf(object.TypeErrorKind, "%s requires integers, got %s %s", comparisonSymbol(op), left.Type(), right.Type())
+	}
 
 	var result bool
-
 	switch op {
-	case code.OpEqual:
-		if lok && rok {
-			result = li.Value == ri.Value
-		} else {
-			result = left.Inspect() == right.Inspect()
-		}
-	case code.OpNotEqual:
-		if lok && rok {
-			result = li.Value != ri.Value
-		} else {
-			result = left.Inspect() != right.Inspect()
-		}
 	case code.OpGreaterThan:
-		if !lok || !rok {
-			return fmt.Errorf("> requires integers, got %T %T", left, right)
-		}
-		result = li.Value > ri.Value
+		result = left.n > right.n
 	case code.OpGreaterEqual:
-		if !lok || !rok {
-			return fmt.Errorf(">= requires integers, got %T %T", left, right)
-		}
-		result = li.Value >= ri.Value
+		result = left.n >= right.n
 	case code.OpLessThan:
-		if !lok || !rok {
-			return fmt.Errorf("< requires integers, got %T %T", left, right)
-		}
-		result = li.Value < ri.Value
+		result = left.n < right.n
 	case code.OpLessEqual:
-		if !lok || !rok {
-			return fmt.Errorf("<= requires integers, got %T %T", left, right)
-		}
-		result = li.Value <= ri.Value
+		result = left.n <= right.n
 	}
 
-	return vm.push(&object.Boolean{Value: result})
+	return vm.push(BoolValue(result))
 }
 
-func (vm *VM) executeBang() error {
-	operand := vm.pop()
-	switch v := operand.(type) {
-	case *object.Boolean:
-		return vm.push(&object.Boolean{Value: !v.Value})
-	case *object.Null:
-		return vm.push(&object.Boolean{Value: true})
-	default:
-		return vm.push(&object.Boolean{Value: false})
-	}
-}
-
-func isTruthy(o object.Object) bool {
-	switch v := o.(type) {
-	case *object.Boolean:
-		return v.Value
-	case *object.Null:
-		return false
+func comparisonSymbol(op code.Opcode) string {
+	switch op {
+	case code.OpGreaterThan:
+		return ">"
+	case code.OpGreaterEqual:
+		return ">="
+	case code.OpLessThan:
+		return "<"
 	default:
-		return true
+		return "<="
 	}
 }
@@ -0,0 +1,435 @@
+package vm_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mingo/internal/code"
+	"mingo/internal/compiler"
+	"mingo/internal/lexer"
+	"mingo/internal/module"
+	"mingo/internal/object"
+	"mingo/internal/parser"
+	"mingo/internal/stdlib"
+	"mingo/internal/vm"
+)
+
+// runGlobals compiles and runs input, returning the globals slice so tests can
+// inspect final variable values without going through print.
+func runGlobals(t testing.TB, input string) []vm.Value {
+	globals, _ := run(t, input)
+	return globals
+}
+
+// global runs input and returns the final value of the named global.
+func global(t testing.TB, input, name string) vm.Value {
+	globals, sym := run(t, input)
+	s, ok := sym.Resolve(name)
+	if !ok {
+		t.Fatalf("%q: global %s not defined", input, name)
+	}
+	return globals[s.Index]
+}
+
+func run(t testing.TB, input string) ([]vm.Value, *compiler.SymbolTable) {
+	t.Helper()
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	sym := compiler.NewSymbolTable()
+	comp := compiler.NewWithState(sym, nil)
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	globals := vm.NewGlobals()
+	machine := vm.NewFromBytecode(comp.Bytecode(), globals)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("runtime error: %v", err)
+	}
+	return globals, sym
+}
+
+// output runs input and returns everything it printed and the run error.
+func output(t testing.TB, input string) (string, error) {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	var out strings.Builder
+	machine := vm.NewFromBytecode(comp.Bytecode(), nil)
+	machine.SetOutput(&out)
+	err := machine.Run()
+	return out.String(), err
+}
+
+func TestGlobalsAfterLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1 + 2 * 3;", []string{"7"}},
+		{"let x = 10; x = x - 4;", []string{"6"}},
+		{"let a = 1 == 1; let b = true == false; let c = 1 != 2;", []string{"true", "false", "true"}},
+		{"let a = !true; let b = !!5; let c = 3 >= 3; let d = 2 < 1;", []string{"false", "true", "true", "false"}},
+		{"let i = 0; let s = 0; while (i < 5) { s = s + i; i = i + 1; }", []string{"5", "10"}},
+		{"let x = 0; if (1 > 2) { x = 1; } else { x = 2; }", []string{"2"}},
+		{"let a = 5000 == 5000; let b = 5000 != 4999 + 1; let c = true == 1;", []string{"true", "false", "false"}},
+	}
+
+	for _, tt := range tests {
+		globals := runGlobals(t, tt.input)
+		for i, want := range tt.expected {
+			if got := globals[i].Inspect(); got != want {
+				t.Errorf("%q: global %d = %s, want %s", tt.input, i, got, want)
+			}
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn add(a, b) { a + b; } let r = add(2, 3);", "5"},
+		{"fn f() { } let r = f();", "null"},
+		{"fn f(n) { let x = n * 2; let y = x + 1; return y; } let r = f(4);", "9"},
+		{"fn f(n) { if (n > 0) { 1; } else { 2; } } let r = f(0);", "2"},
+		{"fn f(n) { if (n > 0) { 1; } } let r = f(0);", "null"},
+		{"fn fact(n) { if (n < 2) { return 1; } n * fact(n - 1); } let r = fact(10);", "3628800"},
+		{"let g = 7; fn f() { let a = 1; g = g + a; g; } f(); let r = f();", "9"},
+		{"let r = fn(x) { x * x; }(6);", "36"},
+		{"let r = 0; let i = 0; fn sq(x) { x * x; } while (i < 4) { r = r + sq(i); i = i + 1; }", "14"},
+	}
+
+	for _, tt := range tests {
+		if got := global(t, tt.input, "r").Inspect(); got != tt.expected {
+			t.Errorf("%q: r = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn f(a) { a; } f(1, 2);", "1:17: ArgumentError: wrong number of arguments: want=1, got=2"},
+		{"let x = 1; x(2);", "1:13: TypeError: calling non-function: INTEGER"},
+		{"1 / 0;", "1:3: ZeroDivisionError: division by zero"},
+		{"let a = 1;\n  true + a;", "2:8: TypeError: unsupported types for binary op: BOOLEAN INTEGER"},
+		{"fn f() { f(); } f();", "1:11: StackOverflowError: call stack overflow"},
+		{"fn f(x) { if (x > 2) { throw x * 10; } f(x + 1); } f(0);", "1:24: Error: 30"},
+		{"try { 1 / 0; } finally { print(1); }", "1:9: ZeroDivisionError: division by zero"},
+		// builtins raise at the call
+		{"import \"math\" as m;\nm.abs(1, 2);", "2:6: ArgumentError: wrong number of arguments to math.abs: want=1, got=2"},
+		{"import \"math\" as m; let f = m.max; f(1, true);", "1:37: TypeError: math.max: argument 2 must be INTEGER, got BOOLEAN"},
+		{"import \"math\" as m; m.checked_mul(m.MAX_INT, 2);", "1:34: OverflowError: math.checked_mul: integer overflow"},
+		{"let xs = [1, 2]; xs[2];", "1:20: IndexError: index 2 out of range for array of length 2"},
+		{"[1][-1];", "1:4: IndexError: index -1 out of range for array of length 1"},
+		{"let s = \"ab\"; s[0];", "1:16: TypeError: index operator not supported: STRING"},
+		{"[1][true];", "1:4: TypeError: array index must be INTEGER, got BOOLEAN"},
+		{"\"a\" + 1;", "1:5: TypeError: unsupported types for binary op: STRING INTEGER"},
+		{"import \"json\" as j; let m = j.parse(\"{}\"); m[\"𝒳\"];", "1:45: KeyError: key \"𝒳\" not in map"},
+		{"import \"maps\" as m; m.new()[0];", "1:28: TypeError: map key must be STRING, got INTEGER"},
+		{"import \"strings\" as s; s.slice(\"𝒳\", 0, 2);", "1:31: IndexError: strings.slice: range [0:2] out of range for string of length 1"},
+	}
+
+	for _, tt := range tests {
+		_, err := output(t, tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: error = %v, want %q", tt.input, err, tt.expected)
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { print(1); } catch (e) { print(2); } print(3);", "1 3"},
+		{"try { 1 / 0; print(1); } catch (e) { print(e); }", "ZeroDivisionError: division by zero"},
+		{"try { throw 42; } catch (e) { print(e); } finally { print(0); }", "Error: 42 0"},
+		{"try { print(1); } finally { print(2); } print(3);", "1 2 3"},
+		{"try { try { throw 1; } finally { print(2); } } catch { print(3); }", "2 3"},
+		{"try { try { throw 1; } catch (e) { throw 2; } } catch (e) { print(e); }", "Error: 2"},
+		// errors unwind across frames, dropping the callee's stack
+		{"fn f(n) { if (n == 0) { true + 1; } 1 + f(n - 1); } try { f(5); } catch (e) { print(e); } print(9);",
+			"TypeError: unsupported types for binary op: BOOLEAN INTEGER 9"},
+		{"fn f() { try { return 1; } finally { print(2); } } print(f());", "2 1"},
+		{"fn f() { try { throw 1; } catch (e) { return 5; } finally { print(2); } } print(f());", "2 5"},
+		// an error in the finally block a return runs leaves the try
+		// statement, without running the block again
+		{"fn f() { try { return 1; } catch (e) { print(e); } finally { print(2); throw 3; } } try { f(); } catch (e) { print(e); }",
+			"2 Error: 3"},
+		{"fn f() { try { try { return 1; } catch { print(0); } } catch (e) { print(e); } finally { throw 2; } } try { f(); } catch (e) { print(e); }",
+			"Error: 2"},
+		{"fn f() { try { try { return 1; } finally { throw 2; } } catch (e) { print(e); } 3; } print(f());", "Error: 2 3"},
+		{"fn f() { try { throw 1; } catch (e) { return e; } finally { throw 2; } } try { f(); } catch (e) { print(e); }", "Error: 2"},
+		// the copies of a finally block share its variables
+		{"try { print(0); } finally { let x = 1; } print(x);", "0 1"},
+		{"fn f() { try { return 1; } finally { let y = 2; print(y); } } print(f); print(f());", "compiled fn[params=0 locals=1] 2 1"},
+		// a try nested in an expression keeps the operands below it
+		{"let x = 1 + if (true) { try { throw 1; } catch { } 2; }; print(x);", "3"},
+		{"fn f(a) { let b = a * 2; try { throw b; } catch (e) { print(e); } b + a; } print(f(3));", "Error: 6 9"},
+		{"let i = 0; while (i < 3) { try { if (i == 1) { throw i; } print(i); } catch (e) { print(e); } i = i + 1; }",
+			"0 Error: 1 2"},
+		{"import \"math\" as m; try { print(m.sqrt(-1)); } catch (e) { print(e); } print(m.min(m.pow(2, 3), 9));",
+			"ValueError: math.sqrt: negative argument -1 8"},
+		// a builtin calling back into the program keeps the operands below it
+		{`import "testing" as t; print(1 + [2, t.assert_error(fn() { try { throw 1; } catch { } 1 / 0; })][0]);`, "3"},
+		{`import "testing" as t; print(t.assert_error(fn() { t.assert_error(fn() { 1; }); }, "AssertionError"));`,
+			"AssertionError: no error was raised"},
+		{`import "testing" as t; fn f() { t.assert_error(fn() { 1; }); } try { f(); } catch (e) { print(e); } print(2);`,
+			"AssertionError: no error was raised 2"},
+	}
+
+	for _, tt := range tests {
+		out, err := output(t, tt.input)
+		if err != nil {
+			t.Errorf("%q: runtime error: %v", tt.input, err)
+			continue
+		}
+		if got := strings.Join(strings.Fields(out), " "); got != tt.expected {
+			t.Errorf("%q: output %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestStringsAndArrays(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`print("a 𝒳" + "" + "b");`, "a 𝒳b\n"},
+		{`let xs = [1, "𝒳", [true]]; print(xs); print(xs[2][0]); print(xs[1]);`, "[1, \"𝒳\", [true]]\ntrue\n𝒳\n"},
+		{`print("𝒳" == "𝒳"); print("a" != "b"); print([1] == [1]); let a = [1]; print(a == a);`, "true\ntrue\nfalse\ntrue\n"},
+		{`import "strings" as s; let w = s.split("𝒳,b,c", ","); print(s.join(w, "+")); print(s.len(w[0]));`, "𝒳+b+c\n1\n"},
+		{`import "strings" as s; print(s.format("{} has {} runes", "𝒳𝒳", s.len("𝒳𝒳")));`, "𝒳𝒳 has 2 runes\n"},
+		{`import "strings" as s; import "arrays" as a; print(a.len(a.push(s.split("𝒳", ""), "x")));`, "2\n"},
+		{`import "json" as json; let cfg = json.parse("{\"n\": [1, {\"𝒳\": true}]}"); print(cfg["n"][1]["𝒳"]); print(json.stringify(cfg));`,
+			"true\n{\"n\":[1,{\"𝒳\":true}]}\n"},
+		{`import "maps" as maps; let m = maps.new(); maps.set(m, "a", [m]); print(m); print(m == m); print(m == maps.new());`,
+			"{\"a\": [{...}]}\ntrue\nfalse\n"},
+		// operands stay in place below an array being built
+		{"fn f(n) { [n, n * 2][1]; } print(1 + f(3) + [4][0]);", "11\n"},
+	}
+
+	for _, tt := range tests {
+		out, err := output(t, tt.input)
+		if err != nil {
+			t.Errorf("%q: runtime error: %v", tt.input, err)
+			continue
+		}
+		if out != tt.expected {
+			t.Errorf("%q: output %q, want %q", tt.input, out, tt.expected)
+		}
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "in.txt"), []byte("𝒳"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := parser.New(lexer.New(`import "io" as io; io.write_file("out.txt", io.read_file("in.txt") + "!");`))
+	comp := compiler.New()
+	if err := comp.Compile(p.ParseProgram()); err != nil {
+		t.Fatal(err)
+	}
+
+	// denied by default
+	err := vm.NewFromBytecode(comp.Bytecode(), nil).Run()
+	if want := "1:57: PermissionError: io.read_file: no file access was granted"; err == nil || err.Error() != want {
+		t.Fatalf("wrong error. want=%q got=%v", want, err)
+	}
+
+	machine := vm.NewFromBytecode(comp.Bytecode(), nil)
+	machine.SetCapabilities(stdlib.Capabilities{Root: root})
+	if err := machine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(root, "out.txt")); err != nil || string(b) != "𝒳!" {
+		t.Fatalf("wrong file written: %q %v", b, err)
+	}
+}
+
+func TestErrorValue(t *testing.T) {
+	_, err := output(t, "let x = 0;\nlet y = 10 / x;")
+	rt, ok := err.(*vm.RuntimeError)
+	if !ok {
+		t.Fatalf("error is %T, want *vm.RuntimeError", err)
+	}
+	if rt.Err.Kind != object.ZeroDivisionKind || rt.Err.Message != "division by zero" {
+		t.Errorf("error = %s: %s", rt.Err.Kind, rt.Err.Message)
+	}
+	if rt.Err.Pos.Line != 2 || rt.Err.Pos.Column != 12 {
+		t.Errorf("position = %d:%d, want 2:12", rt.Err.Pos.Line, rt.Err.Pos.Column)
+	}
+}
+
+func TestSharedValues(t *testing.T) {
+	globals := runGlobals(t, "let a = 1 < 2; let b = !false; let c = 40 + 2; let d = 100000 + 1;")
+	if globals[0].Object() != object.TRUE || globals[1].Object() != object.TRUE {
+		t.Errorf("booleans not shared: %p %p, want %p", globals[0].Object(), globals[1].Object(), object.TRUE)
+	}
+	if globals[2].Kind() != vm.IntKind || globals[2].Object() != object.NewInteger(42) {
+		t.Errorf("small integer not unboxed or not served from cache")
+	}
+	if got := globals[3].Inspect(); got != "100001" {
+		t.Errorf("large integer = %s, want 100001", got)
+	}
+}
+
+// Any program that compiles runs to completion, to a runtime error or out
+// of its budget, without panicking. Imports of files are refused, and no
+// capabilities are granted.
+func FuzzRun(f *testing.F) {
+	files, _ := filepath.Glob("../../examples/*.mg")
+	if len(files) == 0 {
+		f.Fatalf("no examples found")
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(src))
+	}
+	f.Add(`import "arrays" as a; let xs = [1]; try { xs[2] } catch (e) { a.push(xs, e) }`)
+	f.Add("fn f(n) { f(n + 1) } f(0);")
+	f.Fuzz(func(t *testing.T, input string) {
+		loader := &module.Loader{ReadFile: func(path string) ([]byte, error) {
+			return nil, errors.New("no files")
+		}}
+		mods, err := loader.Load("", input)
+		if err != nil {
+			return
+		}
+		comp := compiler.New()
+		if err := module.Compile(comp, mods); err != nil {
+			return
+		}
+		machine := vm.NewFromBytecode(comp.Bytecode(), nil)
+		machine.SetOutput(io.Discard)
+		machine.SetHook(&budget{instructions: 100000})
+		err = machine.Run()
+		if _, ok := err.(*vm.RuntimeError); err != nil && !ok && err != errBudget {
+			t.Fatalf("%q: unexpected error %T: %v", input, err, err)
+		}
+	})
+}
+
+var errBudget = errors.New("budget exceeded")
+
+// budget stops a run after a number of instructions, or before it joins
+// two strings into one longer than a megabyte: doubling a string in a loop
+// exhausts memory well within any useful instruction count.
+type budget struct {
+	instructions int
+}
+
+func (b *budget) Instruction(machine *vm.VM, op code.Opcode) error {
+	b.instructions--
+	if b.instructions < 0 {
+		return errBudget
+	}
+	if stack := machine.Stack(); op == code.OpAdd && len(stack) >= 2 {
+		l, lok := stack[len(stack)-2].Object().(*object.String)
+		r, rok := stack[len(stack)-1].Object().(*object.String)
+		if lok && rok && len(l.Value)+len(r.Value) > 1<<20 {
+			return errBudget
+		}
+	}
+	return nil
+}
+
+const loopProgram = `
+let i = 0;
+let sum = 0;
+let evens = 0;
+while (i < 10000) {
+  sum = sum + i * 2 - 1;
+  if (i - (i / 2) * 2 == 0) { evens = evens + 1; }
+  i = i + 1;
+}
+`
+
+const fibLoopProgram = `
+let round = 0;
+while (round < 200) {
+  let a = 0;
+  let b = 1;
+  let i = 0;
+  while (i < 40) {
+    let next = a + b;
+    a = b;
+    b = next;
+    i = i + 1;
+  }
+  round = round + 1;
+}
+`
+
+// scaledFibExample runs examples/fib.mg for a larger n, many times over.
+func scaledFibExample(b *testing.B) string {
+	src, err := os.ReadFile("../../examples/fib.mg")
+	if err != nil {
+		b.Fatal(err)
+	}
+	body := strings.Replace(string(src), "let n = 8;", "let n = 90;", 1)
+	return "let round = 0;\nwhile (round < 100) {\n" + body + "round = round + 1;\n}\n"
+}
+
+func benchmarkProgram(b *testing.B, input string) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		b.Fatalf("parse errors: %v", p.Errors())
+	}
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		b.Fatalf("compile error: %v", err)
+	}
+	// share one globals store so the benchmark measures execution rather
+	// than allocating a fresh store on every iteration
+	globals := vm.NewGlobals()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		machine := vm.NewWithGlobals(comp.Instructions(), comp.Constants(), globals)
+		machine.SetOutput(io.Discard)
+		if err := machine.Run(); err != nil {
+			b.Fatalf("runtime error: %v", err)
+		}
+	}
+}
+
+func BenchmarkLoop(b *testing.B)    { benchmarkProgram(b, loopProgram) }
+func BenchmarkFibLoop(b *testing.B) { benchmarkProgram(b, fibLoopProgram) }
+
+const recursiveFibProgram = `
+fn fib(n) {
+  if (n < 2) { return n; }
+  fib(n - 1) + fib(n - 2);
+}
+let result = fib(20);
+`
+
+func BenchmarkRecursiveFib(b *testing.B) { benchmarkProgram(b, recursiveFibProgram) }
+func BenchmarkFibExample(b *testing.B)   { benchmarkProgram(b, scaledFibExample(b)) }